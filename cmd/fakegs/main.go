@@ -0,0 +1,39 @@
+// Command fakegs runs a fake GrooveStats API for testing the launcher. Point
+// the GrooveStats URL setting at it, e.g. http://127.0.0.1:8765.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/GrooveStats/gslauncher/internal/fakegs"
+)
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:8765", "listen on this address")
+	scenarioPath := flag.String("scenario", "", "load a scenario file")
+	fixtureDir := flag.String("fixtures", "", "override the built-in fixtures with the ones in this directory")
+	flag.Parse()
+
+	scenario := fakegs.DefaultScenario()
+	if *scenarioPath != "" {
+		var err error
+		scenario, err = fakegs.LoadScenario(*scenarioPath)
+		if err != nil {
+			log.Fatal("failed to load scenario: ", err)
+		}
+	}
+
+	server := fakegs.NewServer(scenario)
+
+	if *fixtureDir != "" {
+		err := server.LoadFixtures(*fixtureDir)
+		if err != nil {
+			log.Fatal("failed to load fixtures: ", err)
+		}
+	}
+
+	log.Printf("listening on http://%s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, server))
+}
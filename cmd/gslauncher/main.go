@@ -1,52 +1,24 @@
 package main
 
 import (
-	"bytes"
 	"flag"
-	"io"
 	"log"
+	"log/slog"
 	"os"
-	"path/filepath"
 	"runtime"
 
+	"github.com/GrooveStats/gslauncher/internal/events"
 	"github.com/GrooveStats/gslauncher/internal/gui"
+	"github.com/GrooveStats/gslauncher/internal/history"
+	"github.com/GrooveStats/gslauncher/internal/logging"
+	"github.com/GrooveStats/gslauncher/internal/metrics"
+	"github.com/GrooveStats/gslauncher/internal/scorequeue"
 	"github.com/GrooveStats/gslauncher/internal/settings"
 	"github.com/GrooveStats/gslauncher/internal/unlocks"
+	"github.com/GrooveStats/gslauncher/internal/usage"
 	"github.com/GrooveStats/gslauncher/internal/version"
 )
 
-const MAX_LOG_SIZE = 1024 * 1024 // 1 MiB
-
-func redirectLog(cacheDir string) {
-	filename := filepath.Join(cacheDir, "groovestats-launcher", "log.txt")
-
-	old, err := os.ReadFile(filename)
-	if err == nil {
-		if len(old) > MAX_LOG_SIZE {
-			old = old[len(old)-MAX_LOG_SIZE:]
-			idx := bytes.IndexByte(old, byte('\n'))
-			old = old[idx+1:]
-		}
-	}
-
-	logFile, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
-	if err != nil {
-		log.Print("failed to open log file: ", err)
-		return
-	}
-
-	if old != nil {
-		logFile.Write(old)
-		logFile.WriteString("-----\n")
-	}
-
-	if settings.Get().Debug {
-		log.SetOutput(io.MultiWriter(os.Stderr, logFile))
-	} else {
-		log.SetOutput(logFile)
-	}
-}
-
 func main() {
 	userCacheDir, err := os.UserCacheDir()
 	if err != nil {
@@ -58,20 +30,53 @@ func main() {
 	cacheDir := flag.String("cachedir", userCacheDir, "set the cache location")
 	flag.Parse()
 
-	redirectLog(*cacheDir)
-	log.Printf("GrooveStats Launcher %s (%s %s)", version.Formatted(), runtime.GOOS, runtime.GOARCH)
+	logFile, err := logging.Setup(*cacheDir, settings.Get().Debug)
+	if err != nil {
+		log.Print("failed to open log file: ", err)
+	} else {
+		defer logFile.Close()
+	}
+
+	slog.Info("GrooveStats Launcher", "version", version.Formatted(), "os", runtime.GOOS, "arch", runtime.GOARCH)
 
 	settings.Load()
 	if settings.Get().FirstLaunch {
 		settings.DetectSM()
 	}
+	logging.SetLevel(logging.ParseLevel(settings.Get().LogLevel))
+
+	if port := settings.Get().MetricsPort; port != 0 {
+		_, err = metrics.Serve(port)
+		if err != nil {
+			slog.Error("failed to start metrics endpoint", "err", err)
+		}
+	}
 
 	unlockManager, err := unlocks.NewManager(*cacheDir)
 	if err != nil {
-		log.Print("failed to initialize downloader: ", err)
+		slog.Error("failed to initialize downloader", "err", err)
+		return
+	}
+
+	scoreQueue, err := scorequeue.NewQueue(*cacheDir)
+	if err != nil {
+		slog.Error("failed to initialize score queue", "err", err)
+		return
+	}
+
+	scoreHistory, err := history.NewHistory(*cacheDir)
+	if err != nil {
+		slog.Error("failed to open score history", "err", err)
+		return
+	}
+	defer scoreHistory.Close()
+
+	usageStats, err := usage.Open(*cacheDir)
+	if err != nil {
+		slog.Error("failed to open statistics", "err", err)
 		return
 	}
 
-	app := gui.NewApp(unlockManager, *autolaunch, *cacheDir)
+	app := gui.NewApp(unlockManager, scoreQueue, scoreHistory, usageStats, events.NewTracker(), *autolaunch, *cacheDir)
 	app.Run()
 }
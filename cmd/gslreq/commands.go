@@ -0,0 +1,216 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/GrooveStats/gslauncher/internal/version"
+)
+
+type playerFlags struct {
+	chartHash *string
+	apiKey    *string
+}
+
+func addPlayerFlags(flags *flag.FlagSet, player int) playerFlags {
+	return playerFlags{
+		chartHash: flags.String(fmt.Sprintf("p%d-chart-hash", player), "", fmt.Sprintf("chart hash for player %d", player)),
+		apiKey:    flags.String(fmt.Sprintf("p%d-api-key", player), "", fmt.Sprintf("api key of player %d", player)),
+	}
+}
+
+func (p playerFlags) set() bool {
+	return *p.chartHash != "" || *p.apiKey != ""
+}
+
+func (p playerFlags) data() map[string]interface{} {
+	return map[string]interface{}{
+		"chartHash": *p.chartHash,
+		"apiKey":    *p.apiKey,
+	}
+}
+
+// parse parses the flags and reports whether the arguments are valid. It
+// also returns the exit code to use if they are not.
+func parse(flags *flag.FlagSet, args []string, nargs int) (bool, int) {
+	err := flags.Parse(args)
+	if err == flag.ErrHelp {
+		return false, exitSuccess
+	}
+	if err != nil {
+		return false, exitUsage
+	}
+
+	if flags.NArg() != nargs {
+		flags.Usage()
+		return false, exitUsage
+	}
+
+	return true, 0
+}
+
+func runPing(args []string) int {
+	flags, opts := newFlagSet("ping", "")
+	protocol := flags.Int("protocol", version.Protocol, "newest protocol version to request")
+	minProtocol := flags.Int("min-protocol", 0, "oldest protocol version to accept")
+
+	if ok, code := parse(flags, args, 0); !ok {
+		return code
+	}
+
+	request := map[string]interface{}{
+		"action":   "ping",
+		"protocol": *protocol,
+	}
+	if *minProtocol != 0 {
+		request["minProtocol"] = *minProtocol
+	}
+
+	return send(opts, request)
+}
+
+func runNewSession(args []string) int {
+	flags, opts := newFlagSet("new-session", "")
+	chartHashVersion := flags.Int("chart-hash-version", 3, "chart hash version used by the theme")
+
+	if ok, code := parse(flags, args, 0); !ok {
+		return code
+	}
+
+	return send(opts, map[string]interface{}{
+		"action":           "groovestats/new-session",
+		"chartHashVersion": *chartHashVersion,
+	})
+}
+
+func runPlayerScores(args []string) int {
+	flags, opts := newFlagSet("player-scores", "")
+	player1 := addPlayerFlags(flags, 1)
+	player2 := addPlayerFlags(flags, 2)
+
+	if ok, code := parse(flags, args, 0); !ok {
+		return code
+	}
+
+	request := map[string]interface{}{
+		"action": "groovestats/player-scores",
+	}
+
+	return sendPlayerRequest(flags, opts, request, player1, player2)
+}
+
+func runLeaderboards(args []string) int {
+	flags, opts := newFlagSet("leaderboards", "")
+	maxResults := flags.Int("max-results", 0, "maximum number of leaderboard entries")
+	player1 := addPlayerFlags(flags, 1)
+	player2 := addPlayerFlags(flags, 2)
+
+	if ok, code := parse(flags, args, 0); !ok {
+		return code
+	}
+
+	request := map[string]interface{}{
+		"action": "groovestats/player-leaderboards",
+	}
+	if *maxResults != 0 {
+		request["maxLeaderboardResults"] = *maxResults
+	}
+
+	return sendPlayerRequest(flags, opts, request, player1, player2)
+}
+
+func sendPlayerRequest(flags *flag.FlagSet, opts *options, request map[string]interface{}, player1, player2 playerFlags) int {
+	if !player1.set() && !player2.set() {
+		fmt.Fprintln(flags.Output(), "data for at least one player has to be provided")
+		flags.Usage()
+		return exitUsage
+	}
+
+	if player1.set() {
+		request["player1"] = player1.data()
+	}
+	if player2.set() {
+		request["player2"] = player2.data()
+	}
+
+	return send(opts, request)
+}
+
+type submitFlags struct {
+	playerFlags
+	profileName *string
+	score       *int
+	rate        *int
+	comment     *string
+}
+
+func addSubmitFlags(flags *flag.FlagSet, player int) submitFlags {
+	return submitFlags{
+		playerFlags: addPlayerFlags(flags, player),
+		profileName: flags.String(fmt.Sprintf("p%d-profile-name", player), "", fmt.Sprintf("profile name of player %d", player)),
+		score:       flags.Int(fmt.Sprintf("p%d-score", player), 0, fmt.Sprintf("score of player %d x100", player)),
+		rate:        flags.Int(fmt.Sprintf("p%d-rate", player), 100, fmt.Sprintf("music rate of player %d x100", player)),
+		comment:     flags.String(fmt.Sprintf("p%d-comment", player), "", fmt.Sprintf("comment for the score of player %d", player)),
+	}
+}
+
+func (p submitFlags) data() map[string]interface{} {
+	data := p.playerFlags.data()
+	data["profileName"] = *p.profileName
+	data["score"] = *p.score
+	data["rate"] = *p.rate
+	data["comment"] = *p.comment
+
+	return data
+}
+
+func runSubmit(args []string) int {
+	flags, opts := newFlagSet("submit", "")
+	maxResults := flags.Int("max-results", 0, "maximum number of leaderboard entries")
+	player1 := addSubmitFlags(flags, 1)
+	player2 := addSubmitFlags(flags, 2)
+
+	if ok, code := parse(flags, args, 0); !ok {
+		return code
+	}
+
+	if !player1.set() && !player2.set() {
+		fmt.Fprintln(flags.Output(), "data for at least one player has to be provided")
+		flags.Usage()
+		return exitUsage
+	}
+
+	request := map[string]interface{}{
+		"action": "groovestats/score-submit",
+	}
+	if *maxResults != 0 {
+		request["maxLeaderboardResults"] = *maxResults
+	}
+	if player1.set() {
+		request["player1"] = player1.data()
+	}
+	if player2.set() {
+		request["player2"] = player2.data()
+	}
+
+	return send(opts, request)
+}
+
+func runRaw(args []string) int {
+	flags, opts := newFlagSet("raw", "< request.json")
+
+	if ok, code := parse(flags, args, 0); !ok {
+		return code
+	}
+
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		log.Print(err)
+		return exitError
+	}
+
+	return send(opts, data)
+}
@@ -1,70 +1,221 @@
 package main
 
 import (
+	"bytes"
 	"crypto/rand"
+	"encoding/json"
+	"flag"
 	"fmt"
-	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/GrooveStats/gslauncher/internal/settings"
 )
 
+// exit codes
+const (
+	exitSuccess  = 0
+	exitError    = 1
+	exitUsage    = 2
+	exitFail     = 3
+	exitDisabled = 4
+	exitInvalid  = 5
+)
+
+type command struct {
+	name        string
+	description string
+	run         func(args []string) int
+}
+
+var commands = []command{
+	{"ping", "check whether the launcher is running", runPing},
+	{"new-session", "start a GrooveStats session", runNewSession},
+	{"player-scores", "fetch the scores of the players", runPlayerScores},
+	{"leaderboards", "fetch the leaderboards of the charts", runLeaderboards},
+	{"submit", "submit a score", runSubmit},
+	{"raw", "send a request read from stdin", runRaw},
+	{"replay", "replay a recorded transcript", runReplay},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: gslreq <command> [options]\n\n")
+	fmt.Fprintf(os.Stderr, "commands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-15s %s\n", cmd.name, cmd.description)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun gslreq <command> -h for the options of a command.\n")
+}
+
 func main() {
-	settings.Load()
+	log.SetFlags(0)
+	log.SetPrefix("gslreq: ")
 
-	if settings.Get().SmSaveDir == "" {
-		log.Fatal("Save directory not configured")
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(exitUsage)
 	}
 
-	dataDir := filepath.Join(settings.Get().SmSaveDir, "GrooveStats")
-	uuid := genUuid4()
+	for _, cmd := range commands {
+		if cmd.name == os.Args[1] {
+			os.Exit(cmd.run(os.Args[2:]))
+		}
+	}
 
-	filename := uuid + ".json"
-	tmpfile := filepath.Join(dataDir, "requests", "new."+filename+".new")
-	requestFile := filepath.Join(dataDir, "requests", filename)
-	responseFile := filepath.Join(dataDir, "responses", filename)
+	usage()
+	os.Exit(exitUsage)
+}
+
+type options struct {
+	timeout time.Duration
+	saveDir string
+	raw     bool
+}
+
+func newFlagSet(name string, arguments string) (*flag.FlagSet, *options) {
+	opts := &options{}
+
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.DurationVar(&opts.timeout, "timeout", time.Minute, "how long to wait for a response")
+	flags.StringVar(&opts.saveDir, "save-dir", "", "StepMania Save directory (default from the launcher settings)")
+	flags.BoolVar(&opts.raw, "raw", false, "print the response as received instead of pretty-printing it")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: gslreq %s\n", strings.TrimSpace(name+" [options] "+arguments))
+		flags.PrintDefaults()
+	}
+
+	return flags, opts
+}
+
+func (opts *options) dataDir() (string, error) {
+	saveDir := opts.saveDir
+
+	if saveDir == "" {
+		settings.Load()
+		saveDir = settings.Get().SmSaveDir
+	}
+
+	if saveDir == "" {
+		return "", fmt.Errorf("Save directory not configured")
+	}
+
+	return filepath.Join(saveDir, "GrooveStats"), nil
+}
+
+// send sends a request, prints the response and returns the exit code
+// matching the status of the response.
+func send(opts *options, request interface{}) int {
+	data, ok := request.([]byte)
+	if !ok {
+		var err error
+
+		data, err = json.Marshal(request)
+		if err != nil {
+			log.Print(err)
+			return exitError
+		}
+	}
 
-	f, err := os.Create(tmpfile)
+	dataDir, err := opts.dataDir()
 	if err != nil {
-		log.Fatal(err)
+		log.Print(err)
+		return exitError
+	}
+
+	response, err := sendRequest(dataDir, data, opts.timeout)
+	if err != nil {
+		log.Print(err)
+		return exitError
+	}
+
+	if !opts.raw {
+		var buf bytes.Buffer
+
+		err := json.Indent(&buf, bytes.TrimSpace(response), "", "    ")
+		if err == nil {
+			buf.WriteByte('\n')
+			response = buf.Bytes()
+		}
+	}
+
+	_, err = os.Stdout.Write(response)
+	if err != nil {
+		log.Print(err)
+		return exitError
+	}
+
+	return exitCode(response)
+}
+
+func exitCode(response []byte) int {
+	var base struct {
+		Status string `json:"status"`
+	}
+
+	err := json.Unmarshal(response, &base)
+	if err != nil {
+		return exitError
 	}
 
-	_, err = io.Copy(f, os.Stdin)
+	switch base.Status {
+	case "", "success":
+		// ping responses don't have a status
+		return exitSuccess
+	case "fail":
+		return exitFail
+	case "disabled":
+		return exitDisabled
+	case "invalid":
+		return exitInvalid
+	default:
+		return exitError
+	}
+}
+
+// sendRequest writes a request file and waits for the launcher to respond.
+func sendRequest(dataDir string, data []byte, timeout time.Duration) ([]byte, error) {
+	filename := genUuid4() + ".json"
+	tmpfile := filepath.Join(dataDir, "requests", "new."+filename+".new")
+	requestFile := filepath.Join(dataDir, "requests", filename)
+	responseFile := filepath.Join(dataDir, "responses", filename)
+
+	err := os.WriteFile(tmpfile, data, 0600)
 	if err != nil {
-		f.Close()
 		os.Remove(tmpfile)
+		return nil, err
 	}
 
 	err = os.Rename(tmpfile, requestFile)
 	if err != nil {
-		f.Close()
 		os.Remove(tmpfile)
+		return nil, err
 	}
 
-	for i := 0; i < 60; i++ {
+	deadline := time.Now().Add(timeout)
+
+	for time.Now().Before(deadline) {
 		time.Sleep(100 * time.Millisecond)
 
-		f, err := os.Open(responseFile)
+		response, err := os.ReadFile(responseFile)
 		if err != nil {
 			if os.IsNotExist(err) {
 				continue
 			}
 
-			log.Fatal(err)
+			return nil, err
 		}
 
-		_, err = io.Copy(os.Stdout, f)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		return
+		os.Remove(responseFile)
+		return response, nil
 	}
 
-	log.Fatal("timeout")
+	// don't leave the request behind if the launcher didn't pick it up
+	os.Remove(requestFile)
+
+	return nil, fmt.Errorf("timeout")
 }
 
 func genUuid4() string {
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/GrooveStats/gslauncher/internal/fsipc"
+)
+
+// runReplay sends the requests of a transcript to the launcher one by one and
+// compares the responses with the recorded ones. Score submissions are
+// skipped unless asked for, they would submit the scores to GrooveStats
+// again.
+func runReplay(args []string) int {
+	flags, opts := newFlagSet("replay", "<transcript>")
+	apiKeyP1 := flags.String("p1-api-key", "", "api key to use for player 1")
+	apiKeyP2 := flags.String("p2-api-key", "", "api key to use for player 2")
+	profileNameP1 := flags.String("p1-profile-name", "", "profile name to use for player 1 (default \"<redacted>\")")
+	profileNameP2 := flags.String("p2-profile-name", "", "profile name to use for player 2 (default \"<redacted>\")")
+	allowSubmit := flags.Bool("allow-submit", false, "replay score submissions as well")
+
+	if ok, code := parse(flags, args, 1); !ok {
+		return code
+	}
+
+	dataDir, err := opts.dataDir()
+	if err != nil {
+		log.Print(err)
+		return exitError
+	}
+
+	requests, responses, err := readTranscript(flags.Arg(0))
+	if err != nil {
+		log.Print(err)
+		return exitError
+	}
+
+	differences := 0
+
+	apiKeys := [2]string{*apiKeyP1, *apiKeyP2}
+	profileNames := [2]string{*profileNameP1, *profileNameP2}
+
+	for _, request := range requests {
+		if isScoreSubmit(request.Data) && !*allowSubmit {
+			fmt.Printf("%s: skipped, score submissions are only replayed with -allow-submit\n", request.Id)
+			continue
+		}
+
+		data, err := substitutePlayers(request.Data, apiKeys, profileNames)
+		if err != nil {
+			fmt.Printf("%s: skipped, %v\n", request.Id, err)
+			continue
+		}
+
+		response, err := sendRequest(dataDir, data, opts.timeout)
+		if err != nil {
+			fmt.Printf("%s: %v\n", request.Id, err)
+			differences++
+			continue
+		}
+
+		recorded, ok := responses[request.Id]
+		if !ok {
+			fmt.Printf("%s: no recorded response\n", request.Id)
+			continue
+		}
+
+		diff, err := diffJson(recorded.Data, response)
+		if err != nil {
+			fmt.Printf("%s: %v\n", request.Id, err)
+			differences++
+			continue
+		}
+
+		if len(diff) == 0 {
+			fmt.Printf("%s: ok\n", request.Id)
+			continue
+		}
+
+		differences++
+		fmt.Printf("%s: response differs\n", request.Id)
+		for _, line := range diff {
+			fmt.Printf("    %s\n", line)
+		}
+	}
+
+	if differences != 0 {
+		return exitError
+	}
+
+	return exitSuccess
+}
+
+// readTranscript returns the requests in the order they were received and the
+// responses by request id. Superseded requests are left out, since they never
+// got a response.
+func readTranscript(filename string) ([]fsipc.TranscriptEntry, map[string]fsipc.TranscriptEntry, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	requests := make([]fsipc.TranscriptEntry, 0)
+	responses := make(map[string]fsipc.TranscriptEntry)
+	superseded := make(map[string]bool)
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		var entry fsipc.TranscriptEntry
+
+		err := json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		switch entry.Kind {
+		case fsipc.TranscriptRequest:
+			requests = append(requests, entry)
+		case fsipc.TranscriptResponse:
+			responses[entry.Id] = entry
+		case fsipc.TranscriptSuperseded:
+			superseded[entry.Id] = true
+		}
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	filtered := make([]fsipc.TranscriptEntry, 0, len(requests))
+	for _, request := range requests {
+		if !superseded[request.Id] {
+			filtered = append(filtered, request)
+		}
+	}
+
+	return filtered, responses, nil
+}
+
+func isScoreSubmit(data []byte) bool {
+	var request struct {
+		Action string `json:"action"`
+	}
+	json.Unmarshal(data, &request)
+
+	return request.Action == "groovestats/score-submit"
+}
+
+// substitutePlayers puts the given api keys in place of the redacted ones.
+// Profile names are only replaced if one is given, otherwise they are sent
+// as "<redacted>".
+func substitutePlayers(data []byte, apiKeys [2]string, profileNames [2]string) ([]byte, error) {
+	var request map[string]interface{}
+
+	err := json.Unmarshal(data, &request)
+	if err != nil {
+		// malformed requests are replayed as they are
+		var raw string
+		if json.Unmarshal(data, &raw) == nil {
+			return []byte(raw), nil
+		}
+		return data, nil
+	}
+
+	for i, player := range []string{"player1", "player2"} {
+		playerData, ok := request[player].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if _, ok := playerData["profileName"]; ok && profileNames[i] != "" {
+			playerData["profileName"] = profileNames[i]
+		}
+
+		if _, ok := playerData["apiKey"]; !ok {
+			continue
+		}
+
+		if apiKeys[i] == "" {
+			return nil, fmt.Errorf("no api key given for %s", player)
+		}
+		playerData["apiKey"] = apiKeys[i]
+	}
+
+	return json.Marshal(request)
+}
+
+// diffJson lists the values that differ between two JSON documents.
+func diffJson(expected []byte, actual []byte) ([]string, error) {
+	var a, b interface{}
+
+	err := json.Unmarshal(expected, &a)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recorded response: %w", err)
+	}
+
+	err = json.Unmarshal(actual, &b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+
+	diff := make([]string, 0)
+	diffValues("", a, b, &diff)
+
+	return diff, nil
+}
+
+func diffValues(path string, a interface{}, b interface{}, diff *[]string) {
+	if path == "" {
+		path = "."
+	}
+
+	switch va := a.(type) {
+	case map[string]interface{}:
+		vb, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+
+		keys := make([]string, 0, len(va)+len(vb))
+		for key := range va {
+			keys = append(keys, key)
+		}
+		for key := range vb {
+			if _, ok := va[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			diffValues(strings.TrimSuffix(path, ".")+"."+key, va[key], vb[key], diff)
+		}
+		return
+	case []interface{}:
+		vb, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+
+		if len(va) != len(vb) {
+			*diff = append(*diff, fmt.Sprintf("%s: expected %d elements, got %d", path, len(va), len(vb)))
+			return
+		}
+
+		for i := range va {
+			diffValues(fmt.Sprintf("%s[%d]", path, i), va[i], vb[i], diff)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		*diff = append(*diff, fmt.Sprintf("%s: expected %s, got %s", path, formatJson(a), formatJson(b)))
+	}
+}
+
+func formatJson(v interface{}) string {
+	if v == nil {
+		return "nothing"
+	}
+
+	b, _ := json.Marshal(v)
+	return string(b)
+}
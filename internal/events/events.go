@@ -0,0 +1,219 @@
+// Package events keeps track of the active GrooveStats events and the
+// progress the local profiles made in them during the running session.
+package events
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/GrooveStats/gslauncher/internal/fsipc"
+	"github.com/GrooveStats/gslauncher/internal/groovestats"
+)
+
+type Event struct {
+	Name      string `json:"name"`
+	ShortName string `json:"shortName"`
+	Url       string `json:"url"`
+
+	// false for events GrooveStats doesn't send anymore
+	Active bool `json:"-"`
+}
+
+// Progress is what a profile earned in an event during the session.
+type Progress struct {
+	ProfileName string
+
+	// scores that improved the standing in the event
+	Scores int
+
+	// ITL points gained
+	Points int
+
+	// titles of the completed quests
+	Quests []string
+}
+
+// Tracker is safe for concurrent use.
+type Tracker struct {
+	mutex  sync.Mutex
+	events []Event
+
+	// keyed by the event name
+	progress map[string][]Progress
+
+	// guarded by mutex, but called without holding it
+	updateCallback func()
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{
+		events:         make([]Event, 0),
+		progress:       make(map[string][]Progress),
+		updateCallback: func() {},
+	}
+}
+
+func (tracker *Tracker) SetUpdateCallback(callback func()) {
+	tracker.mutex.Lock()
+	tracker.updateCallback = callback
+	tracker.mutex.Unlock()
+}
+
+// StartSession forgets the progress of the previous session. The events
+// are kept until GrooveStats sends new ones.
+func (tracker *Tracker) StartSession() {
+	tracker.mutex.Lock()
+	tracker.progress = make(map[string][]Progress)
+	updateCallback := tracker.updateCallback
+	tracker.mutex.Unlock()
+
+	updateCallback()
+}
+
+// SetActiveEvents replaces the events with the ones of a new-session
+// response.
+func (tracker *Tracker) SetActiveEvents(resp *groovestats.NewSessionResponse) {
+	events := make([]Event, 0, len(resp.ActiveEvents))
+	for _, event := range resp.ActiveEvents {
+		events = append(events, Event{
+			Name:      event.Name,
+			ShortName: event.ShortName,
+			Url:       event.Url,
+			Active:    true,
+		})
+	}
+
+	tracker.mutex.Lock()
+	tracker.events = events
+	updateCallback := tracker.updateCallback
+	tracker.mutex.Unlock()
+
+	updateCallback()
+}
+
+// AddScoreSubmit records the progress of the players in a score submission.
+func (tracker *Tracker) AddScoreSubmit(req *fsipc.GsScoreSubmitRequest, resp *groovestats.ScoreSubmitResponse) {
+	tracker.mutex.Lock()
+
+	player, result := req.Player1, resp.Player1
+	for i := 0; i < 2; i++ {
+		if i == 1 {
+			player, result = req.Player2, resp.Player2
+		}
+		if player == nil || result == nil {
+			continue
+		}
+
+		if rpg := result.Rpg; rpg != nil {
+			earned := Progress{ProfileName: player.ProfileName}
+
+			if rpg.ScoreDelta != nil && *rpg.ScoreDelta > 0 {
+				earned.Scores = 1
+			}
+			if progress := rpg.Progress; progress != nil {
+				if earned.Scores == 0 && len(progress.StatImprovements)+len(progress.SkillImprovements) > 0 {
+					earned.Scores = 1
+				}
+				for _, quest := range progress.QuestsCompleted {
+					earned.Quests = append(earned.Quests, quest.Title)
+				}
+			}
+
+			tracker.add(rpg.Name, earned)
+		}
+
+		if itl := result.Itl; itl != nil {
+			earned := Progress{ProfileName: player.ProfileName}
+
+			if itl.PreviousPointTotal != nil {
+				earned.Points = itl.CurrentPointTotal - *itl.PreviousPointTotal
+			}
+			if earned.Points > 0 || itl.ScoreDelta != nil && *itl.ScoreDelta > 0 {
+				earned.Scores = 1
+			}
+			if progress := itl.Progress; progress != nil {
+				for _, quest := range progress.QuestsCompleted {
+					earned.Quests = append(earned.Quests, quest.Title)
+				}
+			}
+
+			tracker.add(itl.Name, earned)
+		}
+	}
+
+	updateCallback := tracker.updateCallback
+	tracker.mutex.Unlock()
+
+	updateCallback()
+}
+
+func (tracker *Tracker) add(eventName string, earned Progress) {
+	if eventName == "" || earned.Scores == 0 && earned.Points <= 0 && len(earned.Quests) == 0 {
+		return
+	}
+
+	// the RPG and ITL data may use the short name of the event
+	for _, event := range tracker.events {
+		if strings.EqualFold(event.Name, eventName) || strings.EqualFold(event.ShortName, eventName) {
+			eventName = event.Name
+			break
+		}
+	}
+
+	progress := tracker.progress[eventName]
+	for i := range progress {
+		if progress[i].ProfileName == earned.ProfileName {
+			progress[i].Scores += earned.Scores
+			progress[i].Points += earned.Points
+			progress[i].Quests = append(progress[i].Quests, earned.Quests...)
+			return
+		}
+	}
+
+	tracker.progress[eventName] = append(progress, earned)
+}
+
+// Events returns the active events, followed by the events that aren't
+// active anymore but had progress in this session.
+func (tracker *Tracker) Events() []Event {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	events := make([]Event, len(tracker.events))
+	copy(events, tracker.events)
+
+	active := make(map[string]bool)
+	for _, event := range events {
+		active[event.Name] = true
+	}
+
+	inactive := make([]string, 0)
+	for name := range tracker.progress {
+		if !active[name] {
+			inactive = append(inactive, name)
+		}
+	}
+	sort.Strings(inactive)
+
+	for _, name := range inactive {
+		events = append(events, Event{Name: name})
+	}
+
+	return events
+}
+
+// Progress returns the progress of the profiles in an event, in the order
+// they first earned some.
+func (tracker *Tracker) Progress(eventName string) []Progress {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	progress := make([]Progress, len(tracker.progress[eventName]))
+	for i, p := range tracker.progress[eventName] {
+		p.Quests = append([]string(nil), p.Quests...)
+		progress[i] = p
+	}
+
+	return progress
+}
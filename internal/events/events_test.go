@@ -0,0 +1,139 @@
+package events
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/GrooveStats/gslauncher/internal/fsipc"
+	"github.com/GrooveStats/gslauncher/internal/groovestats"
+)
+
+func newTestTracker(t *testing.T, activeEvents ...Event) *Tracker {
+	// Event has the json tags of the response
+	data, err := json.Marshal(map[string]interface{}{"activeEvents": activeEvents})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var newSession groovestats.NewSessionResponse
+	err = json.Unmarshal(data, &newSession)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tracker := NewTracker()
+	tracker.SetActiveEvents(&newSession)
+
+	return tracker
+}
+
+// submit adds a score of domp with the given response data of player 1.
+func submit(t *testing.T, tracker *Tracker, player1 string) {
+	var req fsipc.GsScoreSubmitRequest
+	err := json.Unmarshal([]byte(`{"player1": {"profileName": "domp"}}`), &req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var resp groovestats.ScoreSubmitResponse
+	err = json.Unmarshal([]byte(`{"player1": `+player1+`}`), &resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tracker.AddScoreSubmit(&req, &resp)
+}
+
+func TestShortName(t *testing.T) {
+	tracker := newTestTracker(t, Event{Name: "Stamina RPG 6", ShortName: "SRPG6"})
+
+	submit(t, tracker, `{"rpg": {"name": "srpg6", "scoreDelta": 12}}`)
+
+	expected := []Progress{{ProfileName: "domp", Scores: 1}}
+	if progress := tracker.Progress("Stamina RPG 6"); !reflect.DeepEqual(progress, expected) {
+		t.Errorf("unexpected progress: %+v", progress)
+	}
+
+	if events := tracker.Events(); len(events) != 1 {
+		t.Errorf("short name listed as another event: %+v", events)
+	}
+}
+
+func TestInactiveEvent(t *testing.T) {
+	tracker := newTestTracker(t, Event{Name: "ITL Online 2022", ShortName: "ITL2022", Url: "https://itl2022.groovestats.com"})
+
+	submit(t, tracker, `{"itl": {"name": "ITL Online 2022", "currentPointTotal": 1200, "previousPointTotal": 1150}}`)
+
+	if events := tracker.Events(); len(events) != 1 || !events[0].Active {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+
+	// the event ended, GrooveStats doesn't send it anymore
+	tracker.SetActiveEvents(&groovestats.NewSessionResponse{})
+
+	events := tracker.Events()
+	if len(events) != 1 || events[0].Name != "ITL Online 2022" || events[0].Url != "" || events[0].Active {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+
+	expected := []Progress{{ProfileName: "domp", Scores: 1, Points: 50}}
+	if progress := tracker.Progress("ITL Online 2022"); !reflect.DeepEqual(progress, expected) {
+		t.Errorf("unexpected progress: %+v", progress)
+	}
+
+	// progress for an event that was never active is kept as well
+	submit(t, tracker, `{"rpg": {"name": "Stamina RPG 7", "progress": {"questsCompleted": [{"title": "Quest 1"}]}}}`)
+
+	events = tracker.Events()
+	if len(events) != 2 || events[1].Name != "Stamina RPG 7" {
+		t.Errorf("unexpected events: %+v", events)
+	}
+}
+
+func TestStartSession(t *testing.T) {
+	tracker := newTestTracker(t, Event{Name: "Stamina RPG 6", ShortName: "SRPG6"})
+
+	submit(t, tracker, `{"rpg": {"name": "Stamina RPG 6", "scoreDelta": 12}}`)
+	tracker.SetActiveEvents(&groovestats.NewSessionResponse{})
+
+	updates := 0
+	tracker.SetUpdateCallback(func() { updates++ })
+
+	tracker.StartSession()
+
+	if updates != 1 {
+		t.Errorf("expected 1 update, got %d", updates)
+	}
+	if progress := tracker.Progress("Stamina RPG 6"); len(progress) != 0 {
+		t.Errorf("progress not reset: %+v", progress)
+	}
+
+	// the inactive event was only listed because of the progress
+	if events := tracker.Events(); len(events) != 0 {
+		t.Errorf("unexpected events: %+v", events)
+	}
+}
+
+func TestNoEventData(t *testing.T) {
+	tracker := newTestTracker(t, Event{Name: "Stamina RPG 6", ShortName: "SRPG6"})
+
+	updates := 0
+	tracker.SetUpdateCallback(func() { updates++ })
+
+	submit(t, tracker, `{"chartHash": "hash", "isRanked": true, "rpg": null, "itl": null}`)
+	submit(t, tracker, `{"chartHash": "hash", "isRanked": false}`)
+
+	// and a score that didn't improve anything
+	submit(t, tracker, `{"rpg": {"name": "Stamina RPG 6", "scoreDelta": 0, "progress": {}}}`)
+
+	if updates != 3 {
+		t.Errorf("expected 3 updates, got %d", updates)
+	}
+	if progress := tracker.Progress("Stamina RPG 6"); len(progress) != 0 {
+		t.Errorf("unexpected progress: %+v", progress)
+	}
+	if events := tracker.Events(); len(events) != 1 {
+		t.Errorf("unexpected events: %+v", events)
+	}
+}
@@ -0,0 +1,265 @@
+package fakegs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// wait for changes to settle before reloading
+const reloadDelay = 200 * time.Millisecond
+
+// fixtureDir holds the fixtures of an override directory. Files named like
+// the embedded fixtures, e.g. score-submit.json, replace them. Files in a
+// subdirectory named after a chart hash, e.g. <hash>/score-submit.json,
+// provide the player data for that chart.
+type fixtureDir struct {
+	dir      string
+	watcher  *fsnotify.Watcher
+	logger   *slog.Logger
+	shutdown chan struct{}
+	wg       sync.WaitGroup
+
+	mutex    sync.Mutex
+	fixtures map[string][]byte
+}
+
+func newFixtureDir(dir string, logger *slog.Logger) (*fixtureDir, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	fixtures := &fixtureDir{
+		dir:      dir,
+		watcher:  watcher,
+		logger:   logger,
+		shutdown: make(chan struct{}),
+	}
+
+	err = fixtures.load()
+	if err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	fixtures.wg.Add(1)
+	go fixtures.watch()
+
+	return fixtures, nil
+}
+
+func (fixtures *fixtureDir) Close() {
+	close(fixtures.shutdown)
+	fixtures.watcher.Close()
+	fixtures.wg.Wait()
+}
+
+// get returns the fixture with the given name, e.g. "score-submit.json" or
+// "<hash>/score-submit.json".
+func (fixtures *fixtureDir) get(name string) (object, bool) {
+	fixtures.mutex.Lock()
+	data, ok := fixtures.fixtures[name]
+	fixtures.mutex.Unlock()
+
+	if !ok {
+		return nil, false
+	}
+
+	// validated when loading
+	var fixture object
+	json.Unmarshal(data, &fixture)
+	return fixture, true
+}
+
+// load reads all fixtures and watches the directory and its subdirectories.
+// Invalid fixtures are skipped.
+func (fixtures *fixtureDir) load() error {
+	loaded := make(map[string][]byte)
+
+	err := filepath.WalkDir(fixtures.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(fixtures.dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if entry.IsDir() {
+			if strings.Count(rel, "/") > 0 {
+				return fs.SkipDir
+			}
+			return fixtures.watcher.Add(path)
+		}
+
+		if !strings.HasSuffix(rel, ".json") {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		var fixture object
+		err = json.Unmarshal(data, &fixture)
+		if err != nil || fixture == nil {
+			fixtures.logger.Warn("skipping invalid fixture", "file", rel, "err", err)
+			return nil
+		}
+
+		loaded[rel] = data
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load fixtures: %w", err)
+	}
+
+	fixtures.mutex.Lock()
+	fixtures.fixtures = loaded
+	fixtures.mutex.Unlock()
+
+	fixtures.logger.Info("loaded fixtures", "count", len(loaded), "dir", fixtures.dir)
+
+	return nil
+}
+
+func (fixtures *fixtureDir) watch() {
+	defer fixtures.wg.Done()
+
+	var reload <-chan time.Time
+
+	for {
+		select {
+		case _, ok := <-fixtures.watcher.Events:
+			if !ok {
+				return
+			}
+
+			if reload == nil {
+				reload = time.After(reloadDelay)
+			}
+		case err, ok := <-fixtures.watcher.Errors:
+			if !ok {
+				return
+			}
+
+			fixtures.logger.Warn("fsnotify error", "err", err)
+		case <-reload:
+			reload = nil
+
+			err := fixtures.load()
+			if err != nil {
+				fixtures.logger.Warn("failed to reload fixtures", "err", err)
+			}
+		case <-fixtures.shutdown:
+			return
+		}
+	}
+}
+
+// LoadFixtures replaces the embedded fixtures with the ones in dir, see
+// fixtureDir. The directory is watched for changes. An empty dir goes back
+// to the embedded fixtures.
+func (server *Server) LoadFixtures(dir string) error {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if server.fixtures != nil {
+		if server.fixtures.dir == dir {
+			return nil
+		}
+
+		server.fixtures.Close()
+		server.fixtures = nil
+	}
+
+	if dir == "" {
+		return nil
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return errors.New("not a directory: " + dir)
+	}
+
+	fixtures, err := newFixtureDir(dir, server.logger)
+	if err != nil {
+		return err
+	}
+
+	server.fixtures = fixtures
+	return nil
+}
+
+// Close stops watching the fixture directory.
+func (server *Server) Close() {
+	server.LoadFixtures("")
+}
+
+// loadFixture returns the fixture from the override directory if there is
+// one, the embedded one otherwise.
+func (server *Server) loadFixture(filename string) (object, error) {
+	server.mutex.Lock()
+	fixtures := server.fixtures
+	server.mutex.Unlock()
+
+	if fixtures != nil {
+		fixture, ok := fixtures.get(filename)
+		if ok {
+			return fixture, nil
+		}
+	}
+
+	return loadFixture(filename)
+}
+
+// applyChartFixtures replaces the players with the ones from the fixtures of
+// their charts. These are used as they are, the scenario isn't applied to
+// them.
+func (server *Server) applyChartFixtures(r *http.Request, filename string, response object) {
+	server.mutex.Lock()
+	fixtures := server.fixtures
+	server.mutex.Unlock()
+
+	if fixtures == nil {
+		return
+	}
+
+	query := r.URL.Query()
+
+	for i, key := range []string{"player1", "player2"} {
+		chartHash := query.Get(fmt.Sprintf("chartHashP%d", i+1))
+		if chartHash == "" || strings.ContainsAny(chartHash, "/\\.") {
+			continue
+		}
+
+		fixture, ok := fixtures.get(chartHash + "/" + filename)
+		if !ok {
+			continue
+		}
+
+		// use the same player if the fixture has both, player1 otherwise
+		player, ok := fixture[key]
+		if !ok {
+			player = fixture["player1"]
+		}
+		response[key] = player
+	}
+}
@@ -0,0 +1,102 @@
+package fakegs
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// Step describes how the server answers requests to an endpoint.
+type Step struct {
+	// wait this long before answering
+	DelayMs int `json:"delayMs"`
+
+	// answer with this status code instead of the fixture, 0 for 200
+	Status int `json:"status"`
+
+	// answer with a GrooveStats error body, with the status code above
+	Error string `json:"error"`
+
+	// value of the Retry-After header
+	RetryAfter string `json:"retryAfter"`
+
+	// close the connection without answering
+	Drop bool `json:"drop"`
+
+	// number of requests this step applies to, defaults to 1
+	Repeat int `json:"repeat"`
+}
+
+// Scenario controls the answers of the fake server. Steps are applied to the
+// requests of an endpoint in order, the last one stays in effect for all
+// further requests. Endpoints without steps always succeed.
+type Scenario struct {
+	// which events are active
+	Rpg bool `json:"rpg"`
+	Itl bool `json:"itl"`
+
+	// "OK", "UNSUPPORTED_CHART_HASH" or "MAINTENANCE"
+	NewSessionResult string `json:"newSessionResult"`
+
+	// "score-added", "improved", "score-not-improved" or
+	// "chart-not-ranked"
+	SubmitResult string `json:"submitResult"`
+
+	// quests completed with every score submission, in the format of the
+	// GrooveStats API
+	RpgQuests []json.RawMessage `json:"rpgQuests"`
+	ItlQuests []json.RawMessage `json:"itlQuests"`
+
+	// ITL points gained with every score submission. The totals are kept
+	// per api key.
+	ItlPointsGained int `json:"itlPointsGained"`
+
+	// steps by endpoint name, e.g. "score-submit"
+	Endpoints map[string][]Step `json:"endpoints"`
+}
+
+func DefaultScenario() Scenario {
+	return Scenario{
+		Rpg:              true,
+		Itl:              true,
+		NewSessionResult: "OK",
+		SubmitResult:     "score-added",
+		ItlPointsGained:  1,
+		Endpoints:        make(map[string][]Step),
+	}
+}
+
+// LoadScenario reads a scenario file. Fields missing in the file keep their
+// default values.
+func LoadScenario(filename string) (Scenario, error) {
+	scenario := DefaultScenario()
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return scenario, err
+	}
+
+	err = json.Unmarshal(data, &scenario)
+	return scenario, err
+}
+
+// step returns the step for the given request to an endpoint (starting at 0).
+func (scenario *Scenario) step(endpoint string, n int) Step {
+	steps := scenario.Endpoints[endpoint]
+	if len(steps) == 0 {
+		return Step{}
+	}
+
+	for _, step := range steps {
+		repeat := step.Repeat
+		if repeat < 1 {
+			repeat = 1
+		}
+
+		if n < repeat {
+			return step
+		}
+		n -= repeat
+	}
+
+	return steps[len(steps)-1]
+}
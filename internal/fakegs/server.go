@@ -0,0 +1,378 @@
+package fakegs
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GrooveStats/gslauncher/internal/logging"
+)
+
+//go:embed fixtures/*.json
+var fixtures embed.FS
+
+type object = map[string]interface{}
+
+type handlerFunc func(r *http.Request, scenario *Scenario) (object, error)
+
+// Server is a fake GrooveStats API. It answers with the fixtures, modified
+// according to the scenario.
+type Server struct {
+	mutex    sync.Mutex
+	scenario Scenario
+	handlers map[string]handlerFunc
+	requests map[string]int
+	logger   *slog.Logger
+	fixtures *fixtureDir
+
+	// ITL points gained by api key
+	itlPoints map[string]int
+}
+
+func NewServer(scenario Scenario) *Server {
+	server := &Server{
+		scenario:  scenario,
+		requests:  make(map[string]int),
+		itlPoints: make(map[string]int),
+		logger:    logging.New("FakeGS"),
+	}
+
+	server.handlers = map[string]handlerFunc{
+		"new-session":         server.newSession,
+		"player-scores":       server.playerScores,
+		"player-leaderboards": server.playerLeaderboards,
+		"score-submit":        server.scoreSubmit,
+	}
+
+	return server
+}
+
+// SetScenario replaces the scenario and starts over with the first step of
+// every endpoint.
+func (server *Server) SetScenario(scenario Scenario) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.scenario = scenario
+	server.requests = make(map[string]int)
+}
+
+func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	endpoint := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/"), ".php")
+
+	handler, ok := server.handlers[endpoint]
+	if !ok {
+		writeJson(w, http.StatusNotFound, object{"error": "not found"})
+		return
+	}
+
+	server.mutex.Lock()
+	scenario := server.scenario
+	step := scenario.step(endpoint, server.requests[endpoint])
+	server.requests[endpoint]++
+	server.mutex.Unlock()
+
+	server.logger.Info("request", "method", r.Method, "url", r.URL.String())
+
+	if step.DelayMs > 0 {
+		select {
+		case <-time.After(time.Duration(step.DelayMs) * time.Millisecond):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if step.Drop {
+		hijacker, ok := w.(http.Hijacker)
+		if ok {
+			conn, _, err := hijacker.Hijack()
+			if err == nil {
+				conn.Close()
+				return
+			}
+		}
+	}
+
+	if step.RetryAfter != "" {
+		w.Header().Set("Retry-After", step.RetryAfter)
+	}
+
+	status := step.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	// GrooveStats sometimes answers errors with status code 200
+	if step.Error != "" {
+		writeJson(w, status, object{"error": step.Error})
+		return
+	}
+	if status != http.StatusOK {
+		writeJson(w, status, object{"message": http.StatusText(status)})
+		return
+	}
+
+	response, err := handler(r, &scenario)
+	if err != nil {
+		writeJson(w, http.StatusBadRequest, object{"error": err.Error()})
+		return
+	}
+
+	writeJson(w, http.StatusOK, response)
+}
+
+func (server *Server) newSession(r *http.Request, scenario *Scenario) (object, error) {
+	response, err := server.loadFixture("new-session.json")
+	if err != nil {
+		return nil, err
+	}
+
+	// the first event is the RPG, the last one ITL
+	events, _ := response["activeEvents"].([]interface{})
+	if !scenario.Itl && len(events) > 0 {
+		events = events[:len(events)-1]
+	}
+	if !scenario.Rpg && len(events) > 0 {
+		events = events[1:]
+	}
+	response["activeEvents"] = events
+
+	response["servicesResult"] = scenario.NewSessionResult
+	if scenario.NewSessionResult != "OK" {
+		response["servicesAllowed"] = object{
+			"scoreSubmit":        false,
+			"playerScores":       false,
+			"playerLeaderboards": false,
+		}
+	}
+
+	return response, nil
+}
+
+func (server *Server) playerScores(r *http.Request, scenario *Scenario) (object, error) {
+	response, err := server.loadFixture("player-scores.json")
+	if err != nil {
+		return nil, err
+	}
+
+	server.applyChartFixtures(r, "player-scores.json", response)
+	setPlayers(r, response)
+
+	return response, nil
+}
+
+func (server *Server) playerLeaderboards(r *http.Request, scenario *Scenario) (object, error) {
+	response, err := server.loadFixture("player-leaderboards.json")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range []string{"player1", "player2"} {
+		player := getObject(response, key)
+		if player == nil {
+			continue
+		}
+
+		truncateLeaderboards(r, player)
+		removeEvents(scenario, player)
+	}
+
+	server.applyChartFixtures(r, "player-leaderboards.json", response)
+	setPlayers(r, response)
+
+	return response, nil
+}
+
+func (server *Server) scoreSubmit(r *http.Request, scenario *Scenario) (object, error) {
+	var body object
+	err := json.NewDecoder(r.Body).Decode(&body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid body: %w", err)
+	}
+
+	response, err := server.loadFixture("score-submit.json")
+	if err != nil {
+		return nil, err
+	}
+
+	for i, key := range []string{"player1", "player2"} {
+		player := getObject(response, key)
+		if player == nil {
+			continue
+		}
+
+		truncateLeaderboards(r, player)
+
+		err := applySubmitResult(scenario.SubmitResult, player)
+		if err != nil {
+			return nil, err
+		}
+
+		setQuests(getObject(player, "rpg"), scenario.RpgQuests)
+		setQuests(getObject(player, "itl"), scenario.ItlQuests)
+
+		apiKey := r.Header.Get(fmt.Sprintf("x-api-key-player-%d", i+1))
+		server.addItlPoints(getObject(player, "itl"), apiKey, scenario.ItlPointsGained)
+
+		removeEvents(scenario, player)
+	}
+
+	server.applyChartFixtures(r, "score-submit.json", response)
+	setPlayers(r, response)
+
+	return response, nil
+}
+
+// addItlPoints moves the point totals of the fixture by the points the api
+// key gained so far.
+func (server *Server) addItlPoints(itl object, apiKey string, gained int) {
+	if itl == nil {
+		return
+	}
+
+	server.mutex.Lock()
+	previous := server.itlPoints[apiKey]
+	server.itlPoints[apiKey] = previous + gained
+	server.mutex.Unlock()
+
+	for _, name := range []string{"PointTotal", "RankingPointTotal"} {
+		base, _ := itl["previous"+name].(float64)
+		itl["previous"+name] = int(base) + previous
+		itl["current"+name] = int(base) + previous + gained
+	}
+}
+
+func applySubmitResult(result string, player object) error {
+	rpg := getObject(player, "rpg")
+
+	switch result {
+	case "score-added":
+		player["result"] = "score-added"
+		delete(player, "scoreDelta")
+		if rpg != nil {
+			rpg["result"] = "score-added"
+			delete(rpg, "scoreDelta")
+			delete(rpg, "rateDelta")
+		}
+	case "improved":
+		player["result"] = "improved"
+		if rpg != nil {
+			rpg["result"] = "improved"
+		}
+	case "score-not-improved":
+		player["result"] = "score-not-improved"
+		player["scoreDelta"] = 0
+		if rpg != nil {
+			rpg["result"] = "score-not-improved"
+			rpg["scoreDelta"] = 0
+			rpg["rateDelta"] = 0
+		}
+	case "chart-not-ranked":
+		player["isRanked"] = false
+		delete(player, "result")
+		delete(player, "scoreDelta")
+		player["gsLeaderboard"] = nil
+		player["rpg"] = nil
+	default:
+		return fmt.Errorf("unknown submit result %q", result)
+	}
+
+	return nil
+}
+
+func setQuests(event object, quests []json.RawMessage) {
+	if event == nil || len(quests) == 0 {
+		return
+	}
+
+	progress := getObject(event, "progress")
+	if progress == nil {
+		progress = object{}
+		event["progress"] = progress
+	}
+
+	progress["questsCompleted"] = quests
+}
+
+func removeEvents(scenario *Scenario, player object) {
+	if !scenario.Rpg {
+		player["rpg"] = nil
+	}
+	if !scenario.Itl {
+		player["itl"] = nil
+	}
+}
+
+// setPlayers removes the players that weren't part of the request and sets
+// the chart hashes of the others.
+func setPlayers(r *http.Request, response object) {
+	query := r.URL.Query()
+
+	for i, key := range []string{"player1", "player2"} {
+		chartHash := query.Get(fmt.Sprintf("chartHashP%d", i+1))
+		player := getObject(response, key)
+
+		if chartHash == "" || player == nil {
+			response[key] = nil
+			continue
+		}
+
+		player["chartHash"] = chartHash
+	}
+}
+
+func truncateLeaderboards(r *http.Request, player object) {
+	n, err := strconv.Atoi(r.URL.Query().Get("maxLeaderboardResults"))
+	if err != nil || n < 0 {
+		return
+	}
+
+	truncate(player, "gsLeaderboard", n)
+	truncate(getObject(player, "rpg"), "rpgLeaderboard", n)
+	truncate(getObject(player, "itl"), "itlLeaderboard", n)
+}
+
+func truncate(obj object, key string, n int) {
+	if obj == nil {
+		return
+	}
+
+	list, ok := obj[key].([]interface{})
+	if ok && len(list) > n {
+		obj[key] = list[:n]
+	}
+}
+
+func getObject(obj object, key string) object {
+	value, _ := obj[key].(map[string]interface{})
+	return value
+}
+
+func loadFixture(filename string) (object, error) {
+	data, err := fixtures.ReadFile("fixtures/" + filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var response object
+	err = json.Unmarshal(data, &response)
+	return response, err
+}
+
+func writeJson(w http.ResponseWriter, status int, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
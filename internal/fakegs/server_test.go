@@ -0,0 +1,207 @@
+package fakegs
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const submitBody = `{
+	"player1": {"score": 9876, "rate": 100}
+}`
+
+func newTestServer(t *testing.T, scenario Scenario) *httptest.Server {
+	server := httptest.NewServer(NewServer(scenario))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func get(t *testing.T, url string) (*http.Response, object) {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return decode(t, resp)
+}
+
+func submit(t *testing.T, url string, apiKey string) object {
+	req, err := http.NewRequest("POST", url+"/score-submit.php?chartHashP1=abc&maxLeaderboardResults=3", strings.NewReader(submitBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("x-api-key-player-1", apiKey)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, response := decode(t, resp)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %d", resp.StatusCode)
+	}
+
+	return response
+}
+
+func decode(t *testing.T, resp *http.Response) (*http.Response, object) {
+	defer resp.Body.Close()
+
+	var response object
+	err := json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return resp, response
+}
+
+func TestServer(t *testing.T) {
+	scenario := DefaultScenario()
+	scenario.Rpg = false
+	scenario.NewSessionResult = "MAINTENANCE"
+	scenario.ItlPointsGained = 5
+	scenario.ItlQuests = []json.RawMessage{json.RawMessage(`{"title": "Test Quest", "rewards": []}`)}
+
+	server := newTestServer(t, scenario)
+
+	_, response := get(t, server.URL+"/new-session.php?chartHashVersion=3")
+	if events := response["activeEvents"].([]interface{}); len(events) != 1 {
+		t.Fatalf("expected one event, got %d", len(events))
+	}
+	if getObject(response, "servicesAllowed")["scoreSubmit"] != false {
+		t.Fatal("score submit allowed during maintenance")
+	}
+
+	_, response = get(t, server.URL+"/player-leaderboards.php?chartHashP2=abc&maxLeaderboardResults=2")
+	if response["player1"] != nil {
+		t.Fatal("player 1 not removed")
+	}
+	player := getObject(response, "player2")
+	if player["chartHash"] != "abc" || player["rpg"] != nil {
+		t.Fatalf("unexpected player %v", player)
+	}
+	if leaderboard := player["gsLeaderboard"].([]interface{}); len(leaderboard) != 2 {
+		t.Fatalf("leaderboard not truncated: %d entries", len(leaderboard))
+	}
+
+	// the totals keep growing per api key
+	expected := []float64{30005, 30010, 30005}
+	for i, apiKey := range []string{"one", "one", "two"} {
+		response := submit(t, server.URL, apiKey)
+		itl := getObject(getObject(response, "player1"), "itl")
+
+		if itl["currentPointTotal"] != expected[i] || itl["previousPointTotal"] != expected[i]-5 {
+			t.Fatalf("submit %d: unexpected itl totals %v -> %v", i, itl["previousPointTotal"], itl["currentPointTotal"])
+		}
+
+		quests := getObject(itl, "progress")["questsCompleted"].([]interface{})
+		if len(quests) != 1 || quests[0].(map[string]interface{})["title"] != "Test Quest" {
+			t.Fatalf("unexpected quests %v", quests)
+		}
+	}
+}
+
+func TestServerSteps(t *testing.T) {
+	scenario := DefaultScenario()
+	scenario.Endpoints["player-scores"] = []Step{
+		{Status: http.StatusServiceUnavailable, RetryAfter: "3", Repeat: 2},
+		{Error: "Invalid API key"},
+		{},
+	}
+	scenario.Endpoints["new-session"] = []Step{{Drop: true}}
+
+	server := newTestServer(t, scenario)
+	url := server.URL + "/player-scores.php?chartHashP1=abc"
+
+	for i := 0; i < 2; i++ {
+		resp, _ := get(t, url)
+		if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") != "3" {
+			t.Fatalf("request %d: unexpected status %d", i, resp.StatusCode)
+		}
+	}
+
+	resp, response := get(t, url)
+	if resp.StatusCode != http.StatusOK || response["error"] != "Invalid API key" {
+		t.Fatalf("unexpected error response %d %v", resp.StatusCode, response)
+	}
+
+	// the last step stays in effect
+	for i := 0; i < 2; i++ {
+		_, response = get(t, url)
+		if getObject(response, "player1")["chartHash"] != "abc" {
+			t.Fatalf("unexpected response %v", response)
+		}
+	}
+
+	_, err := http.Get(server.URL + "/new-session.php")
+	if err == nil {
+		t.Fatal("connection not dropped")
+	}
+}
+
+func TestFixtureDir(t *testing.T) {
+	dir := t.TempDir()
+
+	err := os.WriteFile(filepath.Join(dir, "new-session.json"), []byte(`{"activeEvents": [], "servicesAllowed": {}}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake := NewServer(DefaultScenario())
+	err = fake.LoadFixtures(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(fake.Close)
+
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	_, response := get(t, server.URL+"/new-session.php")
+	if events := response["activeEvents"].([]interface{}); len(events) != 0 {
+		t.Fatalf("override not used, got %d events", len(events))
+	}
+
+	// picked up while running
+	err = os.Mkdir(filepath.Join(dir, "abc"), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * reloadDelay)
+
+	err = os.WriteFile(filepath.Join(dir, "abc", "player-scores.json"), []byte(`{"player1": {"score": 42}}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	url := server.URL + "/player-scores.php?chartHashP1=def&chartHashP2=abc"
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, response = get(t, url)
+		if getObject(response, "player2")["score"] == float64(42) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("chart fixture not loaded")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	player := getObject(response, "player2")
+	if player["chartHash"] != "abc" {
+		t.Fatalf("unexpected player %v", player)
+	}
+
+	// other charts still use the built-in fixture
+	if getObject(response, "player1")["score"] == float64(42) {
+		t.Fatal("chart fixture used for another chart")
+	}
+}
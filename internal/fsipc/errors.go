@@ -0,0 +1,80 @@
+package fsipc
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	ErrorMalformedJson       = "malformed-json"
+	ErrorMissingAction       = "missing-action"
+	ErrorUnknownAction       = "unknown-action"
+	ErrorValidation          = "validation-failed"
+	ErrorUnsupportedProtocol = "unsupported-protocol"
+)
+
+// RequestError describes why a request was rejected. It is sent back to the
+// theme as part of an InvalidResponse.
+type RequestError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Field   string `json:"field,omitempty"`
+
+	// the range of protocols supported by the launcher, only set for
+	// ErrorUnsupportedProtocol
+	Protocol    int `json:"protocol,omitempty"`
+	MinProtocol int `json:"minProtocol,omitempty"`
+}
+
+func (e *RequestError) Error() string {
+	return e.Message
+}
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+
+	// report field names the way they appear in the request
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	return v
+}
+
+func newValidationError(err error) *RequestError {
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if !ok || len(validationErrors) == 0 {
+		return &RequestError{
+			Code:    ErrorValidation,
+			Message: err.Error(),
+		}
+	}
+
+	fieldError := validationErrors[0]
+
+	// strip the name of the request struct
+	field := fieldError.Namespace()
+	if idx := strings.IndexByte(field, '.'); idx != -1 {
+		field = field[idx+1:]
+	}
+
+	message := fmt.Sprintf("%s failed the %s validation", field, fieldError.Tag())
+	if fieldError.Param() != "" {
+		message = fmt.Sprintf("%s failed the %s=%s validation", field, fieldError.Tag(), fieldError.Param())
+	}
+
+	return &RequestError{
+		Code:    ErrorValidation,
+		Message: message,
+		Field:   field,
+	}
+}
@@ -1,13 +1,14 @@
 package fsipc
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io/fs"
-	"log"
+	"log/slog"
+	"net/http"
 	"os"
 	"path/filepath"
-	"regexp"
 	"runtime"
 	"strings"
 	"sync"
@@ -15,26 +16,55 @@ import (
 	"time"
 
 	"github.com/fsnotify/fsnotify"
-	"github.com/go-playground/validator/v10"
 
+	"github.com/GrooveStats/gslauncher/internal/logging"
+	"github.com/GrooveStats/gslauncher/internal/metrics"
 	"github.com/GrooveStats/gslauncher/internal/settings"
 )
 
+type queuedRequest struct {
+	id      string
+	action  *Action
+	request interface{}
+
+	// ids of identical older requests that get the same response
+	coalesced []string
+}
+
 type FsIpc struct {
-	Requests                     chan interface{}
-	GsPlayerScoresRequests       chan interface{}
-	GsPlayerLeaderboardsRequests chan interface{}
-	RootDir                      string
+	RootDir string
+
+	registry     *Registry
+	sharedQueue  chan queuedRequest
+	latestQueues map[string]chan queuedRequest
 
 	requestDir  string
 	responseDir string
+	watchMode   settings.WatchMode
 	watcher     *fsnotify.Watcher
+	polling     bool
+	handled     map[string]time.Time
+	server      *http.Server
 	shutdown    chan struct{}
-	logger      *log.Logger
+	logger      *slog.Logger
 	wg          sync.WaitGroup
+
+	// guards handled, which the request goroutines update
+	handledMutex sync.Mutex
+
+	// guards sending to the request queues
+	mutex  sync.RWMutex
+	closed bool
+
+	netMutex   sync.Mutex
+	netPending map[string]func(data []byte, err error)
+
+	recorder recorder
 }
 
-func New(rootDir string) (*FsIpc, error) {
+// New starts watching for requests in rootDir. Requests are handled by the
+// actions in the registry, which must not be modified afterwards.
+func New(rootDir string, registry *Registry) (*FsIpc, error) {
 	info, err := os.Stat(rootDir)
 	if os.IsNotExist(err) || !info.IsDir() {
 		return nil, fmt.Errorf("root directory doesn't exist")
@@ -60,29 +90,41 @@ func New(rootDir string) (*FsIpc, error) {
 		return nil, err
 	}
 
-	watcher, err := fsnotify.NewWatcher()
+	logger := logging.New("IPC")
+
+	fsipc := FsIpc{
+		RootDir:      rootDir,
+		registry:     registry,
+		sharedQueue:  make(chan queuedRequest, 5),
+		latestQueues: make(map[string]chan queuedRequest),
+		requestDir:   requestDir,
+		responseDir:  responseDir,
+		watchMode:    settings.Get().IpcWatchMode,
+		handled:      make(map[string]time.Time),
+		shutdown:     make(chan struct{}),
+		logger:       logger,
+		netPending:   make(map[string]func([]byte, error)),
+	}
+
+	err = fsipc.startWatcher()
 	if err != nil {
 		return nil, err
 	}
 
-	logger := log.New(log.Writer(), "[IPC] ", log.LstdFlags|log.Lmsgprefix)
+	fsipc.wg.Add(1)
+	go fsipc.worker(fsipc.sharedQueue)
 
-	fsipc := FsIpc{
-		Requests:                     make(chan interface{}, 5),
-		GsPlayerScoresRequests:       make(chan interface{}, 1),
-		GsPlayerLeaderboardsRequests: make(chan interface{}, 1),
-		RootDir:                      rootDir,
-		requestDir:                   requestDir,
-		responseDir:                  responseDir,
-		watcher:                      watcher,
-		shutdown:                     make(chan struct{}),
-		logger:                       logger,
-	}
-
-	err = fsipc.watcher.Add(fsipc.requestDir)
-	if err != nil {
-		fsipc.watcher.Close()
-		return nil, err
+	for _, name := range registry.Names() {
+		action, _ := registry.Lookup(name)
+		if action.Policy != PolicyLatestOnly {
+			continue
+		}
+
+		queue := make(chan queuedRequest, 1)
+		fsipc.latestQueues[name] = queue
+
+		fsipc.wg.Add(1)
+		go fsipc.worker(queue)
 	}
 
 	fsipc.wg.Add(1)
@@ -91,51 +133,47 @@ func New(rootDir string) (*FsIpc, error) {
 	return &fsipc, nil
 }
 
+// Close stops accepting requests and waits until the pending ones are
+// handled.
 func (fsipc *FsIpc) Close() error {
 	close(fsipc.shutdown)
+	fsipc.closeServer()
+
+	fsipc.mutex.Lock()
+	fsipc.closed = true
+	close(fsipc.sharedQueue)
+	for _, queue := range fsipc.latestQueues {
+		close(queue)
+	}
+	fsipc.mutex.Unlock()
+
 	fsipc.wg.Wait()
 
-	close(fsipc.Requests)
-	close(fsipc.GsPlayerScoresRequests)
-	close(fsipc.GsPlayerLeaderboardsRequests)
+	err := fsipc.stopRecording()
+	if err != nil {
+		fsipc.logger.Error("failed to close transcript", "err", err)
+	}
 
-	err := os.RemoveAll(fsipc.requestDir)
+	err = fsipc.removeStatus()
 	if err != nil {
 		return err
 	}
 
-	err = os.RemoveAll(fsipc.responseDir)
+	err = os.RemoveAll(fsipc.requestDir)
 	if err != nil {
 		return err
 	}
 
-	return fsipc.watcher.Close()
-}
-
-func (fsipc *FsIpc) loop() {
-	defer fsipc.wg.Done()
-
-	for {
-		select {
-		case event, ok := <-fsipc.watcher.Events:
-			if !ok {
-				return
-			}
-
-			if event.Op&fsnotify.Create == fsnotify.Create {
-				fsipc.wg.Add(1)
-				go fsipc.handleFile(event.Name)
-			}
-		case err, ok := <-fsipc.watcher.Errors:
-			if !ok {
-				return
-			}
+	err = os.RemoveAll(fsipc.responseDir)
+	if err != nil {
+		return err
+	}
 
-			fsipc.logger.Printf("fsnotify error: %v", err)
-		case <-fsipc.shutdown:
-			return
-		}
+	if fsipc.watcher == nil {
+		return nil
 	}
+
+	return fsipc.watcher.Close()
 }
 
 func readFilePatient(filename string) ([]byte, error) {
@@ -164,10 +202,15 @@ func readFilePatient(filename string) ([]byte, error) {
 }
 
 func (fsipc *FsIpc) logRequest(id string, data []byte) {
-	re := regexp.MustCompile(`"apiKey":\s*"[^"]*"`)
-	data = re.ReplaceAllLiteral(data, []byte(`"apiKey":"<redacted>"`))
+	// the api keys and profile names of the players are redacted from
+	// now on, wherever they show up
+	logging.AddSecrets(data)
 
-	fsipc.logger.Printf("received request %s: %s", id, data)
+	if json.Valid(data) {
+		fsipc.logger.Info("received request", "id", id, "data", json.RawMessage(data))
+	} else {
+		fsipc.logger.Info("received request", "id", id, "data", string(data))
+	}
 }
 
 func (fsipc *FsIpc) handleFile(filename string) {
@@ -182,7 +225,8 @@ func (fsipc *FsIpc) handleFile(filename string) {
 
 	info, err := os.Stat(filename)
 	if err != nil {
-		fsipc.logger.Printf("failed to stat %s: %v", basename, err)
+		fsipc.logger.Warn("failed to stat request", "file", basename, "err", err)
+		fsipc.forget(basename)
 		return
 	}
 
@@ -192,96 +236,249 @@ func (fsipc *FsIpc) handleFile(filename string) {
 
 	// SM only waits up to one minute for a reply, so if the request is too
 	// old, just discard it.
-	if info.ModTime().Add(time.Minute).Before(time.Now()) {
-		fsipc.logger.Printf("discarding stale request: %s", id)
-		err = os.Remove(filename)
-		if err != nil {
-			fsipc.logger.Printf("failed to delete %s: %v", basename, err)
-		}
+	if info.ModTime().Add(staleRequestAge).Before(time.Now()) {
+		fsipc.logger.Warn("discarding stale request", "id", id)
+		fsipc.removeRequestFile(filename)
 		return
 	}
 
 	data, err := readFilePatient(filename)
 	if err != nil {
-		fsipc.logger.Printf("failed to read %s: %v", basename, err)
+		// tried again on the next scan
+		fsipc.logger.Warn("failed to read request", "file", basename, "err", err)
+		fsipc.forget(basename)
 		return
 	}
 
+	err = fsipc.handleRequest(id, data)
+	if err != nil {
+		fsipc.logger.Warn("invalid request", "id", id, "err", err)
+
+		requestError, ok := err.(*RequestError)
+		if !ok {
+			return
+		}
+
+		err = fsipc.WriteResponse(id, NewInvalidResponse(requestError))
+		if err != nil {
+			fsipc.logger.Error("failed to write response", "id", id, "err", err)
+		}
+	}
+
+	fsipc.removeRequestFile(filename)
+}
+
+// removeRequestFile deletes a request file. If that fails, the file stays
+// marked as handled until it is stale.
+func (fsipc *FsIpc) removeRequestFile(filename string) {
+	basename := filepath.Base(filename)
+
+	err := os.Remove(filename)
+	if err != nil {
+		fsipc.logger.Warn("failed to delete request", "file", basename, "err", err)
+		return
+	}
+
+	fsipc.forget(basename)
+}
+
+// handleRequest parses and validates a request and passes it on to the
+// matching request channel. It is shared by all transports.
+func (fsipc *FsIpc) handleRequest(id string, data []byte) error {
+	fsipc.record(TranscriptRequest, id, data)
+
+	err := fsipc.parseRequest(id, data)
+	if requestError, ok := err.(*RequestError); ok {
+		response, _ := json.Marshal(NewInvalidResponse(requestError))
+		fsipc.record(TranscriptResponse, id, response)
+	}
+
+	return err
+}
+
+func (fsipc *FsIpc) parseRequest(id string, data []byte) error {
 	var base struct {
 		Action string `json:"action"`
 	}
 
-	err = json.Unmarshal(data, &base)
+	err := json.Unmarshal(data, &base)
 	if err != nil {
-		fsipc.logger.Printf("failed to unmarshal request %s: %v", id, err)
-		return
+		return &RequestError{
+			Code:    ErrorMalformedJson,
+			Message: err.Error(),
+		}
 	}
 
-	var request interface{}
-
-	switch base.Action {
-	case "ping":
-		request = &PingRequest{Id: id}
-	case "groovestats/new-session":
-		request = &GsNewSessionRequest{Id: id}
-	case "groovestats/player-scores":
-		request = &GsPlayerScoresRequest{Id: id}
-	case "groovestats/player-leaderboards":
-		request = &GsPlayerLeaderboardsRequest{Id: id}
-	case "groovestats/score-submit":
-		request = &GsScoreSubmitRequest{Id: id}
-	case "":
-		fsipc.logger.Printf("invalid request %s: missing action", id)
-		return
-	default:
-		fsipc.logger.Printf("invalid request %s: unknown action %s", id, base.Action)
-		return
+	if base.Action == "" {
+		return &RequestError{
+			Code:    ErrorMissingAction,
+			Field:   "action",
+			Message: "missing action",
+		}
+	}
+
+	action, ok := fsipc.registry.Lookup(base.Action)
+	if !ok {
+		return &RequestError{
+			Code:    ErrorUnknownAction,
+			Field:   "action",
+			Message: fmt.Sprintf("unknown action %s", base.Action),
+		}
 	}
 
 	fsipc.logRequest(id, data)
 
+	request := action.NewRequest(id)
+
 	err = json.Unmarshal(data, request)
 	if err != nil {
-		fsipc.logger.Printf("failed to unmarshal request %s: %v", id, err)
-		return
+		requestError := &RequestError{
+			Code:    ErrorMalformedJson,
+			Message: err.Error(),
+		}
+
+		if typeError, ok := err.(*json.UnmarshalTypeError); ok {
+			requestError.Code = ErrorValidation
+			requestError.Field = typeError.Field
+		}
+
+		return requestError
 	}
 
-	validate := validator.New()
 	err = validate.Struct(request)
 	if err != nil {
-		fsipc.logger.Printf("invalid request %s: %v", id, err)
-		return
+		return newValidationError(err)
 	}
 
-	switch req := request.(type) {
-	case *GsPlayerScoresRequest:
-		// empty the buffer
-		select {
-		case <-fsipc.GsPlayerScoresRequests:
-			// do nothing
-		default:
-			// do nothing
+	if action.Validate != nil {
+		err = action.Validate(request)
+		if err != nil {
+			if requestError, ok := err.(*RequestError); ok {
+				return requestError
+			}
+
+			return &RequestError{
+				Code:    ErrorValidation,
+				Message: err.Error(),
+			}
 		}
-		fsipc.GsPlayerScoresRequests <- req
-	case *GsPlayerLeaderboardsRequest:
+	}
+
+	return fsipc.enqueue(queuedRequest{
+		id:      id,
+		action:  action,
+		request: request,
+	})
+}
+
+func (fsipc *FsIpc) enqueue(req queuedRequest) error {
+	fsipc.mutex.RLock()
+	defer fsipc.mutex.RUnlock()
+
+	if fsipc.closed {
+		return fmt.Errorf("shutting down")
+	}
+
+	metrics.IpcRequests.With(req.action.Name).Inc()
+
+	switch req.action.Policy {
+	case PolicyLatestOnly:
+		queue := fsipc.latestQueues[req.action.Name]
+
 		// empty the buffer
 		select {
-		case <-fsipc.GsPlayerLeaderboardsRequests:
-			// do nothing
+		case old := <-queue:
+			metrics.IpcQueueDepth.Dec()
+
+			if sameRequest(old.request, req.request) {
+				// the theme asked again, e.g. after scrolling
+				// back and forth, answer all of them at once
+				req.coalesced = append(old.coalesced, old.id)
+				metrics.IpcCoalesced.With(req.action.Name).Inc()
+			} else {
+				fsipc.supersede(old.id)
+				for _, id := range old.coalesced {
+					fsipc.supersede(id)
+				}
+			}
 		default:
 			// do nothing
 		}
-		fsipc.GsPlayerLeaderboardsRequests <- req
+		metrics.IpcQueueDepth.Inc()
+		queue <- req
+	case PolicyConcurrent:
+		fsipc.wg.Add(1)
+		go func() {
+			defer fsipc.wg.Done()
+			fsipc.handle(req)
+		}()
 	default:
-		fsipc.Requests <- request
+		metrics.IpcQueueDepth.Inc()
+		fsipc.sharedQueue <- req
+	}
+
+	return nil
+}
+
+func (fsipc *FsIpc) worker(queue chan queuedRequest) {
+	defer fsipc.wg.Done()
+
+	for req := range queue {
+		metrics.IpcQueueDepth.Dec()
+		fsipc.handle(req)
+	}
+}
+
+func (fsipc *FsIpc) handle(req queuedRequest) {
+	response := req.action.Handler(req.request)
+	if response == nil {
+		return
+	}
+
+	for _, id := range append(req.coalesced, req.id) {
+		err := fsipc.WriteResponse(id, response)
+		if err != nil {
+			fsipc.logger.Error("failed to write response", "id", id, "err", err)
+		}
+	}
+}
+
+// sameRequest compares two parsed requests. The ids aren't marshalled, so
+// only the data sent by the theme is compared.
+func sameRequest(a, b interface{}) bool {
+	dataA, errA := json.Marshal(a)
+	dataB, errB := json.Marshal(b)
+
+	return errA == nil && errB == nil && bytes.Equal(dataA, dataB)
+}
+
+// writeFileAtomic writes the data to a temporary file and renames it
+// afterwards, so readers never see a partially written file.
+func writeFileAtomic(filename string, data []byte, fsync bool) error {
+	dir, basename := filepath.Split(filename)
+
+	f, err := os.CreateTemp(dir, "."+basename+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpfile := f.Name()
+
+	_, err = f.Write(data)
+	if err == nil && fsync {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpfile, filename)
 	}
 
-	err = os.Remove(filename)
 	if err != nil {
-		fsipc.logger.Printf("failed to delete %s: %v", basename, err)
+		os.Remove(tmpfile)
 	}
 
-	return
+	return err
 }
 
 func (fsipc *FsIpc) WriteResponse(id string, data interface{}) error {
@@ -290,12 +487,20 @@ func (fsipc *FsIpc) WriteResponse(id string, data interface{}) error {
 		return err
 	}
 
-	if settings.Get().Debug {
-		fsipc.logger.Printf("writing response for %s: %s", id, b)
+	fsipc.logger.Debug("writing response", "id", id, "data", json.RawMessage(b))
+
+	fsipc.record(TranscriptResponse, id, b)
+
+	if fsipc.deliverNetResponse(id, b) {
+		return nil
 	}
 
+	return fsipc.writeResponseFile(id, b)
+}
+
+func (fsipc *FsIpc) writeResponseFile(id string, data []byte) error {
 	filename := filepath.Join(fsipc.responseDir, id+".json")
-	err = os.WriteFile(filename, b, 0600)
+	err := writeFileAtomic(filename, data, settings.Get().IpcFsync)
 
 	if err == nil {
 		// SM only waits up to one minute for a reply, so when the
@@ -308,7 +513,7 @@ func (fsipc *FsIpc) WriteResponse(id string, data interface{}) error {
 			case <-time.After(time.Minute):
 				err := os.Remove(filename)
 				if err != nil {
-					fsipc.logger.Printf("failed to delete %s: %v", filename, err)
+					fsipc.logger.Warn("failed to delete response", "file", filename, "err", err)
 				}
 			case <-fsipc.shutdown:
 				return
@@ -2,11 +2,18 @@ package fsipc
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
+
+	"github.com/GrooveStats/gslauncher/internal/settings"
+	"github.com/gorilla/websocket"
 )
 
 func writeRequest(filename string, data []byte) error {
@@ -20,31 +27,75 @@ func writeRequest(filename string, data []byte) error {
 	return os.Rename(tmpfile, filename)
 }
 
+// newTestRegistry registers the actions of the launcher with handlers that
+// pass the requests on to the returned channel.
+func newTestRegistry(t *testing.T) (*Registry, chan interface{}) {
+	requests := make(chan interface{}, 10)
+
+	handler := func(request interface{}) interface{} {
+		requests <- request
+		return nil
+	}
+
+	actions := []Action{
+		{
+			Name:       "ping",
+			NewRequest: func(id string) interface{} { return &PingRequest{Id: id} },
+			Policy:     PolicyShared,
+			Handler:    handler,
+		},
+		{
+			Name:       "groovestats/new-session",
+			NewRequest: func(id string) interface{} { return &GsNewSessionRequest{Id: id} },
+			Policy:     PolicyShared,
+			Handler:    handler,
+		},
+		{
+			Name:       "groovestats/player-scores",
+			NewRequest: func(id string) interface{} { return &GsPlayerScoresRequest{Id: id} },
+			Policy:     PolicyLatestOnly,
+			Handler:    handler,
+		},
+		{
+			Name:       "groovestats/player-leaderboards",
+			NewRequest: func(id string) interface{} { return &GsPlayerLeaderboardsRequest{Id: id} },
+			Policy:     PolicyLatestOnly,
+			Handler:    handler,
+		},
+		{
+			Name:       "groovestats/score-submit",
+			NewRequest: func(id string) interface{} { return &GsScoreSubmitRequest{Id: id} },
+			Policy:     PolicyShared,
+			Handler:    handler,
+		},
+	}
+
+	registry := NewRegistry()
+	for _, action := range actions {
+		err := registry.Register(action)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return registry, requests
+}
+
 func TestFsipc(t *testing.T) {
 	dir := t.TempDir()
 
-	ipc, err := New(dir)
+	registry, requests := newTestRegistry(t)
+
+	ipc, err := New(dir, registry)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	getRequest := func(t *testing.T) interface{} {
 		var request interface{}
-		var ok bool
 
 		select {
-		case request, ok = <-ipc.GsPlayerScoresRequests:
-			if !ok {
-				t.Fatal("player-scores requests channel closed prematurely")
-			}
-		case request, ok = <-ipc.GsPlayerLeaderboardsRequests:
-			if !ok {
-				t.Fatal("player-leaderboards requests channel closed prematurely")
-			}
-		case request, ok = <-ipc.Requests:
-			if !ok {
-				t.Fatal("requests channel closed prematurely")
-			}
+		case request = <-requests:
 		case <-time.After(10 * time.Second):
 			t.Fatal("request not received")
 		}
@@ -60,7 +111,7 @@ func TestFsipc(t *testing.T) {
 				"protocol": 1
 			}`))
 			if err != nil {
-				t.Fatal(err)
+				t.Error(err)
 			}
 		}()
 
@@ -87,7 +138,7 @@ func TestFsipc(t *testing.T) {
 				"chartHashVersion": 3
 			}`))
 			if err != nil {
-				t.Fatal(err)
+				t.Error(err)
 			}
 		}()
 
@@ -117,7 +168,7 @@ func TestFsipc(t *testing.T) {
 				}
 			}`))
 			if err != nil {
-				t.Fatal(err)
+				t.Error(err)
 			}
 		}()
 
@@ -140,58 +191,6 @@ func TestFsipc(t *testing.T) {
 		}
 	})
 
-	t.Run("GsPlayerScoresRequestDuplicate", func(t *testing.T) {
-		filename := filepath.Join(dir, "requests", "26ff392e4b894617b59c39beef4adfae.json")
-		err := writeRequest(filename, []byte(`{
-			"action": "groovestats/player-scores",
-			"player2": {
-				"chartHash": "H",
-				"apiKey": "K"
-			}
-		}`))
-		if err != nil {
-			t.Fatal(err)
-		}
-
-		time.Sleep(100 * time.Millisecond)
-
-		filename = filepath.Join(dir, "requests", "3fd78791f6a04689ad7dcb6887b418cc.json")
-		err = writeRequest(filename, []byte(`{
-			"action": "groovestats/player-scores",
-			"player2": {
-				"chartHash": "H",
-				"apiKey": "K"
-			}
-		}`))
-		if err != nil {
-			t.Fatal(err)
-		}
-
-		time.Sleep(100 * time.Millisecond)
-
-		request := getRequest(t)
-		playerScoresRequest, ok := request.(*GsPlayerScoresRequest)
-		if !ok {
-			t.Fatal("incorrect request type")
-		}
-
-		expected := GsPlayerScoresRequest{
-			Id:      "3fd78791f6a04689ad7dcb6887b418cc",
-			Player1: nil,
-			Player2: &gsPlayerData{
-				ChartHash: "H",
-				ApiKey:    "K",
-			},
-		}
-		if !reflect.DeepEqual(*playerScoresRequest, expected) {
-			t.Fatal("unexpected request")
-		}
-
-		if len(ipc.GsPlayerScoresRequests) > 0 {
-			t.Fatal("old request not discarded")
-		}
-	})
-
 	t.Run("GsPlayerLeaderboardsRequest", func(t *testing.T) {
 		go func() {
 			filename := filepath.Join(dir, "requests", "68b50a36752141d58700b4d252dfee15.json")
@@ -203,7 +202,7 @@ func TestFsipc(t *testing.T) {
 				}
 			}`))
 			if err != nil {
-				t.Fatal(err)
+				t.Error(err)
 			}
 		}()
 
@@ -249,7 +248,7 @@ func TestFsipc(t *testing.T) {
 				}
 			}`))
 			if err != nil {
-				t.Fatal(err)
+				t.Error(err)
 			}
 		}()
 
@@ -283,6 +282,53 @@ func TestFsipc(t *testing.T) {
 		}
 	})
 
+	t.Run("InvalidRequest", func(t *testing.T) {
+		filename := filepath.Join(dir, "requests", "0c1c3b0b24c64ca5a4c5e0a4d1dd2f01.json")
+		err := writeRequest(filename, []byte(`{
+			"action": "groovestats/score-submit",
+			"player1": {
+				"apiKey": "K",
+				"chartHash": "H",
+				"score": 10001,
+				"rate": 100
+			}
+		}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		responseFilename := filepath.Join(dir, "responses", "0c1c3b0b24c64ca5a4c5e0a4d1dd2f01.json")
+
+		var serialized []byte
+		for i := 0; i < 100; i++ {
+			serialized, err = os.ReadFile(responseFilename)
+			if err == nil {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var response InvalidResponse
+		err = json.Unmarshal(serialized, &response)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if response.Status != "invalid" || response.Error == nil {
+			t.Fatal("unexpected response")
+		}
+		if response.Error.Code != ErrorValidation || response.Error.Field != "player1.score" {
+			t.Fatalf("unexpected error: %+v", *response.Error)
+		}
+
+		if len(requests) > 0 {
+			t.Fatal("invalid request passed on")
+		}
+	})
+
 	t.Run("WriteResponse", func(t *testing.T) {
 		var data struct {
 			Payload string `json:"payload"`
@@ -312,9 +358,598 @@ func TestFsipc(t *testing.T) {
 			t.Fatal(err)
 		}
 
-		_, ok := <-ipc.Requests
-		if ok {
-			t.Fatal("requests channel not closed")
+		err = ipc.handleRequest("a1d3bb0a5a7a4f0e9a8ff4dba3b7cc41", []byte(`{
+			"action": "ping",
+			"protocol": 1
+		}`))
+		if err == nil {
+			t.Fatal("request accepted after closing")
 		}
 	})
 }
+
+func TestNetIpc(t *testing.T) {
+	dir := t.TempDir()
+
+	registry := NewRegistry()
+	err := registry.Register(Action{
+		Name:       "ping",
+		NewRequest: func(id string) interface{} { return &PingRequest{Id: id} },
+		Policy:     PolicyShared,
+		Handler: func(request interface{}) interface{} {
+			return PingResponse{
+				Version: PingVersion{Major: 1, Minor: 2, Patch: 3},
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ipc, err := New(dir, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ipc.Close()
+
+	addr, err := ipc.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := bytes.NewBufferString(`{"action": "ping", "protocol": 1}`)
+	resp, err := http.Post(fmt.Sprintf("http://%v/request", addr), "application/json", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status %s", resp.Status)
+	}
+
+	var response PingResponse
+	err = json.NewDecoder(resp.Body).Decode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Version != (PingVersion{Major: 1, Minor: 2, Patch: 3}) {
+		t.Fatal("unexpected response")
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, "responses"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatal("response written to the responses directory")
+	}
+}
+
+func TestNetIpcLocalOnly(t *testing.T) {
+	registry := NewRegistry()
+	err := registry.Register(Action{
+		Name:       "ping",
+		NewRequest: func(id string) interface{} { return &PingRequest{Id: id} },
+		Policy:     PolicyShared,
+		Handler: func(request interface{}) interface{} {
+			return PingResponse{}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ipc, err := New(t.TempDir(), registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ipc.Close()
+
+	addr, err := ipc.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := addr.(*net.TCPAddr).Port
+
+	tests := []struct {
+		host        string
+		origin      string
+		contentType string
+		status      int
+	}{
+		{fmt.Sprintf("127.0.0.1:%d", port), "", "application/json", http.StatusOK},
+		{fmt.Sprintf("localhost:%d", port), "http://localhost:8080", "application/json; charset=utf-8", http.StatusOK},
+		{fmt.Sprintf("[::1]:%d", port), "null", "application/json", http.StatusForbidden},
+		{fmt.Sprintf("evil.example.com:%d", port), "", "application/json", http.StatusForbidden},
+		{"127.0.0.1:1", "", "application/json", http.StatusForbidden},
+		{fmt.Sprintf("127.0.0.1:%d", port), "https://evil.example.com", "application/json", http.StatusForbidden},
+		{fmt.Sprintf("127.0.0.1:%d", port), "", "text/plain", http.StatusUnsupportedMediaType},
+		{fmt.Sprintf("127.0.0.1:%d", port), "", "application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+	}
+
+	for _, test := range tests {
+		body := bytes.NewBufferString(`{"action": "ping", "protocol": 1}`)
+		req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("http://%v/request", addr), body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Host = test.host
+		req.Header.Set("Content-Type", test.contentType)
+		if test.origin != "" {
+			req.Header.Set("Origin", test.origin)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != test.status {
+			t.Errorf("%+v: unexpected status %s", test, resp.Status)
+		}
+	}
+
+	wsUrl := fmt.Sprintf("ws://%v/ws", addr)
+
+	_, resp, err := websocket.DefaultDialer.Dial(wsUrl, http.Header{"Origin": {"null"}})
+	if err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("WebSocket with null origin accepted: %v", err)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsUrl, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for i := 0; i < 3; i++ {
+		err = conn.WriteJSON(map[string]interface{}{"id": fmt.Sprint(i), "action": "ping", "protocol": 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var message wsMessage
+		err = conn.ReadJSON(&message)
+		if err != nil || message.Id != fmt.Sprint(i) || message.Error != "" {
+			t.Fatalf("unexpected WebSocket message %+v: %v", message, err)
+		}
+	}
+
+	ipc.netMutex.Lock()
+	defer ipc.netMutex.Unlock()
+	if len(ipc.netPending) != 0 {
+		t.Errorf("answered requests still pending: %v", ipc.netPending)
+	}
+}
+
+func TestFsipcPolling(t *testing.T) {
+	oldSettings := settings.Get()
+	defer settings.Update(oldSettings)
+
+	newSettings := settings.Get()
+	newSettings.IpcWatchMode = settings.WatchPoll
+	settings.Update(newSettings)
+
+	dir := t.TempDir()
+	registry, requests := newTestRegistry(t)
+
+	ipc, err := New(dir, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ipc.Close()
+
+	for _, id := range []string{"4d2b5dc2f0b64f2c9b6e6a9a1e3a1a01", "4d2b5dc2f0b64f2c9b6e6a9a1e3a1a02"} {
+		filename := filepath.Join(dir, "requests", id+".json")
+		err := writeRequest(filename, []byte(`{
+			"action": "groovestats/new-session",
+			"chartHashVersion": 3
+		}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case request := <-requests:
+			if _, ok := request.(*GsNewSessionRequest); !ok {
+				t.Fatal("incorrect request type")
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("request not received")
+		}
+	}
+
+	select {
+	case <-requests:
+		t.Fatal("request handled twice")
+	case <-time.After(2 * pollInterval):
+	}
+}
+
+func TestFsipcDuplicateRequest(t *testing.T) {
+	dir := t.TempDir()
+
+	started := make(chan *GsPlayerScoresRequest, 10)
+	gate := make(chan struct{})
+
+	registry := NewRegistry()
+	err := registry.Register(Action{
+		Name:       "groovestats/player-scores",
+		NewRequest: func(id string) interface{} { return &GsPlayerScoresRequest{Id: id} },
+		Policy:     PolicyLatestOnly,
+		Handler: func(request interface{}) interface{} {
+			playerScoresRequest := request.(*GsPlayerScoresRequest)
+			started <- playerScoresRequest
+			<-gate
+			return map[string]string{"chartHash": playerScoresRequest.Player2.ChartHash}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ipc, err := New(dir, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ipc.Close()
+
+	next := func() *GsPlayerScoresRequest {
+		select {
+		case request := <-started:
+			return request
+		case <-time.After(10 * time.Second):
+			t.Fatal("request not received")
+			return nil
+		}
+	}
+
+	write := func(id string, chartHash string) {
+		filename := filepath.Join(dir, "requests", id+".json")
+		err := writeRequest(filename, []byte(fmt.Sprintf(`{
+			"action": "groovestats/player-scores",
+			"player2": {
+				"chartHash": %q,
+				"apiKey": "K"
+			}
+		}`, chartHash)))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// keep the handler busy, so the duplicates wait in the queue
+	write("1f3c5e7a9b2d4f6081a3c5e7f9b1d301", "A")
+	next()
+
+	ids := []string{"26ff392e4b894617b59c39beef4adfae", "3fd78791f6a04689ad7dcb6887b418cc"}
+	for _, id := range ids {
+		write(id, "H")
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	close(gate)
+
+	if request := next(); request.Id != ids[1] {
+		t.Fatalf("expected request %s, got %s", ids[1], request.Id)
+	}
+
+	for _, id := range ids {
+		filename := filepath.Join(dir, "responses", id+".json")
+
+		var data []byte
+		for i := 0; i < 100; i++ {
+			data, err = os.ReadFile(filename)
+			if err == nil {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != `{"chartHash":"H"}` {
+			t.Errorf("unexpected response for %s: %s", id, data)
+		}
+	}
+
+	select {
+	case request := <-started:
+		t.Fatalf("duplicate request %s handled again", request.Id)
+	default:
+	}
+}
+
+func TestFsipcRetryUnreadable(t *testing.T) {
+	oldSettings := settings.Get()
+	defer settings.Update(oldSettings)
+
+	newSettings := settings.Get()
+	newSettings.IpcWatchMode = settings.WatchPoll
+	settings.Update(newSettings)
+
+	dir := t.TempDir()
+	registry, requests := newTestRegistry(t)
+
+	ipc, err := New(dir, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ipc.Close()
+
+	// the request can't be read until the link target shows up
+	target := filepath.Join(t.TempDir(), "request.json")
+	err = os.Symlink(target, filepath.Join(dir, "requests", "4d2b5dc2f0b64f2c9b6e6a9a1e3a1a01.json"))
+	if err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+
+	select {
+	case <-requests:
+		t.Fatal("unreadable request handled")
+	case <-time.After(4 * pollInterval):
+	}
+
+	err = writeRequest(target, []byte(`{"action": "groovestats/new-session", "chartHashVersion": 3}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-requests:
+	case <-time.After(10 * time.Second):
+		t.Fatal("request not retried")
+	}
+
+	// handled files are forgotten once they are deleted
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		ipc.handledMutex.Lock()
+		n := len(ipc.handled)
+		ipc.handledMutex.Unlock()
+
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d handled files not forgotten", n)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+func TestWriteResponseAtomic(t *testing.T) {
+	for _, fsync := range []bool{false, true} {
+		t.Run(fmt.Sprintf("fsync=%v", fsync), func(t *testing.T) {
+			oldSettings := settings.Get()
+			defer settings.Update(oldSettings)
+
+			newSettings := settings.Get()
+			newSettings.IpcFsync = fsync
+			settings.Update(newSettings)
+
+			dir := t.TempDir()
+
+			ipc, err := New(dir, NewRegistry())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ipc.Close()
+
+			// roughly the size of a large leaderboard response
+			type entry struct {
+				Name     string `json:"name"`
+				Score    int    `json:"score"`
+				Comments string `json:"comments"`
+			}
+			leaderboard := make([]entry, 5000)
+			for i := range leaderboard {
+				leaderboard[i] = entry{
+					Name:     fmt.Sprintf("player %d", i),
+					Score:    10000 - i,
+					Comments: "C700, Overhead, Cel",
+				}
+			}
+
+			const id = "6b0cbd1f3c7e4e5fa3a0e0a2fd0ad1b3"
+			filename := filepath.Join(dir, "responses", id+".json")
+			stop := make(chan struct{})
+			errs := make(chan error, 8)
+
+			for i := 0; i < 8; i++ {
+				go func() {
+					for {
+						select {
+						case <-stop:
+							errs <- nil
+							return
+						default:
+						}
+
+						data, err := os.ReadFile(filename)
+						if os.IsNotExist(err) {
+							continue
+						} else if err != nil {
+							errs <- err
+							return
+						}
+
+						var response []entry
+						err = json.Unmarshal(data, &response)
+						if err != nil {
+							errs <- fmt.Errorf("truncated response (%d bytes): %w", len(data), err)
+							return
+						}
+						if len(response) != len(leaderboard) {
+							errs <- fmt.Errorf("incomplete response")
+							return
+						}
+					}
+				}()
+			}
+
+			// syncing is slow, so don't overdo it
+			iterations := 200
+			if fsync {
+				iterations = 20
+			}
+
+			for i := 0; i < iterations; i++ {
+				err := ipc.WriteResponse(id, leaderboard)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			close(stop)
+			for i := 0; i < 8; i++ {
+				err := <-errs
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			entries, err := os.ReadDir(filepath.Join(dir, "responses"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 {
+				t.Fatal("temporary files left behind")
+			}
+		})
+	}
+}
+
+func TestRecord(t *testing.T) {
+	dir := t.TempDir()
+	registry, requests := newTestRegistry(t)
+
+	ipc, err := New(dir, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transcript := filepath.Join(t.TempDir(), "transcript.jsonl")
+	err = ipc.Record(transcript)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ipc.handleRequest("f2b8b0fb2d1c4a5e8c9a2a47b0a5b6c1", []byte(`{
+		"action": "groovestats/player-scores",
+		"player1": {
+			"chartHash": "H",
+			"apiKey": "secret"
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := (<-requests).(*GsPlayerScoresRequest)
+	err = ipc.WriteResponse(request.Id, NetworkResponse{Status: "success"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ipc.handleRequest("f2b8b0fb2d1c4a5e8c9a2a47b0a5b6c2", []byte(`{"action": "unknown"}`))
+	if err == nil {
+		t.Fatal("invalid request accepted")
+	}
+
+	err = ipc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(transcript)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(data, []byte("secret")) {
+		t.Fatal("api key not redacted")
+	}
+
+	lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 transcript entries, got %d", len(lines))
+	}
+
+	kinds := []string{TranscriptRequest, TranscriptResponse, TranscriptRequest, TranscriptResponse}
+	for i, line := range lines {
+		var entry TranscriptEntry
+
+		err := json.Unmarshal(line, &entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if entry.Kind != kinds[i] {
+			t.Fatalf("entry %d: expected %s, got %s", i, kinds[i], entry.Kind)
+		}
+	}
+
+	var invalid struct {
+		Data InvalidResponse `json:"data"`
+	}
+	json.Unmarshal(lines[3], &invalid)
+	if invalid.Data.Status != "invalid" || invalid.Data.Error == nil || invalid.Data.Error.Code != ErrorUnknownAction {
+		t.Fatalf("unexpected response: %s", lines[3])
+	}
+}
+
+func TestStatus(t *testing.T) {
+	dir := t.TempDir()
+
+	ipc, err := New(dir, NewRegistry())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ipc.WriteStatus(&Status{Pid: 42, PendingUnlocks: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "status.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var status Status
+	err = json.Unmarshal(data, &status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Pid != 42 || status.PendingUnlocks != 1 || status.NewSession != nil {
+		t.Fatalf("unexpected status: %s", data)
+	}
+
+	err = ipc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = os.Stat(filepath.Join(dir, "status.json"))
+	if !os.IsNotExist(err) {
+		t.Fatal("status file not removed")
+	}
+
+	// writing after closing must not recreate the file
+	err = ipc.WriteStatus(&Status{Pid: 42})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = os.Stat(filepath.Join(dir, "status.json"))
+	if !os.IsNotExist(err) {
+		t.Fatal("status file written after closing")
+	}
+}
@@ -0,0 +1,391 @@
+package fsipc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// The network transport accepts the same requests as the filesystem
+// transport. Plain HTTP clients POST a request to /request and receive the
+// response in the reply. WebSocket clients connect to /ws and can have
+// multiple requests in flight. Every message sent over the socket is a
+// request with an additional "id" field. Responses are sent back as
+// {"id": "<id>", "response": {...}} or {"id": "<id>", "error": "..."}.
+
+const maxNetRequestSize = 1024 * 1024
+
+// Requests received over the network get ids with this prefix. Responses for
+// them are never written to the responses directory.
+const netRequestPrefix = "net-"
+
+var errSuperseded = errors.New("request superseded by a newer one")
+
+type wsMessage struct {
+	Id       string          `json:"id"`
+	Response json.RawMessage `json:"response,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// Listen starts the network transport on the given address. The server is
+// stopped when the FsIpc is closed.
+func (fsipc *FsIpc) Listen(addr string) (net.Addr, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/request", fsipc.handleHttpRequest)
+	mux.HandleFunc("/ws", fsipc.handleWebSocket)
+
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	fsipc.server = &http.Server{
+		Handler:           localOnly(port, mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	fsipc.wg.Add(1)
+	go func() {
+		defer fsipc.wg.Done()
+
+		err := fsipc.server.Serve(listener)
+		if err != http.ErrServerClosed {
+			fsipc.logger.Info("network transport stopped", "err", err)
+		}
+	}()
+
+	fsipc.logger.Info("listening", "addr", listener.Addr().String())
+
+	return listener.Addr(), nil
+}
+
+// localOnly only lets requests through that come from tools and pages on
+// this computer. The Host check defeats DNS rebinding, the Origin check keeps
+// websites opened in a browser from talking to the launcher.
+func localOnly(port int, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isLocalHost(r.Host, port) {
+			http.Error(w, "forbidden host", http.StatusForbidden)
+			return
+		}
+
+		if !isLocalOrigin(r) {
+			http.Error(w, "forbidden origin", http.StatusForbidden)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func isLocalHost(hostport string, port int) bool {
+	host, p, err := net.SplitHostPort(hostport)
+	if err != nil || p != strconv.Itoa(port) {
+		return false
+	}
+
+	return strings.EqualFold(host, "localhost") || host == "127.0.0.1" || host == "::1"
+}
+
+func isLocalOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	// "null" is sent by sandboxed iframes and data: urls of any website
+	if origin == "null" {
+		return false
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+
+	host := u.Hostname()
+	return strings.EqualFold(host, "localhost") || host == "127.0.0.1" || host == "::1"
+}
+
+func (fsipc *FsIpc) closeServer() {
+	if fsipc.server == nil {
+		return
+	}
+
+	// Pending HTTP requests return as soon as the shutdown channel is
+	// closed, so this doesn't take long.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := fsipc.server.Shutdown(ctx)
+	if err != nil {
+		fsipc.logger.Error("failed to stop network transport", "err", err)
+	}
+}
+
+func (fsipc *FsIpc) addNetPending(id string, deliver func([]byte, error)) {
+	fsipc.netMutex.Lock()
+	fsipc.netPending[id] = deliver
+	fsipc.netMutex.Unlock()
+}
+
+func (fsipc *FsIpc) removeNetPending(id string) func([]byte, error) {
+	fsipc.netMutex.Lock()
+	defer fsipc.netMutex.Unlock()
+
+	deliver, ok := fsipc.netPending[id]
+	if !ok {
+		return nil
+	}
+
+	delete(fsipc.netPending, id)
+	return deliver
+}
+
+// deliverNetResponse hands the response to the network client waiting for
+// it. It returns false if the request wasn't received over the network.
+func (fsipc *FsIpc) deliverNetResponse(id string, data []byte) bool {
+	if !strings.HasPrefix(id, netRequestPrefix) {
+		return false
+	}
+
+	// The client might be gone already, in which case the response is
+	// dropped.
+	deliver := fsipc.removeNetPending(id)
+	if deliver != nil {
+		deliver(data, nil)
+	}
+
+	return true
+}
+
+// supersede tells the theme that its request has been dropped in favor of a
+// newer one, so it doesn't wait for a response.
+func (fsipc *FsIpc) supersede(id string) {
+	fsipc.record(TranscriptSuperseded, id, nil)
+
+	if !strings.HasPrefix(id, netRequestPrefix) {
+		data, _ := json.Marshal(NewSupersededResponse())
+		err := fsipc.writeResponseFile(id, data)
+		if err != nil {
+			fsipc.logger.Error("failed to write response", "id", id, "err", err)
+		}
+		return
+	}
+
+	deliver := fsipc.removeNetPending(id)
+	if deliver != nil {
+		deliver(nil, errSuperseded)
+	}
+}
+
+func (fsipc *FsIpc) handleHttpRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Browsers send a preflight for JSON, so a cross-site form can't post
+	// requests.
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		http.Error(w, "content type must be application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxNetRequestSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id, err := newRequestId()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	id = netRequestPrefix + "http-" + id
+
+	type result struct {
+		data []byte
+		err  error
+	}
+	ch := make(chan result, 1)
+
+	fsipc.addNetPending(id, func(data []byte, err error) {
+		ch <- result{data, err}
+	})
+	defer fsipc.removeNetPending(id)
+
+	err = fsipc.handleRequest(id, data)
+	if err != nil {
+		fsipc.logger.Warn("invalid request", "id", id, "err", err)
+
+		requestError, ok := err.(*RequestError)
+		if !ok {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		data, _ := json.Marshal(NewInvalidResponse(requestError))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(data)
+		return
+	}
+
+	select {
+	case res := <-ch:
+		if res.err != nil {
+			http.Error(w, res.err.Error(), http.StatusConflict)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(res.data)
+	case <-time.After(time.Minute):
+		http.Error(w, "timeout", http.StatusGatewayTimeout)
+	case <-fsipc.shutdown:
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+	case <-r.Context().Done():
+	}
+}
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: isLocalOrigin,
+}
+
+func (fsipc *FsIpc) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	connId, err := newRequestId()
+	if err != nil {
+		return
+	}
+
+	var writeMutex sync.Mutex
+	send := func(message wsMessage) {
+		writeMutex.Lock()
+		defer writeMutex.Unlock()
+
+		conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		err := conn.WriteJSON(message)
+		if err != nil {
+			fsipc.logger.Warn("failed to send response", "id", message.Id, "err", err)
+		}
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-fsipc.shutdown:
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	// the requests still waiting for a response, forgotten when the
+	// connection closes
+	var pendingMutex sync.Mutex
+	pending := make(map[string]bool)
+	defer func() {
+		pendingMutex.Lock()
+		defer pendingMutex.Unlock()
+
+		for id := range pending {
+			fsipc.removeNetPending(id)
+		}
+	}()
+
+	requests := 0
+
+	conn.SetReadLimit(maxNetRequestSize)
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var base struct {
+			Id string `json:"id"`
+		}
+		json.Unmarshal(data, &base)
+
+		clientId := base.Id
+		if clientId == "" {
+			send(wsMessage{Error: "missing id"})
+			continue
+		}
+
+		id := fmt.Sprintf("%sws-%s-%d", netRequestPrefix, connId, requests)
+		requests++
+
+		pendingMutex.Lock()
+		pending[id] = true
+		pendingMutex.Unlock()
+
+		fsipc.addNetPending(id, func(data []byte, err error) {
+			pendingMutex.Lock()
+			delete(pending, id)
+			pendingMutex.Unlock()
+
+			message := wsMessage{Id: clientId, Response: data}
+			if err != nil {
+				message.Error = err.Error()
+			}
+			send(message)
+		})
+
+		err = fsipc.handleRequest(id, data)
+		if err != nil {
+			fsipc.logger.Warn("invalid request", "id", id, "err", err)
+			fsipc.removeNetPending(id)
+
+			pendingMutex.Lock()
+			delete(pending, id)
+			pendingMutex.Unlock()
+
+			requestError, ok := err.(*RequestError)
+			if !ok {
+				send(wsMessage{Id: clientId, Error: err.Error()})
+				continue
+			}
+
+			response, _ := json.Marshal(NewInvalidResponse(requestError))
+			send(wsMessage{Id: clientId, Response: response})
+		}
+	}
+}
+
+func newRequestId() (string, error) {
+	b := make([]byte, 16)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", b), nil
+}
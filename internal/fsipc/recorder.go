@@ -0,0 +1,126 @@
+package fsipc
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/GrooveStats/gslauncher/internal/logging"
+)
+
+const (
+	TranscriptRequest    = "request"
+	TranscriptResponse   = "response"
+	TranscriptSuperseded = "superseded"
+)
+
+// TranscriptEntry is a single line of an IPC transcript. Requests are stored
+// with their api keys and profile names redacted.
+type TranscriptEntry struct {
+	Time time.Time `json:"time"`
+	Kind string    `json:"kind"`
+	Id   string    `json:"id"`
+
+	// time since the request was received, only set for responses
+	ElapsedMs int64 `json:"elapsedMs,omitempty"`
+
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+type recorder struct {
+	mutex    sync.Mutex
+	file     *os.File
+	encoder  *json.Encoder
+	received map[string]time.Time
+}
+
+// Record writes all requests and responses to a transcript in the JSON lines
+// format until the FsIpc is closed.
+func (fsipc *FsIpc) Record(filename string) error {
+	err := os.MkdirAll(filepath.Dir(filename), os.ModeDir|0700)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+
+	rec := &fsipc.recorder
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+
+	if rec.file != nil {
+		rec.file.Close()
+	}
+
+	rec.file = file
+	rec.encoder = json.NewEncoder(file)
+	rec.received = make(map[string]time.Time)
+
+	fsipc.logger.Info("recording transcript", "file", filename)
+
+	return nil
+}
+
+func (fsipc *FsIpc) record(kind string, id string, data []byte) {
+	rec := &fsipc.recorder
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+
+	if rec.file == nil {
+		return
+	}
+
+	now := time.Now()
+	entry := TranscriptEntry{
+		Time: now,
+		Kind: kind,
+		Id:   id,
+	}
+
+	switch kind {
+	case TranscriptRequest:
+		rec.received[id] = now
+	default:
+		received, ok := rec.received[id]
+		if ok {
+			entry.ElapsedMs = now.Sub(received).Milliseconds()
+			delete(rec.received, id)
+		}
+	}
+
+	if data != nil {
+		data = []byte(logging.Redact(string(data)))
+
+		// keep malformed requests as a string
+		if !json.Valid(data) {
+			data, _ = json.Marshal(string(data))
+		}
+		entry.Data = data
+	}
+
+	err := rec.encoder.Encode(entry)
+	if err != nil {
+		fsipc.logger.Error("failed to write transcript", "err", err)
+	}
+}
+
+func (fsipc *FsIpc) stopRecording() error {
+	rec := &fsipc.recorder
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+
+	if rec.file == nil {
+		return nil
+	}
+
+	err := rec.file.Close()
+	rec.file = nil
+	rec.encoder = nil
+
+	return err
+}
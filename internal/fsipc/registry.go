@@ -0,0 +1,94 @@
+package fsipc
+
+import (
+	"fmt"
+)
+
+// Policy decides how requests of an action are queued.
+type Policy int
+
+const (
+	// Requests are handled one after another, in the order they arrive,
+	// together with the requests of all other shared actions.
+	PolicyShared Policy = iota
+
+	// Requests have their own queue that only holds the newest request.
+	// Pending requests are dropped when a newer one arrives. Useful for
+	// requests that become pointless once the theme asks for something
+	// else, like scores for a song the player scrolled past.
+	PolicyLatestOnly
+
+	// Every request is handled right away in its own goroutine.
+	PolicyConcurrent
+)
+
+// HandlerFunc handles a request and returns the response to send back to the
+// theme. If it returns nil no response is sent.
+type HandlerFunc func(request interface{}) interface{}
+
+type Action struct {
+	Name string
+
+	// NewRequest returns an empty request, that the request data is
+	// unmarshalled into. The request is validated according to its
+	// validate struct tags.
+	NewRequest func(id string) interface{}
+
+	// Validate is an optional check that runs after the struct tag
+	// validation. Returning a *RequestError controls the error sent to the
+	// theme.
+	Validate func(request interface{}) error
+
+	Policy  Policy
+	Handler HandlerFunc
+}
+
+// Registry holds the actions the launcher understands.
+type Registry struct {
+	actions map[string]*Action
+	names   []string
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		actions: make(map[string]*Action),
+		names:   make([]string, 0),
+	}
+}
+
+func (registry *Registry) Register(action Action) error {
+	if action.Name == "" {
+		return fmt.Errorf("action without name")
+	}
+
+	if action.NewRequest == nil || action.Handler == nil {
+		return fmt.Errorf("action %s is incomplete", action.Name)
+	}
+
+	switch action.Policy {
+	case PolicyShared, PolicyLatestOnly, PolicyConcurrent:
+	default:
+		return fmt.Errorf("action %s has an unknown policy", action.Name)
+	}
+
+	if _, ok := registry.actions[action.Name]; ok {
+		return fmt.Errorf("action %s registered twice", action.Name)
+	}
+
+	registry.actions[action.Name] = &action
+	registry.names = append(registry.names, action.Name)
+
+	return nil
+}
+
+func (registry *Registry) Lookup(name string) (*Action, bool) {
+	action, ok := registry.actions[name]
+	return action, ok
+}
+
+// Names returns the names of all actions in the order they were registered.
+func (registry *Registry) Names() []string {
+	names := make([]string, len(registry.names))
+	copy(names, registry.names)
+	return names
+}
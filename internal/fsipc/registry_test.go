@@ -0,0 +1,349 @@
+package fsipc
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testRequest struct {
+	Id    string `json:"-"`
+	Value int    `json:"value" validate:"min=0"`
+}
+
+func newTestRequest(id string) interface{} {
+	return &testRequest{Id: id}
+}
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	handler := func(request interface{}) interface{} { return nil }
+
+	for _, name := range []string{"b", "a", "c"} {
+		err := registry.Register(Action{
+			Name:       name,
+			NewRequest: newTestRequest,
+			Handler:    handler,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if names := registry.Names(); !reflect.DeepEqual(names, []string{"b", "a", "c"}) {
+		t.Fatalf("unexpected names: %v", names)
+	}
+
+	action, ok := registry.Lookup("a")
+	if !ok || action.Name != "a" || action.Policy != PolicyShared {
+		t.Fatal("action not found")
+	}
+
+	_, ok = registry.Lookup("d")
+	if ok {
+		t.Fatal("unknown action found")
+	}
+
+	invalid := []Action{
+		{Name: "a", NewRequest: newTestRequest, Handler: handler},
+		{Name: "", NewRequest: newTestRequest, Handler: handler},
+		{Name: "d", Handler: handler},
+		{Name: "d", NewRequest: newTestRequest},
+		{Name: "d", NewRequest: newTestRequest, Handler: handler, Policy: Policy(42)},
+	}
+	for i, action := range invalid {
+		err := registry.Register(action)
+		if err == nil {
+			t.Errorf("invalid action %d registered", i)
+		}
+	}
+
+	if len(registry.Names()) != 3 {
+		t.Fatal("invalid action registered")
+	}
+}
+
+// gatedHandler passes requests on to a channel and blocks until the gate is
+// opened.
+type gatedHandler struct {
+	started chan *testRequest
+	gate    chan struct{}
+}
+
+func newGatedHandler() *gatedHandler {
+	return &gatedHandler{
+		started: make(chan *testRequest, 10),
+		gate:    make(chan struct{}),
+	}
+}
+
+func (h *gatedHandler) handle(request interface{}) interface{} {
+	h.started <- request.(*testRequest)
+	<-h.gate
+	return nil
+}
+
+func (h *gatedHandler) next(t *testing.T) *testRequest {
+	select {
+	case request := <-h.started:
+		return request
+	case <-time.After(10 * time.Second):
+		t.Fatal("request not handled")
+		return nil
+	}
+}
+
+func (h *gatedHandler) expectIdle(t *testing.T) {
+	select {
+	case request := <-h.started:
+		t.Fatalf("unexpected request %s", request.Id)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func newRoutingIpc(t *testing.T, actions ...Action) *FsIpc {
+	registry := NewRegistry()
+	for _, action := range actions {
+		err := registry.Register(action)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ipc, err := New(t.TempDir(), registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ipc
+}
+
+func sendTestRequest(t *testing.T, ipc *FsIpc, action string, id string) {
+	err := ipc.handleRequest(id, []byte(fmt.Sprintf(`{"action": %q}`, action)))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRoutingLatestOnly(t *testing.T) {
+	handler := newGatedHandler()
+	ipc := newRoutingIpc(t, Action{
+		Name:       "latest",
+		NewRequest: newTestRequest,
+		Policy:     PolicyLatestOnly,
+		Handler:    handler.handle,
+	})
+	defer ipc.Close()
+
+	sendTestRequest(t, ipc, "latest", "1")
+	if request := handler.next(t); request.Id != "1" {
+		t.Fatalf("expected request 1, got %s", request.Id)
+	}
+
+	// the handler is busy, so request 2 waits and 3 is the same request,
+	// both are answered by a single call
+	sendTestRequest(t, ipc, "latest", "2")
+	sendTestRequest(t, ipc, "latest", "3")
+	handler.expectIdle(t)
+
+	close(handler.gate)
+
+	if request := handler.next(t); request.Id != "3" {
+		t.Fatalf("expected request 3, got %s", request.Id)
+	}
+	handler.expectIdle(t)
+}
+
+// TestRoutingLatestOnlyResponses checks the responses the theme gets for
+// the requests that are left waiting.
+func TestRoutingLatestOnlyResponses(t *testing.T) {
+	handler := newGatedHandler()
+	ipc := newRoutingIpc(t, Action{
+		Name:       "latest",
+		NewRequest: newTestRequest,
+		Policy:     PolicyLatestOnly,
+		Handler: func(request interface{}) interface{} {
+			handler.handle(request)
+			return map[string]int{"value": request.(*testRequest).Value}
+		},
+	})
+	defer ipc.Close()
+
+	send := func(id string, value int) {
+		err := ipc.handleRequest(id, []byte(fmt.Sprintf(`{"action": "latest", "value": %d}`, value)))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	readResponse := func(id string) string {
+		filename := filepath.Join(ipc.RootDir, "responses", id+".json")
+
+		var data []byte
+		var err error
+		for i := 0; i < 100; i++ {
+			data, err = os.ReadFile(filename)
+			if err == nil {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return string(data)
+	}
+
+	send("1", 1)
+	handler.next(t)
+
+	// 2 is replaced by the different request 3, 4 is the same as 3
+	send("2", 2)
+	send("3", 3)
+	send("4", 3)
+
+	if response := readResponse("2"); response != `{"status":"superseded"}` {
+		t.Errorf("unexpected response for the superseded request: %s", response)
+	}
+
+	close(handler.gate)
+
+	if request := handler.next(t); request.Id != "4" {
+		t.Fatalf("expected request 4, got %s", request.Id)
+	}
+	handler.expectIdle(t)
+
+	for _, id := range []string{"3", "4"} {
+		if response := readResponse(id); response != `{"value":3}` {
+			t.Errorf("unexpected response for request %s: %s", id, response)
+		}
+	}
+}
+
+func TestRoutingShared(t *testing.T) {
+	handler := newGatedHandler()
+	ipc := newRoutingIpc(t,
+		Action{
+			Name:       "first",
+			NewRequest: newTestRequest,
+			Policy:     PolicyShared,
+			Handler:    handler.handle,
+		},
+		Action{
+			Name:       "second",
+			NewRequest: newTestRequest,
+			Policy:     PolicyShared,
+			Handler:    handler.handle,
+		},
+	)
+	defer ipc.Close()
+
+	sendTestRequest(t, ipc, "first", "1")
+	sendTestRequest(t, ipc, "second", "2")
+	sendTestRequest(t, ipc, "first", "3")
+
+	// shared actions are handled one after another
+	if request := handler.next(t); request.Id != "1" {
+		t.Fatalf("expected request 1, got %s", request.Id)
+	}
+	handler.expectIdle(t)
+
+	close(handler.gate)
+
+	for _, id := range []string{"2", "3"} {
+		if request := handler.next(t); request.Id != id {
+			t.Fatalf("expected request %s, got %s", id, request.Id)
+		}
+	}
+}
+
+func TestRoutingConcurrent(t *testing.T) {
+	handler := newGatedHandler()
+	ipc := newRoutingIpc(t, Action{
+		Name:       "concurrent",
+		NewRequest: newTestRequest,
+		Policy:     PolicyConcurrent,
+		Handler:    handler.handle,
+	})
+	defer ipc.Close()
+
+	sendTestRequest(t, ipc, "concurrent", "1")
+	sendTestRequest(t, ipc, "concurrent", "2")
+
+	// both requests are handled while the gate is still closed
+	seen := map[string]bool{}
+	seen[handler.next(t).Id] = true
+	seen[handler.next(t).Id] = true
+	if !seen["1"] || !seen["2"] {
+		t.Fatalf("unexpected requests: %v", seen)
+	}
+
+	close(handler.gate)
+}
+
+func TestRoutingResponse(t *testing.T) {
+	ipc := newRoutingIpc(t, Action{
+		Name:       "double",
+		NewRequest: newTestRequest,
+		Validate: func(request interface{}) error {
+			if request.(*testRequest).Value == 13 {
+				return fmt.Errorf("unlucky number")
+			}
+			return nil
+		},
+		Handler: func(request interface{}) interface{} {
+			return map[string]int{"value": 2 * request.(*testRequest).Value}
+		},
+	})
+	defer ipc.Close()
+
+	err := ipc.handleRequest("1", []byte(`{"action": "double", "value": 21}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	filename := filepath.Join(ipc.responseDir, "1.json")
+
+	var data []byte
+	for i := 0; i < 100; i++ {
+		data, err = os.ReadFile(filename)
+		if err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var response map[string]int
+	err = json.Unmarshal(data, &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response["value"] != 42 {
+		t.Fatalf("unexpected response: %s", data)
+	}
+
+	// struct tag validation
+	err = ipc.handleRequest("2", []byte(`{"action": "double", "value": -1}`))
+	if requestError, ok := err.(*RequestError); !ok || requestError.Field != "value" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// additional validation
+	err = ipc.handleRequest("3", []byte(`{"action": "double", "value": 13}`))
+	if requestError, ok := err.(*RequestError); !ok || requestError.Code != ErrorValidation {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = ipc.handleRequest("4", []byte(`{"action": "triple", "value": 1}`))
+	if requestError, ok := err.(*RequestError); !ok || requestError.Code != ErrorUnknownAction {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -1,8 +1,12 @@
 package fsipc
 
+// PingRequest announces the range of protocol versions the theme supports.
+// Protocol is the newest version, MinProtocol the oldest one. Older themes
+// only send Protocol.
 type PingRequest struct {
-	Id       string `json:"-"`
-	Protocol int    `json:"protocol" validate:"required"`
+	Id          string `json:"-"`
+	Protocol    int    `json:"protocol" validate:"required"`
+	MinProtocol int    `json:"minProtocol,omitempty" validate:"omitempty,min=1,ltefield=Protocol"`
 }
 
 type GsNewSessionRequest struct {
@@ -63,3 +67,20 @@ type GsScoreSubmitRequest struct {
 	Player1 *gsScoreSubmitPlayerData `json:"player1"`
 	Player2 *gsScoreSubmitPlayerData `json:"player2"`
 }
+
+type LauncherUnlocksListRequest struct {
+	Id string `json:"-"`
+}
+
+type LauncherUnlocksDownloadRequest struct {
+	Id       string `json:"-"`
+	UnlockId string `json:"unlockId" validate:"required"`
+}
+
+// LauncherUnlocksUnpackRequest unpacks an unlock, downloading it first if
+// needed. ProfileName selects the user when unlocks are separated by user.
+type LauncherUnlocksUnpackRequest struct {
+	Id          string `json:"-"`
+	UnlockId    string `json:"unlockId" validate:"required"`
+	ProfileName string `json:"profileName"`
+}
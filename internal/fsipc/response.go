@@ -1,5 +1,7 @@
 package fsipc
 
+import "time"
+
 type PingVersion struct {
 	Major int `json:"major"`
 	Minor int `json:"minor"`
@@ -8,9 +10,69 @@ type PingVersion struct {
 
 type PingResponse struct {
 	Version PingVersion `json:"version"`
+
+	// added in protocol 2
+	Protocol        int      `json:"protocol"`
+	LauncherVersion string   `json:"launcherVersion"`
+	Actions         []string `json:"actions"`
+	Features        []string `json:"features"`
 }
 
 type NetworkResponse struct {
 	Status string      `json:"status"`
 	Data   interface{} `json:"data"`
+
+	// why the request wasn't sent and when it will be allowed again, only
+	// set for disabled responses
+	Reason  string     `json:"reason,omitempty"`
+	RetryAt *time.Time `json:"retryAt,omitempty"`
+
+	// set if a score couldn't be submitted and is submitted later instead
+	Queued bool `json:"queued,omitempty"`
+}
+
+type LauncherUnlockUser struct {
+	ProfileName  string `json:"profileName"`
+	UnpackStatus string `json:"unpackStatus"`
+	UnpackError  string `json:"unpackError,omitempty"`
+}
+
+type LauncherUnlock struct {
+	Id               string               `json:"id"`
+	RpgName          string               `json:"rpgName"`
+	QuestTitle       string               `json:"questTitle"`
+	SongDescriptions []string             `json:"songDescriptions"`
+	DownloadStatus   string               `json:"downloadStatus"`
+	DownloadError    string               `json:"downloadError,omitempty"`
+	DownloadSize     int64                `json:"downloadSize"`
+	DownloadProgress int64                `json:"downloadProgress"`
+	Users            []LauncherUnlockUser `json:"users"`
+}
+
+type LauncherUnlocksResponse struct {
+	// whether unlocks are unpacked separately for every user
+	UserUnlocks bool             `json:"userUnlocks"`
+	Unlocks     []LauncherUnlock `json:"unlocks"`
+}
+
+// SupersededResponse answers a request that was dropped because the theme
+// sent a different request of the same action before it was handled.
+type SupersededResponse struct {
+	Status string `json:"status"`
+}
+
+func NewSupersededResponse() *SupersededResponse {
+	return &SupersededResponse{Status: "superseded"}
+}
+
+type InvalidResponse struct {
+	Status string        `json:"status"`
+	Error  *RequestError `json:"error"`
+}
+
+func NewInvalidResponse(err *RequestError) *InvalidResponse {
+	return &InvalidResponse{
+		Status: "invalid",
+		Error:  err,
+	}
 }
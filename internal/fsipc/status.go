@@ -0,0 +1,65 @@
+package fsipc
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/GrooveStats/gslauncher/internal/settings"
+)
+
+const statusFilename = "status.json"
+
+// Status is written to status.json in the root directory, so themes can
+// find out about the state of the launcher without sending a request.
+type Status struct {
+	Version         PingVersion `json:"version"`
+	LauncherVersion string      `json:"launcherVersion"`
+	Protocol        int         `json:"protocol"`
+	Pid             int         `json:"pid"`
+	Heartbeat       time.Time   `json:"heartbeat"`
+
+	// the response to the last new-session request, nil before the first
+	// one succeeded
+	NewSession interface{} `json:"newSession"`
+
+	// set when requests to any GrooveStats endpoint are disabled due to a
+	// protocol violation
+	PermanentError bool `json:"permanentError"`
+
+	// the circuit breaker state of every GrooveStats endpoint
+	Endpoints interface{} `json:"endpoints"`
+
+	// when GrooveStats became unreachable, nil while it can be reached
+	OfflineSince *time.Time `json:"offlineSince"`
+
+	PendingUnlocks int `json:"pendingUnlocks"`
+}
+
+// WriteStatus replaces the status file. It does nothing once the FsIpc is
+// closed.
+func (fsipc *FsIpc) WriteStatus(status *Status) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+
+	fsipc.mutex.RLock()
+	defer fsipc.mutex.RUnlock()
+
+	if fsipc.closed {
+		return nil
+	}
+
+	filename := filepath.Join(fsipc.RootDir, statusFilename)
+	return writeFileAtomic(filename, data, settings.Get().IpcFsync)
+}
+
+func (fsipc *FsIpc) removeStatus() error {
+	err := os.Remove(filepath.Join(fsipc.RootDir, statusFilename))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,205 @@
+package fsipc
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/GrooveStats/gslauncher/internal/settings"
+)
+
+const (
+	// how often the request directory is scanned in polling mode
+	pollInterval = 250 * time.Millisecond
+
+	// When using fsnotify the request directory is still scanned from time
+	// to time to pick up requests the watcher missed.
+	sweepInterval = 5 * time.Second
+
+	// how long to wait for the watcher to report the self-test file
+	selfTestTimeout = 2 * time.Second
+
+	selfTestName = ".watcher-selftest"
+
+	// SM only waits this long for a reply
+	staleRequestAge = time.Minute
+)
+
+func (fsipc *FsIpc) startWatcher() error {
+	if fsipc.watchMode == settings.WatchPoll {
+		fsipc.polling = true
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err == nil {
+		err = watcher.Add(fsipc.requestDir)
+		if err != nil {
+			watcher.Close()
+		}
+	}
+
+	if err != nil {
+		if fsipc.watchMode == settings.WatchFsnotify {
+			return err
+		}
+
+		fsipc.logger.Warn("failed to watch request directory, polling instead", "err", err)
+		fsipc.polling = true
+		return nil
+	}
+
+	fsipc.watcher = watcher
+	return nil
+}
+
+func (fsipc *FsIpc) loop() {
+	defer fsipc.wg.Done()
+
+	var events chan fsnotify.Event
+	var errors chan error
+	if fsipc.watcher != nil {
+		events = fsipc.watcher.Events
+		errors = fsipc.watcher.Errors
+	}
+
+	interval := sweepInterval
+	if fsipc.polling {
+		interval = pollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// Some file systems (network shares, FUSE mounts, ...) never report
+	// any events. Create a file to find out whether the watcher works.
+	var selfTest <-chan time.Time
+	if fsipc.watchMode == settings.WatchAuto && !fsipc.polling {
+		err := os.WriteFile(filepath.Join(fsipc.requestDir, selfTestName), []byte{}, 0600)
+		if err != nil {
+			fsipc.logger.Warn("failed to create self-test file", "err", err)
+		} else {
+			timer := time.NewTimer(selfTestTimeout)
+			defer timer.Stop()
+			selfTest = timer.C
+		}
+	}
+
+	switchToPolling := func(reason string) {
+		if fsipc.polling || fsipc.watchMode != settings.WatchAuto {
+			return
+		}
+
+		fsipc.logger.Warn("switching to polling", "reason", reason)
+		fsipc.polling = true
+		ticker.Reset(pollInterval)
+		fsipc.scanRequests()
+	}
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				events = nil
+				switchToPolling("watcher closed")
+				continue
+			}
+
+			if filepath.Base(event.Name) == selfTestName {
+				if selfTest != nil {
+					selfTest = nil
+					os.Remove(event.Name)
+				}
+				continue
+			}
+
+			if event.Op&fsnotify.Create == fsnotify.Create {
+				fsipc.dispatchFile(event.Name)
+			}
+		case err, ok := <-errors:
+			if !ok {
+				errors = nil
+				continue
+			}
+
+			fsipc.logger.Warn("fsnotify error", "err", err)
+
+			if err == fsnotify.ErrEventOverflow {
+				// events got lost, look for requests right away
+				fsipc.scanRequests()
+			} else {
+				switchToPolling("watcher failed")
+			}
+		case <-selfTest:
+			selfTest = nil
+			os.Remove(filepath.Join(fsipc.requestDir, selfTestName))
+			switchToPolling("watcher didn't report the self-test file")
+		case <-ticker.C:
+			fsipc.pruneHandled()
+			fsipc.scanRequests()
+		case <-fsipc.shutdown:
+			return
+		}
+	}
+}
+
+// scanRequests looks for request files that haven't been handled yet.
+func (fsipc *FsIpc) scanRequests() {
+	entries, err := os.ReadDir(fsipc.requestDir)
+	if err != nil {
+		fsipc.logger.Warn("failed to scan request directory", "err", err)
+		return
+	}
+
+	for _, entry := range entries {
+		fsipc.dispatchFile(filepath.Join(fsipc.requestDir, entry.Name()))
+	}
+}
+
+// dispatchFile handles a request file unless it has been seen before. Both
+// the watcher and the directory scan report files, so this makes sure every
+// request is handled exactly once.
+func (fsipc *FsIpc) dispatchFile(filename string) {
+	if !strings.HasSuffix(filename, ".json") {
+		return
+	}
+
+	basename := filepath.Base(filename)
+
+	fsipc.handledMutex.Lock()
+	_, ok := fsipc.handled[basename]
+	if !ok {
+		fsipc.handled[basename] = time.Now()
+	}
+	fsipc.handledMutex.Unlock()
+
+	if ok {
+		return
+	}
+
+	fsipc.wg.Add(1)
+	go fsipc.handleFile(filename)
+}
+
+// forget allows a request file to be dispatched again. It is called when the
+// file is gone or couldn't be read.
+func (fsipc *FsIpc) forget(basename string) {
+	fsipc.handledMutex.Lock()
+	delete(fsipc.handled, basename)
+	fsipc.handledMutex.Unlock()
+}
+
+// pruneHandled forgets the files that couldn't be deleted. They are stale by
+// now and would only be discarded if they are still around.
+func (fsipc *FsIpc) pruneHandled() {
+	fsipc.handledMutex.Lock()
+	defer fsipc.handledMutex.Unlock()
+
+	for basename, dispatched := range fsipc.handled {
+		if time.Since(dispatched) > staleRequestAge {
+			delete(fsipc.handled, basename)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package groovestats
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+type breakerState int
+
+const (
+	// requests are sent
+	breakerClosed breakerState = iota
+
+	// requests are rejected until the cooldown is over
+	breakerOpen
+
+	// the cooldown is over and a single probe request is let through
+	breakerHalfOpen
+)
+
+func (state breakerState) String() string {
+	switch state {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+const (
+	breakerMinCooldown = time.Minute
+	breakerMaxCooldown = 30 * time.Minute
+)
+
+// protocolError is returned for responses that suggest the launcher is using
+// the API in the wrong way, e.g. 4xx status codes. Sending more requests
+// to the endpoint won't help, so its circuit breaker is opened.
+type protocolError struct {
+	err error
+}
+
+func (e *protocolError) Error() string {
+	return e.err.Error()
+}
+
+func (e *protocolError) Unwrap() error {
+	return e.err
+}
+
+// breaker stops requests to an endpoint after a protocol violation. Once the
+// cooldown is over a probe request is sent. If it fails again the cooldown is
+// doubled.
+type breaker struct {
+	mutex    sync.Mutex
+	state    breakerState
+	reason   string
+	cooldown time.Duration
+	retryAt  time.Time
+	probing  bool
+}
+
+func newBreaker() *breaker {
+	return &breaker{
+		state:    breakerClosed,
+		cooldown: breakerMinCooldown,
+	}
+}
+
+// allow returns a DisabledError if no request must be sent right now.
+func (b *breaker) allow(now time.Time) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.state == breakerOpen && !now.Before(b.retryAt) {
+		b.state = breakerHalfOpen
+	}
+
+	switch b.state {
+	case breakerOpen:
+		return &DisabledError{reason: b.reason, retryAt: b.retryAt}
+	case breakerHalfOpen:
+		if b.probing {
+			return &DisabledError{reason: b.reason + " (probing)", retryAt: b.retryAt}
+		}
+		b.probing = true
+	}
+
+	return nil
+}
+
+// done records the outcome of a request that was allowed.
+func (b *breaker) done(err error, now time.Time) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	wasProbing := b.probing
+	b.probing = false
+
+	var violation *protocolError
+	var networkError *NetworkError
+
+	if errors.As(err, &violation) {
+		if b.state == breakerHalfOpen && wasProbing {
+			b.cooldown *= 2
+			if b.cooldown > breakerMaxCooldown {
+				b.cooldown = breakerMaxCooldown
+			}
+		}
+
+		b.state = breakerOpen
+		b.reason = "protocol violation: " + err.Error()
+		b.retryAt = now.Add(b.cooldown)
+		return
+	}
+
+	if errors.As(err, &networkError) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		// doesn't tell anything about the endpoint, the next request
+		// probes again
+		return
+	}
+
+	b.state = breakerClosed
+	b.reason = ""
+	b.cooldown = breakerMinCooldown
+	b.retryAt = time.Time{}
+}
+
+// BreakerStatus describes the circuit breaker of an endpoint.
+type BreakerStatus struct {
+	State   string     `json:"state"`
+	Reason  string     `json:"reason,omitempty"`
+	RetryAt *time.Time `json:"retryAt,omitempty"`
+}
+
+func (b *breaker) status() BreakerStatus {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	status := BreakerStatus{
+		State:  b.state.String(),
+		Reason: b.reason,
+	}
+
+	if b.state != breakerClosed {
+		retryAt := b.retryAt
+		status.RetryAt = &retryAt
+	}
+
+	return status
+}
+
+// Breakers returns the state of the circuit breakers by endpoint name, e.g.
+// "player-scores".
+func (client *Client) Breakers() map[string]BreakerStatus {
+	statuses := make(map[string]BreakerStatus)
+
+	for endpoint, b := range client.breakers {
+		statuses[endpointName(endpoint)] = b.status()
+	}
+
+	return statuses
+}
+
+// Disabled reports whether requests to any endpoint are currently disabled.
+func (client *Client) Disabled() bool {
+	for _, b := range client.breakers {
+		if b.status().State != breakerClosed.String() {
+			return true
+		}
+	}
+
+	return false
+}
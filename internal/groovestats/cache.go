@@ -1,101 +1,273 @@
 package groovestats
 
 import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/GrooveStats/gslauncher/internal/fsipc"
+	"github.com/GrooveStats/gslauncher/internal/settings"
 )
 
-type playerScoresCacheEntry struct {
-	data      *playerScoresPlayerData
-	retrieved time.Time
+// Entries that haven't been refreshed for this long are removed when the
+// launcher starts.
+const maxCacheAge = 30 * 24 * time.Hour
+
+type cacheEntry struct {
+	Retrieved time.Time       `json:"retrieved"`
+	Data      json.RawMessage `json:"data"`
 }
 
-const timeout = 15 * time.Minute
+// diskCache stores the data of a single player for a single endpoint in a
+// file. File names start with a hash of the chart hash and the api key, so
+// all entries for a player on a chart can be found without storing the api
+// key in plain text.
+type diskCache struct {
+	dir    string
+	mutex  sync.Mutex
+	logger *slog.Logger
+}
 
-func (client *Client) addPlayerScores(request *fsipc.GsPlayerScoresRequest, response *PlayerScoresResponse) {
-	now := time.Now()
+func newDiskCache(dir string, logger *slog.Logger) *diskCache {
+	cache := &diskCache{
+		dir:    dir,
+		logger: logger,
+	}
+
+	err := os.MkdirAll(dir, os.ModeDir|0700)
+	if err != nil {
+		logger.Error("failed to create cache directory", "err", err)
+	}
+
+	cache.prune(time.Now())
+
+	return cache
+}
 
-	setPlayerData := func(chartHash, apiKey string, data *playerScoresPlayerData) {
-		key := "player-scores:" + chartHash + "," + apiKey
+func playerCacheKey(chartHash, apiKey string) string {
+	sum := sha256.Sum256([]byte(chartHash + "," + apiKey))
+	return fmt.Sprintf("%x", sum[:16])
+}
+
+func (cache *diskCache) path(kind, chartHash, apiKey string) string {
+	return filepath.Join(cache.dir, playerCacheKey(chartHash, apiKey)+"."+kind+".json")
+}
 
-		client.cache.Add(key, playerScoresCacheEntry{
-			data:      data,
-			retrieved: now,
-		})
+func (cache *diskCache) get(kind, chartHash, apiKey string) *cacheEntry {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	data, err := os.ReadFile(cache.path(kind, chartHash, apiKey))
+	if err != nil {
+		return nil
 	}
 
-	if request.Player1 != nil {
-		player := request.Player1
-		setPlayerData(player.ChartHash, player.ApiKey, response.Player1)
+	var entry cacheEntry
+	err = json.Unmarshal(data, &entry)
+	if err != nil {
+		return nil
 	}
 
-	if request.Player2 != nil {
-		player := request.Player2
-		setPlayerData(player.ChartHash, player.ApiKey, response.Player2)
+	return &entry
+}
+
+func (cache *diskCache) add(kind, chartHash, apiKey string, value interface{}, retrieved time.Time) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+
+	data, err = json.Marshal(cacheEntry{Retrieved: retrieved, Data: data})
+	if err != nil {
+		return
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	path := cache.path(kind, chartHash, apiKey)
+	tmpPath := path + ".tmp"
+
+	err = os.WriteFile(tmpPath, data, 0600)
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		cache.logger.Warn("failed to write cache entry", "err", err)
+		os.Remove(tmpPath)
+	}
+}
+
+// remove deletes the entries of all endpoints for a player on a chart.
+func (cache *diskCache) remove(chartHash, apiKey string) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	paths, _ := filepath.Glob(filepath.Join(cache.dir, playerCacheKey(chartHash, apiKey)+".*"))
+	for _, path := range paths {
+		os.Remove(path)
+	}
+}
+
+func (cache *diskCache) prune(now time.Time) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	dirEntries, err := os.ReadDir(cache.dir)
+	if err != nil {
+		return
+	}
+
+	for _, dirEntry := range dirEntries {
+		info, err := dirEntry.Info()
+		if err != nil {
+			continue
+		}
+
+		if strings.HasSuffix(dirEntry.Name(), ".tmp") || now.Sub(info.ModTime()) > maxCacheAge {
+			os.Remove(filepath.Join(cache.dir, dirEntry.Name()))
+		}
 	}
 }
 
-func (client *Client) getPlayerScores(request *fsipc.GsPlayerScoresRequest) *PlayerScoresResponse {
+// cacheResult tells how much of a cached response can be used.
+type cacheResult int
+
+const (
+	cacheMiss cacheResult = iota
+	cacheStale
+	cacheFresh
+)
+
+// lookup fills data with the cached entries of the given players. data holds
+// pointers to the player fields of the response. Players without a chart hash
+// are skipped. The result is only as good as the oldest entry, whose
+// retrieval time is returned as well.
+func (cache *diskCache) lookup(kind string, ttl time.Duration, chartHashes, apiKeys [2]string, data [2]interface{}) (cacheResult, time.Time) {
 	now := time.Now()
+	result := cacheMiss
+	var oldest time.Time
+
+	for i := range chartHashes {
+		if chartHashes[i] == "" {
+			continue
+		}
 
-	getPlayerData := func(chartHash, apiKey string) *playerScoresPlayerData {
-		key := "player-scores:" + chartHash + "," + apiKey
+		entry := cache.get(kind, chartHashes[i], apiKeys[i])
+		if entry == nil {
+			return cacheMiss, time.Time{}
+		}
 
-		iEntry, ok := client.cache.Get(key)
-		if !ok {
-			return nil
+		err := json.Unmarshal(entry.Data, data[i])
+		if err != nil {
+			return cacheMiss, time.Time{}
 		}
 
-		entry := iEntry.(playerScoresCacheEntry)
-		if now.Sub(entry.retrieved) > timeout {
-			client.cache.Remove(key)
-			return nil
+		if oldest.IsZero() || entry.Retrieved.Before(oldest) {
+			oldest = entry.Retrieved
 		}
 
-		return entry.data
+		if now.Sub(entry.Retrieved) > ttl {
+			result = cacheStale
+		} else if result == cacheMiss {
+			result = cacheFresh
+		}
 	}
 
-	response := PlayerScoresResponse{Cached: true}
+	return result, oldest
+}
 
-	if request.Player1 != nil {
-		player := request.Player1
-		data := getPlayerData(player.ChartHash, player.ApiKey)
+func playerScoresTtl() time.Duration {
+	return time.Duration(settings.Get().CachePlayerScoresTtl) * time.Minute
+}
 
-		if data == nil {
-			return nil
-		}
+func playerLeaderboardsTtl() time.Duration {
+	return time.Duration(settings.Get().CachePlayerLeaderboardsTtl) * time.Minute
+}
 
-		response.Player1 = data
+func leaderboardsCacheKind(maxResults *int) string {
+	if maxResults == nil {
+		return "player-leaderboards"
 	}
+	return fmt.Sprintf("player-leaderboards-%d", *maxResults)
+}
 
-	if request.Player2 != nil {
+func (client *Client) addPlayerScores(request *fsipc.GsPlayerScoresRequest, response *PlayerScoresResponse) {
+	now := time.Now()
+
+	if request.Player1 != nil && response.Player1 != nil {
+		player := request.Player1
+		client.cache.add("player-scores", player.ChartHash, player.ApiKey, response.Player1, now)
+	}
+
+	if request.Player2 != nil && response.Player2 != nil {
 		player := request.Player2
-		data := getPlayerData(player.ChartHash, player.ApiKey)
+		client.cache.add("player-scores", player.ChartHash, player.ApiKey, response.Player2, now)
+	}
+}
 
-		if data == nil {
-			return nil
-		}
+func (client *Client) getPlayerScores(chartHashes, apiKeys [2]string) (*PlayerScoresResponse, cacheResult) {
+	response := PlayerScoresResponse{Cached: true}
+	data := [2]interface{}{&response.Player1, &response.Player2}
+
+	result, retrieved := client.cache.lookup("player-scores", playerScoresTtl(), chartHashes, apiKeys, data)
+	if result == cacheMiss {
+		return nil, cacheMiss
+	}
+	if result == cacheStale {
+		response.StaleSince = &retrieved
+	}
+
+	return &response, result
+}
+
+func (client *Client) addPlayerLeaderboards(request *fsipc.GsPlayerLeaderboardsRequest, response *PlayerLeaderboardsResponse) {
+	now := time.Now()
+	kind := leaderboardsCacheKind(request.MaxLeaderboardResults)
 
-		response.Player2 = data
+	if request.Player1 != nil && response.Player1 != nil {
+		player := request.Player1
+		client.cache.add(kind, player.ChartHash, player.ApiKey, response.Player1, now)
 	}
 
-	return &response
+	if request.Player2 != nil && response.Player2 != nil {
+		player := request.Player2
+		client.cache.add(kind, player.ChartHash, player.ApiKey, response.Player2, now)
+	}
 }
 
-func (client *Client) removePlayerScores(request *fsipc.GsScoreSubmitRequest) {
-	removePlayerData := func(chartHash, apiKey string) {
-		key := "player-scores:" + chartHash + "," + apiKey
-		client.cache.Remove(key)
+func (client *Client) getPlayerLeaderboards(maxResults *int, chartHashes, apiKeys [2]string) (*PlayerLeaderboardsResponse, cacheResult) {
+	response := PlayerLeaderboardsResponse{Cached: true}
+	data := [2]interface{}{&response.Player1, &response.Player2}
+
+	kind := leaderboardsCacheKind(maxResults)
+	result, retrieved := client.cache.lookup(kind, playerLeaderboardsTtl(), chartHashes, apiKeys, data)
+	if result == cacheMiss {
+		return nil, cacheMiss
+	}
+	if result == cacheStale {
+		response.StaleSince = &retrieved
 	}
 
+	return &response, result
+}
+
+// removePlayerData drops the cached scores and leaderboards of the charts a
+// score was submitted for, they are outdated now.
+func (client *Client) removePlayerData(request *fsipc.GsScoreSubmitRequest) {
 	if request.Player1 != nil {
 		player := request.Player1
-		removePlayerData(player.ChartHash, player.ApiKey)
+		client.cache.remove(player.ChartHash, player.ApiKey)
 	}
 
 	if request.Player2 != nil {
 		player := request.Player2
-		removePlayerData(player.ChartHash, player.ApiKey)
+		client.cache.remove(player.ChartHash, player.ApiKey)
 	}
 }
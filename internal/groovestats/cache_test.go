@@ -0,0 +1,203 @@
+package groovestats
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GrooveStats/gslauncher/internal/fsipc"
+	"github.com/GrooveStats/gslauncher/internal/settings"
+)
+
+const playerScoresJson = `{
+	"player1": {
+		"chartHash": "hash",
+		"isRanked": true,
+		"gsLeaderboard": []
+	}
+}`
+
+const playerLeaderboardsJson = `{
+	"player1": {
+		"chartHash": "hash",
+		"isRanked": true,
+		"gsLeaderboard": []
+	}
+}`
+
+const scoreSubmitJson = `{
+	"player1": {
+		"chartHash": "hash",
+		"isRanked": true,
+		"result": "score-added",
+		"gsLeaderboard": []
+	}
+}`
+
+// newCacheTestClient returns a client that counts the requests per endpoint
+// and is allowed to use all of them.
+func newCacheTestClient(t *testing.T, hits map[string]*int32) *Client {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		endpoint := strings.TrimPrefix(r.URL.Path, "/")
+		atomic.AddInt32(hits[endpoint], 1)
+
+		switch endpoint {
+		case "new-session.php":
+			w.Write([]byte(newSessionJson))
+		case "player-scores.php":
+			w.Write([]byte(playerScoresJson))
+		case "player-leaderboards.php":
+			w.Write([]byte(playerLeaderboardsJson))
+		case "score-submit.php":
+			w.Write([]byte(scoreSubmitJson))
+		}
+	})
+
+	_, err := client.NewSession(context.Background(), &fsipc.GsNewSessionRequest{ChartHashVersion: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client
+}
+
+func mustUnmarshal(t *testing.T, data string, v interface{}) {
+	err := json.Unmarshal([]byte(data), v)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newHits() map[string]*int32 {
+	return map[string]*int32{
+		"new-session.php":         new(int32),
+		"player-scores.php":       new(int32),
+		"player-leaderboards.php": new(int32),
+		"score-submit.php":        new(int32),
+	}
+}
+
+func TestCache(t *testing.T) {
+	hits := newHits()
+	client := newCacheTestClient(t, hits)
+
+	scoresRequest := &fsipc.GsPlayerScoresRequest{}
+	playerJson := `{"player1": {"chartHash": "hash", "apiKey": "key"}}`
+	mustUnmarshal(t, playerJson, scoresRequest)
+
+	leaderboardsRequest := &fsipc.GsPlayerLeaderboardsRequest{}
+	mustUnmarshal(t, playerJson, leaderboardsRequest)
+
+	for i := 0; i < 2; i++ {
+		scores, err := client.PlayerScores(context.Background(), scoresRequest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if scores.Cached != (i == 1) || scores.Player1 == nil {
+			t.Fatalf("unexpected player scores response %d: %+v", i, scores)
+		}
+
+		leaderboards, err := client.PlayerLeaderboards(context.Background(), leaderboardsRequest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if leaderboards.Cached != (i == 1) || leaderboards.Player1 == nil {
+			t.Fatalf("unexpected player leaderboards response %d: %+v", i, leaderboards)
+		}
+	}
+
+	if *hits["player-scores.php"] != 1 || *hits["player-leaderboards.php"] != 1 {
+		t.Fatalf("expected one request per endpoint, got %d and %d", *hits["player-scores.php"], *hits["player-leaderboards.php"])
+	}
+
+	// the cache survives restarts
+	restarted, err := NewClient(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarted.cache = newDiskCache(client.cache.dir, restarted.logger)
+	scores, err := restarted.PlayerScores(context.Background(), scoresRequest)
+	if err != nil || !scores.Cached {
+		t.Fatalf("expected cached response after restart: %v", err)
+	}
+
+	// a different api key must not see the cached data
+	otherRequest := &fsipc.GsPlayerScoresRequest{}
+	mustUnmarshal(t, `{"player1": {"chartHash": "hash", "apiKey": "other"}}`, otherRequest)
+	scores, err = client.PlayerScores(context.Background(), otherRequest)
+	if err != nil || scores.Cached {
+		t.Fatalf("unexpected cached response for another api key: %v", err)
+	}
+
+	submitRequest := &fsipc.GsScoreSubmitRequest{}
+	mustUnmarshal(t, `{"player1": {"chartHash": "hash", "apiKey": "key", "score": 9000, "rate": 100}}`, submitRequest)
+	_, err = client.ScoreSubmit(context.Background(), submitRequest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scores, err = client.PlayerScores(context.Background(), scoresRequest)
+	if err != nil || scores.Cached {
+		t.Fatalf("expected fresh player scores after score submit: %v", err)
+	}
+
+	leaderboards, err := client.PlayerLeaderboards(context.Background(), leaderboardsRequest)
+	if err != nil || leaderboards.Cached {
+		t.Fatalf("expected fresh player leaderboards after score submit: %v", err)
+	}
+}
+
+func TestCacheStaleWhileRevalidate(t *testing.T) {
+	hits := newHits()
+	client := newCacheTestClient(t, hits)
+
+	newSettings := settings.Get()
+	newSettings.CachePlayerScoresTtl = 0
+	settings.Update(newSettings)
+
+	request := &fsipc.GsPlayerScoresRequest{}
+	mustUnmarshal(t, `{"player1": {"chartHash": "hash", "apiKey": "key"}}`, request)
+
+	_, err := client.PlayerScores(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// expired entries are refetched without stale-while-revalidate
+	time.Sleep(10 * time.Millisecond)
+	scores, err := client.PlayerScores(context.Background(), request)
+	if err != nil || scores.Cached {
+		t.Fatalf("expected fresh response: %v", err)
+	}
+	if *hits["player-scores.php"] != 2 {
+		t.Fatalf("expected 2 requests, got %d", *hits["player-scores.php"])
+	}
+
+	newSettings.CacheStaleWhileRevalidate = true
+	settings.Update(newSettings)
+
+	time.Sleep(10 * time.Millisecond)
+	scores, err = client.PlayerScores(context.Background(), request)
+	if err != nil || !scores.Cached {
+		t.Fatalf("expected stale response: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !refreshed(client, hits) {
+		if time.Now().After(deadline) {
+			t.Fatal("stale entry wasn't refreshed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func refreshed(client *Client, hits map[string]*int32) bool {
+	client.flights.mutex.Lock()
+	defer client.flights.mutex.Unlock()
+
+	return atomic.LoadInt32(hits["player-scores.php"]) == 3 && len(client.flights.calls) == 0
+}
@@ -2,131 +2,246 @@ package groovestats
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"path"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/hashicorp/golang-lru"
-
 	"github.com/GrooveStats/gslauncher/internal/fsipc"
+	"github.com/GrooveStats/gslauncher/internal/httpclient"
+	"github.com/GrooveStats/gslauncher/internal/logging"
+	"github.com/GrooveStats/gslauncher/internal/metrics"
 	"github.com/GrooveStats/gslauncher/internal/settings"
-	"github.com/GrooveStats/gslauncher/internal/stats"
 )
 
 type DisabledError struct {
 	reason string
+
+	// when the endpoint will be tried again, zero if it stays disabled
+	retryAt time.Time
 }
 
 func (e *DisabledError) Error() string {
-	return fmt.Sprintf("endpoint disabled: %s", e.reason)
+	if e.retryAt.IsZero() {
+		return fmt.Sprintf("endpoint disabled: %s", e.reason)
+	}
+	return fmt.Sprintf("endpoint disabled until %s: %s", e.retryAt.Format(time.RFC3339), e.reason)
 }
 
-type Client struct {
-	getClient  *http.Client
-	postClient *http.Client
-	cache      *lru.Cache
-	logger     *log.Logger
+func (e *DisabledError) Reason() string {
+	return e.reason
+}
+
+func (e *DisabledError) RetryAt() time.Time {
+	return e.retryAt
+}
+
+// NetworkError is returned when GrooveStats couldn't be reached or answered
+// with a server error. Requests that failed this way might succeed when they
+// are retried later.
+type NetworkError struct {
+	err error
 
+	// how long GrooveStats asked us to wait before trying again
+	retryAfter time.Duration
+}
+
+func (e *NetworkError) Error() string {
+	return e.err.Error()
+}
+
+func (e *NetworkError) Unwrap() error {
+	return e.err
+}
+
+// Client talks to GrooveStats. It is safe for concurrent use.
+type Client struct {
+	httpClient   *http.Client
+	cache        *diskCache
+	flights      flightGroup
+	connectivity connectivity
+	logger       *slog.Logger
+
+	timeouts      map[string]time.Duration
+	retryPolicies map[string]retryPolicy
+	breakers      map[string]*breaker
+
+	// cache refreshes running in the background, see Close
+	background       context.Context
+	cancelBackground context.CancelFunc
+	wg               sync.WaitGroup
+
+	mutex                   sync.Mutex
+	closed                  bool
+	fakeUrl                 string
 	allowScoreSubmit        bool
 	allowPlayerScores       bool
 	allowPlayerLeaderboards bool
-	permanentError          bool
 }
 
-func NewClient() *Client {
-	cache, _ := lru.New(512)
-	logger := log.New(log.Writer(), "[GS] ", log.LstdFlags|log.Lmsgprefix)
+// NewClient fails if the proxy or CA bundle settings are invalid. Falling
+// back to a default client would silently bypass them.
+func NewClient(cacheDir string) (*Client, error) {
+	httpClient, err := httpclient.New()
+	if err != nil {
+		return nil, err
+	}
+
+	logger := logging.New("GS")
+	cache := newDiskCache(filepath.Join(cacheDir, "groovestats-launcher", "gs-cache"), logger)
+	background, cancelBackground := context.WithCancel(context.Background())
+
+	timeout := time.Duration(settings.Get().HttpTimeout) * time.Second
+	submitTimeout := time.Duration(settings.Get().HttpSubmitTimeout) * time.Second
 
 	return &Client{
-		getClient:  &http.Client{Timeout: 12 * time.Second},
-		postClient: &http.Client{Timeout: 32 * time.Second},
+		httpClient: httpClient,
 		cache:      cache,
 		logger:     logger,
 
+		timeouts: map[string]time.Duration{
+			"/new-session.php":         timeout,
+			"/player-scores.php":       timeout,
+			"/player-leaderboards.php": timeout,
+			"/score-submit.php":        submitTimeout,
+		},
+
+		// score submissions aren't idempotent, so they are never retried
+		retryPolicies: map[string]retryPolicy{
+			"/new-session.php":         getRetry,
+			"/player-scores.php":       getRetry,
+			"/player-leaderboards.php": getRetry,
+			"/score-submit.php":        noRetry,
+		},
+		breakers: map[string]*breaker{
+			"/new-session.php":         newBreaker(),
+			"/player-scores.php":       newBreaker(),
+			"/player-leaderboards.php": newBreaker(),
+			"/score-submit.php":        newBreaker(),
+		},
+
+		background:       background,
+		cancelBackground: cancelBackground,
+
 		allowScoreSubmit:        false,
 		allowPlayerScores:       false,
 		allowPlayerLeaderboards: false,
-		permanentError:          false,
-	}
+	}, nil
 }
 
-func (client *Client) NewSession(request *fsipc.GsNewSessionRequest) (*NewSessionResponse, error) {
-	response, err := client.newSession(request)
+func (client *Client) NewSession(ctx context.Context, request *fsipc.GsNewSessionRequest) (*NewSessionResponse, error) {
+	response, err := client.newSession(ctx, request)
 	if err != nil {
-		client.logger.Print(err)
+		client.logger.Warn("request failed", "endpoint", "new-session", "err", err)
 	}
+	countRequest("new-session", false, err)
 	return response, err
 }
 
-func (client *Client) newSession(request *fsipc.GsNewSessionRequest) (*NewSessionResponse, error) {
-	stats.GsNewSessionCount++
-
-	if settings.Get().FakeGs {
-		response, err := fakeNewSession()
-		if err != nil {
-			return nil, err
-		}
-
-		client.allowScoreSubmit = response.ServicesAllowed.ScoreSubmit
-		client.allowPlayerScores = response.ServicesAllowed.PlayerScores
-		client.allowPlayerLeaderboards = response.ServicesAllowed.PlayerLeaderboards
-
-		return response, nil
-	}
-
-	client.permanentError = false
-
+func (client *Client) newSession(ctx context.Context, request *fsipc.GsNewSessionRequest) (*NewSessionResponse, error) {
 	params := url.Values{}
 	params.Add("chartHashVersion", strconv.Itoa(request.ChartHashVersion))
 
-	req, err := client.newGetRequest("/new-session.php", &params)
+	req, err := client.newGetRequest(ctx, "/new-session.php", &params)
 	if err != nil {
 		return nil, err
 	}
 
 	var response NewSessionResponse
-	err = client.doRequest(req, &response)
+	err = client.doRequestWithRetry("/new-session.php", req, &response)
 	if err != nil {
 		return nil, err
 	}
 
+	client.mutex.Lock()
 	client.allowScoreSubmit = response.ServicesAllowed.ScoreSubmit
 	client.allowPlayerScores = response.ServicesAllowed.PlayerScores
 	client.allowPlayerLeaderboards = response.ServicesAllowed.PlayerLeaderboards
+	client.mutex.Unlock()
 
 	return &response, nil
 }
 
-func (client *Client) PlayerScores(request *fsipc.GsPlayerScoresRequest) (*PlayerScoresResponse, error) {
-	response, err := client.playerScores(request)
+func (client *Client) PlayerScores(ctx context.Context, request *fsipc.GsPlayerScoresRequest) (*PlayerScoresResponse, error) {
+	response, err := client.playerScores(ctx, request)
 	if err != nil {
-		client.logger.Print(err)
+		client.logger.Warn("request failed", "endpoint", "player-scores", "err", err)
 	}
+	countRequest("player-scores", response != nil && response.Cached, err)
 	return response, err
 }
 
-func (client *Client) playerScores(request *fsipc.GsPlayerScoresRequest) (*PlayerScoresResponse, error) {
-	cachedResponse := client.getPlayerScores(request)
-	if cachedResponse != nil {
-		stats.GsPlayerScoresCachedCount++
+func (client *Client) playerScores(ctx context.Context, request *fsipc.GsPlayerScoresRequest) (*PlayerScoresResponse, error) {
+	var chartHashes, apiKeys [2]string
+	if request.Player1 != nil {
+		chartHashes[0] = request.Player1.ChartHash
+		apiKeys[0] = request.Player1.ApiKey
+	}
+	if request.Player2 != nil {
+		chartHashes[1] = request.Player2.ChartHash
+		apiKeys[1] = request.Player2.ApiKey
+	}
+
+	key := flightKey("/player-scores.php", chartHashes, apiKeys)
+	fetch := func(ctx context.Context) (interface{}, error) {
+		return client.fetchPlayerScores(ctx, request)
+	}
+
+	client.mutex.Lock()
+	allowed := client.allowPlayerScores
+	client.mutex.Unlock()
+
+	cachedResponse, result := client.getPlayerScores(chartHashes, apiKeys)
+	if result == cacheFresh {
+		return cachedResponse, nil
+	}
+
+	// answer right away while offline, the refresh finds out whether
+	// GrooveStats can be reached again
+	if result == cacheStale && !client.OfflineSince().IsZero() {
+		if allowed {
+			client.revalidate(key, fetch)
+		}
 		return cachedResponse, nil
 	}
 
-	if !client.allowPlayerScores {
+	if !allowed {
 		return nil, &DisabledError{reason: "not allowed to fetch player scores"}
 	}
 
-	stats.GsPlayerScoresCount++
+	if result == cacheStale && settings.Get().CacheStaleWhileRevalidate {
+		client.revalidate(key, fetch)
+		return cachedResponse, nil
+	}
+
+	response, err, shared := client.flights.do(ctx, key, fetch)
+	if shared {
+		metrics.GsDeduplicated.With("player-scores").Inc()
+	}
 
-	if settings.Get().FakeGs {
-		return fakePlayerScores(request)
+	var networkError *NetworkError
+	if errors.As(err, &networkError) && result == cacheStale {
+		client.logger.Info("answering with cached data", "endpoint", "player-scores", "err", err)
+		return cachedResponse, nil
+	}
+	if err != nil {
+		return nil, err
 	}
 
+	return response.(*PlayerScoresResponse), nil
+}
+
+func (client *Client) fetchPlayerScores(ctx context.Context, request *fsipc.GsPlayerScoresRequest) (*PlayerScoresResponse, error) {
 	params := url.Values{}
 	if request.Player1 != nil {
 		params.Add("chartHashP1", request.Player1.ChartHash)
@@ -135,7 +250,7 @@ func (client *Client) playerScores(request *fsipc.GsPlayerScoresRequest) (*Playe
 		params.Add("chartHashP2", request.Player2.ChartHash)
 	}
 
-	req, err := client.newGetRequest("/player-scores.php", &params)
+	req, err := client.newGetRequest(ctx, "/player-scores.php", &params)
 	if err != nil {
 		return nil, err
 	}
@@ -147,7 +262,7 @@ func (client *Client) playerScores(request *fsipc.GsPlayerScoresRequest) (*Playe
 	}
 
 	var response PlayerScoresResponse
-	err = client.doRequest(req, &response)
+	err = client.doRequestWithRetry("/player-scores.php", req, &response)
 	if err != nil {
 		return nil, err
 	}
@@ -157,25 +272,79 @@ func (client *Client) playerScores(request *fsipc.GsPlayerScoresRequest) (*Playe
 	return &response, nil
 }
 
-func (client *Client) PlayerLeaderboards(request *fsipc.GsPlayerLeaderboardsRequest) (*PlayerLeaderboardsResponse, error) {
-	response, err := client.playerLeaderboards(request)
+func (client *Client) PlayerLeaderboards(ctx context.Context, request *fsipc.GsPlayerLeaderboardsRequest) (*PlayerLeaderboardsResponse, error) {
+	response, err := client.playerLeaderboards(ctx, request)
 	if err != nil {
-		client.logger.Print(err)
+		client.logger.Warn("request failed", "endpoint", "player-leaderboards", "err", err)
 	}
+	countRequest("player-leaderboards", response != nil && response.Cached, err)
 	return response, err
 }
 
-func (client *Client) playerLeaderboards(request *fsipc.GsPlayerLeaderboardsRequest) (*PlayerLeaderboardsResponse, error) {
-	if !client.allowPlayerLeaderboards {
+func (client *Client) playerLeaderboards(ctx context.Context, request *fsipc.GsPlayerLeaderboardsRequest) (*PlayerLeaderboardsResponse, error) {
+	var chartHashes, apiKeys [2]string
+	if request.Player1 != nil {
+		chartHashes[0] = request.Player1.ChartHash
+		apiKeys[0] = request.Player1.ApiKey
+	}
+	if request.Player2 != nil {
+		chartHashes[1] = request.Player2.ChartHash
+		apiKeys[1] = request.Player2.ApiKey
+	}
+
+	key := flightKey("/player-leaderboards.php", chartHashes, apiKeys)
+	if request.MaxLeaderboardResults != nil {
+		key += ":" + strconv.Itoa(*request.MaxLeaderboardResults)
+	}
+	fetch := func(ctx context.Context) (interface{}, error) {
+		return client.fetchPlayerLeaderboards(ctx, request)
+	}
+
+	client.mutex.Lock()
+	allowed := client.allowPlayerLeaderboards
+	client.mutex.Unlock()
+
+	cachedResponse, result := client.getPlayerLeaderboards(request.MaxLeaderboardResults, chartHashes, apiKeys)
+	if result == cacheFresh {
+		return cachedResponse, nil
+	}
+
+	// answer right away while offline, the refresh finds out whether
+	// GrooveStats can be reached again
+	if result == cacheStale && !client.OfflineSince().IsZero() {
+		if allowed {
+			client.revalidate(key, fetch)
+		}
+		return cachedResponse, nil
+	}
+
+	if !allowed {
 		return nil, &DisabledError{reason: "not allowed to fetch player leaderboards"}
 	}
 
-	stats.GsPlayerLeaderboardsCount++
+	if result == cacheStale && settings.Get().CacheStaleWhileRevalidate {
+		client.revalidate(key, fetch)
+		return cachedResponse, nil
+	}
+
+	response, err, shared := client.flights.do(ctx, key, fetch)
+	if shared {
+		metrics.GsDeduplicated.With("player-leaderboards").Inc()
+	}
 
-	if settings.Get().FakeGs {
-		return fakePlayerLeaderboards(request)
+	var networkError *NetworkError
+	if errors.As(err, &networkError) && result == cacheStale {
+		client.logger.Info("answering with cached data", "endpoint", "player-leaderboards", "err", err)
+		return cachedResponse, nil
+	}
+	if err != nil {
+		return nil, err
 	}
 
+	return response.(*PlayerLeaderboardsResponse), nil
+}
+
+func (client *Client) fetchPlayerLeaderboards(ctx context.Context, request *fsipc.GsPlayerLeaderboardsRequest) (*PlayerLeaderboardsResponse, error) {
 	params := url.Values{}
 	if request.Player1 != nil {
 		params.Add("chartHashP1", request.Player1.ChartHash)
@@ -187,7 +356,7 @@ func (client *Client) playerLeaderboards(request *fsipc.GsPlayerLeaderboardsRequ
 		params.Add("maxLeaderboardResults", strconv.Itoa(*request.MaxLeaderboardResults))
 	}
 
-	req, err := client.newGetRequest("/player-leaderboards.php", &params)
+	req, err := client.newGetRequest(ctx, "/player-leaderboards.php", &params)
 	if err != nil {
 		return nil, err
 	}
@@ -199,31 +368,32 @@ func (client *Client) playerLeaderboards(request *fsipc.GsPlayerLeaderboardsRequ
 	}
 
 	var response PlayerLeaderboardsResponse
-	err = client.doRequest(req, &response)
+	err = client.doRequestWithRetry("/player-leaderboards.php", req, &response)
 	if err != nil {
 		return nil, err
 	}
 
+	client.addPlayerLeaderboards(request, &response)
+
 	return &response, nil
 }
 
-func (client *Client) ScoreSubmit(request *fsipc.GsScoreSubmitRequest) (*ScoreSubmitResponse, error) {
-	response, err := client.scoreSubmit(request)
+func (client *Client) ScoreSubmit(ctx context.Context, request *fsipc.GsScoreSubmitRequest) (*ScoreSubmitResponse, error) {
+	response, err := client.scoreSubmit(ctx, request)
 	if err != nil {
-		client.logger.Print(err)
+		client.logger.Warn("request failed", "endpoint", "score-submit", "err", err)
 	}
+	countRequest("score-submit", false, err)
 	return response, err
 }
 
-func (client *Client) scoreSubmit(request *fsipc.GsScoreSubmitRequest) (*ScoreSubmitResponse, error) {
-	if !client.allowScoreSubmit {
-		return nil, &DisabledError{reason: "not allowed to submit scores"}
-	}
+func (client *Client) scoreSubmit(ctx context.Context, request *fsipc.GsScoreSubmitRequest) (*ScoreSubmitResponse, error) {
+	client.mutex.Lock()
+	allowed := client.allowScoreSubmit
+	client.mutex.Unlock()
 
-	stats.GsScoreSubmitCount++
-
-	if settings.Get().FakeGs {
-		return fakeScoreSubmit(request)
+	if !allowed {
+		return nil, &DisabledError{reason: "not allowed to submit scores"}
 	}
 
 	params := url.Values{}
@@ -270,7 +440,7 @@ func (client *Client) scoreSubmit(request *fsipc.GsScoreSubmitRequest) (*ScoreSu
 		}
 	}
 
-	req, err := client.newPostRequest("/score-submit.php", &params, &data)
+	req, err := client.newPostRequest(ctx, "/score-submit.php", &params, &data)
 	if err != nil {
 		return nil, err
 	}
@@ -284,27 +454,109 @@ func (client *Client) scoreSubmit(request *fsipc.GsScoreSubmitRequest) (*ScoreSu
 	}
 
 	var response ScoreSubmitResponse
-	err = client.doRequest(req, &response)
+	err = client.doRequestWithRetry("/score-submit.php", req, &response)
 	if err != nil {
 		return nil, err
 	}
 
-	client.removePlayerScores(request)
+	client.removePlayerData(request)
 
 	return &response, nil
 }
 
-func (client *Client) newGetRequest(path string, params *url.Values) (*http.Request, error) {
-	url := settings.Get().GrooveStatsUrl + path
+// countRequest records the outcome of a request from the theme.
+func countRequest(endpoint string, cached bool, err error) {
+	var disabledError *DisabledError
+
+	outcome := "success"
+	switch {
+	case errors.As(err, &disabledError):
+		outcome = "disabled"
+	case err != nil:
+		outcome = "fail"
+	case cached:
+		outcome = "cached"
+	}
+
+	metrics.GsRequests.With(endpoint, outcome).Inc()
+}
+
+// endpointName returns the name of the endpoint of a path, e.g.
+// "player-scores" for "/player-scores.php".
+func endpointName(p string) string {
+	return strings.TrimSuffix(path.Base(p), ".php")
+}
+
+// revalidate refreshes a stale cache entry in the background. The stale data
+// has been returned already, so errors are only logged.
+func (client *Client) revalidate(key string, fetch func(ctx context.Context) (interface{}, error)) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	if client.closed {
+		return
+	}
+
+	client.wg.Add(1)
+	go func() {
+		defer client.wg.Done()
+
+		_, err, _ := client.flights.do(client.background, key, fetch)
+		if err != nil {
+			client.logger.Warn("failed to refresh cache entry", "err", err)
+		}
+	}()
+}
+
+// Close cancels the cache refreshes running in the background and waits for
+// them to finish.
+func (client *Client) Close() {
+	client.mutex.Lock()
+	client.closed = true
+	client.mutex.Unlock()
+
+	client.cancelBackground()
+	client.wg.Wait()
+}
+
+// flightKey identifies a request by the endpoint, the charts and the api keys
+// of the players.
+func flightKey(endpoint string, chartHashes [2]string, apiKeys [2]string) string {
+	return fmt.Sprintf("%s:%s,%s:%s,%s", endpoint, chartHashes[0], apiKeys[0], chartHashes[1], apiKeys[1])
+}
+
+// SetFakeUrl sets the base url of a fake GrooveStats server. It is used
+// instead of the GrooveStats URL while requests are simulated.
+func (client *Client) SetFakeUrl(url string) {
+	client.mutex.Lock()
+	client.fakeUrl = url
+	client.mutex.Unlock()
+}
+
+func (client *Client) baseUrl() string {
+	client.mutex.Lock()
+	fakeUrl := client.fakeUrl
+	client.mutex.Unlock()
+
+	if settings.Get().FakeGs && fakeUrl != "" {
+		return fakeUrl
+	}
+
+	s := settings.Get()
+	return s.GrooveStatsApiUrl()
+}
+
+func (client *Client) newGetRequest(ctx context.Context, path string, params *url.Values) (*http.Request, error) {
+	url := client.baseUrl() + path
 	if params != nil {
 		url += "?" + params.Encode()
 	}
 
-	return http.NewRequest("GET", url, nil)
+	return http.NewRequestWithContext(ctx, "GET", url, nil)
 }
 
-func (client *Client) newPostRequest(path string, params *url.Values, data interface{}) (*http.Request, error) {
-	url := settings.Get().GrooveStatsUrl + path
+func (client *Client) newPostRequest(ctx context.Context, path string, params *url.Values, data interface{}) (*http.Request, error) {
+	url := client.baseUrl() + path
 	if params != nil {
 		url += "?" + params.Encode()
 	}
@@ -314,36 +566,38 @@ func (client *Client) newPostRequest(path string, params *url.Values, data inter
 		return nil, err
 	}
 
-	return http.NewRequest("POST", url, bytes.NewBuffer(body))
+	return http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
 }
 
-func (client *Client) doRequest(req *http.Request, response interface{}) error {
-	if client.permanentError {
-		return &DisabledError{reason: "request not sent due to protocol violation"}
-	}
+func (client *Client) doRequest(req *http.Request, timeout time.Duration, response interface{}) error {
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	defer cancel()
 
-	var resp *http.Response
-	var err error
+	// cancelled requests would only skew the latencies
+	start := time.Now()
+	defer func() {
+		if req.Context().Err() == nil {
+			metrics.GsRequestDuration.With(endpointName(req.URL.Path)).Observe(time.Since(start).Seconds())
+		}
+	}()
 
-	if req.Method == "GET" {
-		resp, err = client.getClient.Do(req)
-	} else {
-		resp, err = client.postClient.Do(req)
-	}
+	resp, err := client.httpClient.Do(req.WithContext(ctx))
 	if err != nil {
-		return err
+		return requestError(req, err)
 	}
 	defer resp.Body.Close()
 
-	client.logger.Printf("%s %v %s", req.Method, req.URL, resp.Status)
+	client.logger.Info("request", "method", req.Method, "url", req.URL.String(), "status", resp.Status, "duration", time.Since(start))
 
-	if resp.StatusCode >= 400 && resp.StatusCode < 499 && resp.StatusCode != 429 {
-		client.permanentError = true
-	}
+	violation := resp.StatusCode >= 400 && resp.StatusCode < 499 && resp.StatusCode != 429
 
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return err
+		return requestError(req, err)
+	}
+
+	if json.Valid(data) {
+		client.logger.Debug("response", "url", req.URL.String(), "data", json.RawMessage(data))
 	}
 
 	// Parse error response (if it actually is one)
@@ -354,15 +608,36 @@ func (client *Client) doRequest(req *http.Request, response interface{}) error {
 	json.Unmarshal(data, &errorResp)
 	if errorResp.Error != "" || errorResp.Message != "" {
 		if resp.StatusCode == 200 {
-			client.permanentError = true
+			violation = true
 		}
 
-		return fmt.Errorf("%s %v %d %v", req.Method, req.URL, resp.StatusCode, errorResp)
+		err = fmt.Errorf("%s %v %d %v", req.Method, req.URL, resp.StatusCode, errorResp)
+	} else if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err = fmt.Errorf("status code %s", resp.Status)
 	}
 
-	if resp.StatusCode < 200 || resp.StatusCode > 299 {
-		return fmt.Errorf("status code %s", resp.Status)
+	if err != nil {
+		if resp.StatusCode >= 500 || resp.StatusCode == 429 {
+			return &NetworkError{
+				err:        err,
+				retryAfter: parseRetryAfter(resp.Header, time.Now()),
+			}
+		}
+		if violation {
+			return &protocolError{err: err}
+		}
+		return err
 	}
 
 	return json.Unmarshal(data, response)
 }
+
+// requestError wraps errors of the transport in a NetworkError, unless the
+// request was cancelled.
+func requestError(req *http.Request, err error) error {
+	if req.Context().Err() != nil {
+		return fmt.Errorf("%s %v: %w", req.Method, req.URL, req.Context().Err())
+	}
+
+	return &NetworkError{err: err}
+}
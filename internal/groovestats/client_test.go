@@ -0,0 +1,523 @@
+package groovestats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GrooveStats/gslauncher/internal/fakegs"
+	"github.com/GrooveStats/gslauncher/internal/fsipc"
+	"github.com/GrooveStats/gslauncher/internal/settings"
+)
+
+const newSessionJson = `{
+	"activeEvents": [],
+	"servicesAllowed": {
+		"scoreSubmit": true,
+		"playerScores": true,
+		"playerLeaderboards": true
+	},
+	"servicesResult": "OK"
+}`
+
+// newTestClient returns a client talking to the given handler, with short
+// retry delays.
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	oldSettings := settings.Get()
+	t.Cleanup(func() { settings.Update(oldSettings) })
+
+	newSettings := settings.Get()
+	newSettings.GrooveStatsUrl = server.URL
+	newSettings.FakeGs = false
+	settings.Update(newSettings)
+
+	client, err := NewClient(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for endpoint := range client.timeouts {
+		client.timeouts[endpoint] = time.Second
+	}
+	for endpoint, policy := range client.retryPolicies {
+		policy.baseDelay = 10 * time.Millisecond
+		policy.maxDelay = 40 * time.Millisecond
+		client.retryPolicies[endpoint] = policy
+	}
+
+	return client
+}
+
+func TestRetry(t *testing.T) {
+	var hits int32
+
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Write([]byte(newSessionJson))
+	})
+
+	response, err := client.NewSession(context.Background(), &fsipc.GsNewSessionRequest{ChartHashVersion: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.ServicesResult != "OK" {
+		t.Fatal("unexpected response")
+	}
+	if hits != 3 {
+		t.Fatalf("expected 3 attempts, got %d", hits)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	var hits int32
+
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
+		w.Write([]byte(newSessionJson))
+	})
+
+	start := time.Now()
+
+	_, err := client.NewSession(context.Background(), &fsipc.GsNewSessionRequest{ChartHashVersion: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Fatalf("Retry-After not honored, retried after %v", elapsed)
+	}
+	if hits != 2 {
+		t.Fatalf("expected 2 attempts, got %d", hits)
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	var hits int32
+
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Header().Set("Retry-After", "5")
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	policy := client.retryPolicies["/new-session.php"]
+	policy.attempts = 10
+	policy.budget = 3 * time.Second
+	client.retryPolicies["/new-session.php"] = policy
+
+	start := time.Now()
+
+	_, err := client.NewSession(context.Background(), &fsipc.GsNewSessionRequest{ChartHashVersion: 3})
+
+	var networkError *NetworkError
+	if !errors.As(err, &networkError) {
+		t.Fatalf("expected a network error, got %v", err)
+	}
+
+	// waiting 5 seconds would exceed the budget
+	if hits != 1 {
+		t.Fatalf("expected 1 attempt, got %d", hits)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("gave up after %v", elapsed)
+	}
+}
+
+func TestRetryGiveUp(t *testing.T) {
+	var hits int32
+
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	_, err := client.NewSession(context.Background(), &fsipc.GsNewSessionRequest{ChartHashVersion: 3})
+
+	var networkError *NetworkError
+	if !errors.As(err, &networkError) {
+		t.Fatalf("expected a network error, got %v", err)
+	}
+
+	if hits != int32(getRetry.attempts) {
+		t.Fatalf("expected %d attempts, got %d", getRetry.attempts, hits)
+	}
+}
+
+func TestNoRetry(t *testing.T) {
+	var hits int32
+
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+
+		if r.URL.Path == "/new-session.php" {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+	client.allowScoreSubmit = true
+
+	// score submissions aren't idempotent
+	_, err := client.ScoreSubmit(context.Background(), &fsipc.GsScoreSubmitRequest{})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if hits != 1 {
+		t.Fatalf("expected 1 attempt, got %d", hits)
+	}
+
+	// client errors won't go away by retrying
+	_, err = client.NewSession(context.Background(), &fsipc.GsNewSessionRequest{ChartHashVersion: 3})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if hits != 2 {
+		t.Fatalf("expected 2 attempts, got %d", hits)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := retryPolicy{
+		attempts:  10,
+		baseDelay: 100 * time.Millisecond,
+		maxDelay:  time.Second,
+	}
+
+	expected := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	}
+
+	for i, max := range expected {
+		for j := 0; j < 20; j++ {
+			delay := policy.backoff(i + 1)
+			if delay < max/2 || delay > max {
+				t.Fatalf("retry %d: delay %v not between %v and %v", i+1, delay, max/2, max)
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := map[string]time.Duration{
+		"":                              0,
+		"3":                             3 * time.Second,
+		"-1":                            0,
+		"soon":                          0,
+		"Tue, 01 Mar 2022 12:00:30 GMT": 30 * time.Second,
+		"Tue, 01 Mar 2022 11:00:00 GMT": 0,
+	}
+
+	for value, expected := range tests {
+		header := http.Header{}
+		if value != "" {
+			header.Set("Retry-After", value)
+		}
+
+		if actual := parseRetryAfter(header, now); actual != expected {
+			t.Errorf("%q: expected %v, got %v", value, expected, actual)
+		}
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	var fail int32 = 1
+
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/player-leaderboards.php" && atomic.LoadInt32(&fail) == 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if r.URL.Path == "/new-session.php" {
+			w.Write([]byte(newSessionJson))
+			return
+		}
+
+		w.Write([]byte(`{}`))
+	})
+	client.allowPlayerLeaderboards = true
+
+	request := &fsipc.GsPlayerLeaderboardsRequest{}
+
+	_, err := client.PlayerLeaderboards(context.Background(), request)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	_, err = client.PlayerLeaderboards(context.Background(), request)
+
+	var disabledError *DisabledError
+	if !errors.As(err, &disabledError) {
+		t.Fatalf("expected the endpoint to be disabled, got %v", err)
+	}
+	if disabledError.RetryAt().Before(time.Now().Add(breakerMinCooldown / 2)) {
+		t.Fatalf("unexpected retry time %v", disabledError.RetryAt())
+	}
+
+	if status := client.Breakers()["player-leaderboards"]; status.State != "open" || status.RetryAt == nil {
+		t.Fatalf("unexpected breaker status %+v", status)
+	}
+	if !client.Disabled() {
+		t.Fatal("client not disabled")
+	}
+
+	// other endpoints are not affected
+	_, err = client.NewSession(context.Background(), &fsipc.GsNewSessionRequest{ChartHashVersion: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// end the cooldown, the next request is a probe
+	atomic.StoreInt32(&fail, 0)
+	b := client.breakers["/player-leaderboards.php"]
+	b.mutex.Lock()
+	b.retryAt = time.Now()
+	b.mutex.Unlock()
+
+	_, err = client.PlayerLeaderboards(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status := client.Breakers()["player-leaderboards"]; status.State != "closed" {
+		t.Fatalf("unexpected breaker status %+v", status)
+	}
+	if client.Disabled() {
+		t.Fatal("client still disabled")
+	}
+}
+
+func TestBreakerStates(t *testing.T) {
+	b := newBreaker()
+	now := time.Now()
+	violation := &protocolError{err: errors.New("bad request")}
+
+	if b.allow(now) != nil {
+		t.Fatal("closed breaker rejected request")
+	}
+	b.done(violation, now)
+
+	if b.allow(now.Add(breakerMinCooldown-time.Second)) == nil {
+		t.Fatal("open breaker allowed request")
+	}
+
+	// half-open, only one probe at a time
+	now = now.Add(breakerMinCooldown)
+	if b.allow(now) != nil {
+		t.Fatal("probe rejected")
+	}
+	if b.allow(now) == nil {
+		t.Fatal("second probe allowed")
+	}
+
+	// a failed probe doubles the cooldown
+	b.done(violation, now)
+	if b.allow(now.Add(breakerMinCooldown)) == nil {
+		t.Fatal("cooldown not doubled")
+	}
+
+	// network errors don't tell anything about the endpoint
+	now = now.Add(2 * breakerMinCooldown)
+	if b.allow(now) != nil {
+		t.Fatal("probe rejected")
+	}
+	b.done(&NetworkError{err: errors.New("timeout")}, now)
+	if b.state != breakerHalfOpen {
+		t.Fatalf("unexpected state %v", b.state)
+	}
+
+	if b.allow(now) != nil {
+		t.Fatal("probe rejected")
+	}
+	b.done(nil, now)
+	if b.state != breakerClosed || b.cooldown != breakerMinCooldown {
+		t.Fatal("breaker not reset")
+	}
+}
+
+// TestConcurrentRequests fires all endpoints at once. Run it with -race.
+func TestConcurrentRequests(t *testing.T) {
+	fake := fakegs.NewServer(fakegs.DefaultScenario())
+	client := newTestClient(t, fake.ServeHTTP)
+
+	newSettings := settings.Get()
+	newSettings.CacheStaleWhileRevalidate = true
+	newSettings.CachePlayerScoresTtl = 0
+	newSettings.CachePlayerLeaderboardsTtl = 0
+	settings.Update(newSettings)
+
+	ctx := context.Background()
+
+	_, err := client.NewSession(ctx, &fsipc.GsNewSessionRequest{ChartHashVersion: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+
+	for i := 0; i < 10; i++ {
+		chartHash := fmt.Sprintf("hash%d", i%3)
+
+		scoresRequest := &fsipc.GsPlayerScoresRequest{}
+		mustUnmarshal(t, fmt.Sprintf(`{"player1": {"chartHash": %q, "apiKey": "key"}}`, chartHash), scoresRequest)
+
+		leaderboardsRequest := &fsipc.GsPlayerLeaderboardsRequest{}
+		mustUnmarshal(t, fmt.Sprintf(`{"player1": {"chartHash": %q, "apiKey": "key"}, "maxLeaderboardResults": 3}`, chartHash), leaderboardsRequest)
+
+		submitRequest := &fsipc.GsScoreSubmitRequest{}
+		mustUnmarshal(t, fmt.Sprintf(`{"player1": {"chartHash": %q, "apiKey": "key", "score": 9000, "rate": 100}}`, chartHash), submitRequest)
+
+		requests := []func() error{
+			func() error {
+				_, err := client.NewSession(ctx, &fsipc.GsNewSessionRequest{ChartHashVersion: 3})
+				return err
+			},
+			func() error {
+				_, err := client.PlayerScores(ctx, scoresRequest)
+				return err
+			},
+			func() error {
+				_, err := client.PlayerLeaderboards(ctx, leaderboardsRequest)
+				return err
+			},
+			func() error {
+				_, err := client.ScoreSubmit(ctx, submitRequest)
+				return err
+			},
+			func() error {
+				client.SetFakeUrl("")
+				client.OfflineSince()
+				client.Breakers()
+				return nil
+			},
+		}
+
+		for _, request := range requests {
+			wg.Add(1)
+			go func(request func() error) {
+				defer wg.Done()
+
+				err := request()
+				if err != nil {
+					errs <- err
+				}
+			}(request)
+		}
+	}
+
+	wg.Wait()
+	client.Close()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	var hits int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) == 1 {
+			w.Write([]byte(newSessionJson))
+			return
+		}
+
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+
+	_, err := client.NewSession(context.Background(), &fsipc.GsNewSessionRequest{ChartHashVersion: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	request := &fsipc.GsScoreSubmitRequest{}
+	mustUnmarshal(t, `{"player1": {"chartHash": "hash", "apiKey": "key", "score": 9000, "rate": 100}}`, request)
+
+	start := time.Now()
+	_, err = client.ScoreSubmit(ctx, request)
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a cancelled request, got %v", err)
+	}
+	var networkError *NetworkError
+	if errors.As(err, &networkError) {
+		t.Fatal("cancelled request reported as network error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("request took %v to cancel", elapsed)
+	}
+
+	// neither offline nor disabled
+	if !client.OfflineSince().IsZero() || client.Disabled() {
+		t.Fatal("cancelled request changed the client state")
+	}
+}
+
+func TestCancelRetry(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := client.NewSession(ctx, &fsipc.GsNewSessionRequest{ChartHashVersion: 3})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a cancelled request, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("waited %v for the retry", elapsed)
+	}
+}
+
+func TestNewClientInvalidSettings(t *testing.T) {
+	oldSettings := settings.Get()
+	defer settings.Update(oldSettings)
+
+	newSettings := settings.Get()
+	newSettings.HttpProxy = "proxy.example.com"
+	settings.Update(newSettings)
+
+	_, err := NewClient(t.TempDir())
+	if err == nil {
+		t.Fatal("invalid proxy ignored")
+	}
+}
@@ -0,0 +1,58 @@
+package groovestats
+
+import (
+	"context"
+	"sync"
+)
+
+type flightCall struct {
+	done chan struct{}
+	val  interface{}
+	err  error
+
+	// number of callers sharing the result
+	dups int
+}
+
+// flightGroup deduplicates concurrent requests. Callers asking for the same
+// key while a request is in flight wait for it and share its result.
+type flightGroup struct {
+	mutex sync.Mutex
+	calls map[string]*flightCall
+}
+
+// do executes fn unless a call with the same key is already in flight. shared
+// is true if the result of another call was reused. fn is called with the
+// context of the first caller. The others stop waiting when their own
+// context is done.
+func (g *flightGroup) do(ctx context.Context, key string, fn func(ctx context.Context) (interface{}, error)) (val interface{}, err error, shared bool) {
+	g.mutex.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+
+	if call, ok := g.calls[key]; ok {
+		call.dups++
+		g.mutex.Unlock()
+
+		select {
+		case <-call.done:
+			return call.val, call.err, true
+		case <-ctx.Done():
+			return nil, ctx.Err(), true
+		}
+	}
+
+	call := &flightCall{done: make(chan struct{})}
+	g.calls[key] = call
+	g.mutex.Unlock()
+
+	call.val, call.err = fn(ctx)
+	close(call.done)
+
+	g.mutex.Lock()
+	delete(g.calls, key)
+	g.mutex.Unlock()
+
+	return call.val, call.err, false
+}
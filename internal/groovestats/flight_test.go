@@ -0,0 +1,116 @@
+package groovestats
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFlightGroup(t *testing.T) {
+	var group flightGroup
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	calls := 0
+
+	fn := func(ctx context.Context) (interface{}, error) {
+		calls++
+		close(started)
+		<-release
+		return "result", nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]interface{}, 5)
+	sharedCount := 0
+	var mutex sync.Mutex
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		results[0], _, _ = group.do(context.Background(), "key", fn)
+	}()
+	<-started
+
+	for i := 1; i < len(results); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			val, _, shared := group.do(context.Background(), "key", fn)
+			results[i] = val
+
+			mutex.Lock()
+			if shared {
+				sharedCount++
+			}
+			mutex.Unlock()
+		}(i)
+	}
+
+	// wait until every caller is waiting for the first call
+	for {
+		group.mutex.Lock()
+		dups := group.calls["key"].dups
+		group.mutex.Unlock()
+		if dups == len(results)-1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("expected one call, got %d", calls)
+	}
+	if sharedCount != len(results)-1 {
+		t.Errorf("expected %d shared results, got %d", len(results)-1, sharedCount)
+	}
+	for i, result := range results {
+		if result != "result" {
+			t.Errorf("caller %d got %v", i, result)
+		}
+	}
+
+	// nothing in flight anymore, so this runs again
+	val, _, shared := group.do(context.Background(), "key", func(ctx context.Context) (interface{}, error) {
+		return "second", nil
+	})
+	if val != "second" || shared {
+		t.Errorf("expected a fresh call, got %v (shared: %v)", val, shared)
+	}
+}
+
+func TestFlightGroupCancel(t *testing.T) {
+	var group flightGroup
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		group.do(context.Background(), "key", func(ctx context.Context) (interface{}, error) {
+			close(started)
+			<-release
+			return "result", nil
+		})
+	}()
+	<-started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// the waiting caller gives up, the call keeps running
+	_, err, shared := group.do(ctx, "key", nil)
+	if !errors.Is(err, context.Canceled) || !shared {
+		t.Fatalf("expected a cancelled shared call, got %v (shared: %v)", err, shared)
+	}
+
+	close(release)
+	<-done
+}
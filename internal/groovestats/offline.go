@@ -0,0 +1,56 @@
+package groovestats
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// connectivity tracks whether GrooveStats can be reached. The client goes
+// offline when a request fails with a NetworkError even after retrying, and
+// comes back online with the next request that gets an answer.
+type connectivity struct {
+	mutex        sync.Mutex
+	offlineSince time.Time
+	callback     func(offline bool)
+}
+
+func (c *connectivity) done(err error, now time.Time) {
+	var networkError *NetworkError
+	offline := errors.As(err, &networkError)
+
+	c.mutex.Lock()
+	wasOffline := !c.offlineSince.IsZero()
+	if offline && !wasOffline {
+		c.offlineSince = now
+	} else if !offline {
+		c.offlineSince = time.Time{}
+	}
+	callback := c.callback
+	c.mutex.Unlock()
+
+	if offline != wasOffline && callback != nil {
+		callback(offline)
+	}
+}
+
+func (c *connectivity) since() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.offlineSince
+}
+
+// SetConnectivityCallback registers a function that is called whenever the
+// client goes offline or comes back online.
+func (client *Client) SetConnectivityCallback(callback func(offline bool)) {
+	client.connectivity.mutex.Lock()
+	client.connectivity.callback = callback
+	client.connectivity.mutex.Unlock()
+}
+
+// OfflineSince returns when GrooveStats became unreachable, or the zero time
+// if the client is online.
+func (client *Client) OfflineSince() time.Time {
+	return client.connectivity.since()
+}
@@ -0,0 +1,96 @@
+package groovestats
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GrooveStats/gslauncher/internal/fsipc"
+	"github.com/GrooveStats/gslauncher/internal/settings"
+)
+
+func TestOffline(t *testing.T) {
+	var offline int32
+
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&offline) == 1 {
+			// drop the connection without an answer
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+			return
+		}
+
+		switch r.URL.Path {
+		case "/new-session.php":
+			w.Write([]byte(newSessionJson))
+		case "/player-scores.php":
+			w.Write([]byte(playerScoresJson))
+		}
+	})
+
+	changes := make(chan bool, 2)
+	client.SetConnectivityCallback(func(offline bool) {
+		changes <- offline
+	})
+
+	_, err := client.NewSession(context.Background(), &fsipc.GsNewSessionRequest{ChartHashVersion: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newSettings := settings.Get()
+	newSettings.CachePlayerScoresTtl = 0
+	settings.Update(newSettings)
+
+	request := &fsipc.GsPlayerScoresRequest{}
+	mustUnmarshal(t, `{"player1": {"chartHash": "hash", "apiKey": "key"}}`, request)
+
+	_, err = client.PlayerScores(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	atomic.StoreInt32(&offline, 1)
+	time.Sleep(10 * time.Millisecond)
+
+	scores, err := client.PlayerScores(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !scores.Cached || scores.StaleSince == nil {
+		t.Fatalf("expected stale response, got %+v", scores)
+	}
+
+	if client.OfflineSince().IsZero() {
+		t.Fatal("client not offline")
+	}
+	if offline := <-changes; !offline {
+		t.Fatal("expected offline notification")
+	}
+
+	// charts that were never fetched still fail
+	otherRequest := &fsipc.GsPlayerScoresRequest{}
+	mustUnmarshal(t, `{"player1": {"chartHash": "other", "apiKey": "key"}}`, otherRequest)
+	_, err = client.PlayerScores(context.Background(), otherRequest)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	// the next successful request brings the client back online
+	atomic.StoreInt32(&offline, 0)
+	_, err = client.NewSession(context.Background(), &fsipc.GsNewSessionRequest{ChartHashVersion: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !client.OfflineSince().IsZero() {
+		t.Fatal("client still offline")
+	}
+	if offline := <-changes; offline {
+		t.Fatal("expected online notification")
+	}
+}
@@ -1,5 +1,7 @@
 package groovestats
 
+import "time"
+
 type ErrorResponse struct {
 	Message string `json:"message"`
 	Error   string `json:"error"`
@@ -45,6 +47,11 @@ type PlayerScoresResponse struct {
 
 	// added by the launcher
 	Cached bool `json:"cached"`
+
+	// when outdated data was fetched from GrooveStats, only set if it is
+	// returned because GrooveStats couldn't be reached or is refreshed in
+	// the background
+	StaleSince *time.Time `json:"staleSince,omitempty"`
 }
 
 type playerLeaderboardsPlayerData struct {
@@ -66,6 +73,14 @@ type playerLeaderboardsPlayerData struct {
 type PlayerLeaderboardsResponse struct {
 	Player1 *playerLeaderboardsPlayerData `json:"player1"`
 	Player2 *playerLeaderboardsPlayerData `json:"player2"`
+
+	// added by the launcher
+	Cached bool `json:"cached"`
+
+	// when outdated data was fetched from GrooveStats, only set if it is
+	// returned because GrooveStats couldn't be reached or is refreshed in
+	// the background
+	StaleSince *time.Time `json:"staleSince,omitempty"`
 }
 
 type scoreSubmitPlayerData struct {
@@ -0,0 +1,136 @@
+package groovestats
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// retryPolicy decides how often a failed request is retried. Only requests
+// that failed with a NetworkError are retried.
+type retryPolicy struct {
+	// maximum number of attempts, including the first one
+	attempts int
+
+	// the delay before the first retry, doubled for every further retry
+	baseDelay time.Duration
+	maxDelay  time.Duration
+
+	// The theme stops waiting for a response after 60 seconds, so there's
+	// no point in retrying after that.
+	budget time.Duration
+}
+
+var noRetry = retryPolicy{attempts: 1}
+
+// retry policy for idempotent GET requests
+var getRetry = retryPolicy{
+	attempts:  4,
+	baseDelay: 500 * time.Millisecond,
+	maxDelay:  8 * time.Second,
+	budget:    50 * time.Second,
+}
+
+// backoff returns the jittered delay before the given retry (starting at 1).
+func (policy retryPolicy) backoff(retry int) time.Duration {
+	delay := policy.baseDelay
+	for i := 1; i < retry && delay < policy.maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > policy.maxDelay {
+		delay = policy.maxDelay
+	}
+
+	// wait somewhere between half and the full delay, so clients don't
+	// retry in lockstep
+	if delay > 1 {
+		delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+	}
+
+	return delay
+}
+
+// parseRetryAfter parses the Retry-After header, which holds either a number
+// of seconds or a date.
+func parseRetryAfter(header http.Header, now time.Time) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+
+	seconds, err := strconv.Atoi(value)
+	if err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	date, err := http.ParseTime(value)
+	if err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+
+	return 0
+}
+
+// doRequestWithRetry sends a request to the endpoint, observing its retry
+// policy and circuit breaker.
+func (client *Client) doRequestWithRetry(endpoint string, req *http.Request, response interface{}) error {
+	b := client.breakers[endpoint]
+	if b != nil {
+		err := b.allow(time.Now())
+		if err != nil {
+			return err
+		}
+	}
+
+	err := client.retry(client.retryPolicies[endpoint], client.timeouts[endpoint], req, response)
+
+	if b != nil {
+		b.done(err, time.Now())
+	}
+
+	// a cancelled request doesn't tell whether GrooveStats can be reached
+	if req.Context().Err() == nil {
+		client.connectivity.done(err, time.Now())
+	}
+
+	return err
+}
+
+func (client *Client) retry(policy retryPolicy, timeout time.Duration, req *http.Request, response interface{}) error {
+	deadline := time.Now().Add(policy.budget)
+
+	for attempt := 1; ; attempt++ {
+		err := client.doRequest(req.Clone(req.Context()), timeout, response)
+
+		var networkError *NetworkError
+		if err == nil || attempt >= policy.attempts || !errors.As(err, &networkError) {
+			return err
+		}
+
+		delay := policy.backoff(attempt)
+		if networkError.retryAfter > delay {
+			delay = networkError.retryAfter
+		}
+
+		// make sure the next attempt can finish before the deadline
+		if time.Now().Add(delay + timeout).After(deadline) {
+			return err
+		}
+
+		client.logger.Warn("retrying request", "method", req.Method, "url", req.URL.String(), "delay", delay.Round(time.Millisecond), "err", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return fmt.Errorf("%s %v: %w", req.Method, req.URL, req.Context().Err())
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package gui
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/GrooveStats/gslauncher/internal/events"
+)
+
+type EventsWidget struct {
+	eventTracker *events.Tracker
+	vbox         *fyne.Container
+	entries      *fyne.Container
+
+	// the tracker calls Refresh from the session goroutines
+	mutex sync.Mutex
+}
+
+func NewEventsWidget(eventTracker *events.Tracker) *EventsWidget {
+	titleLabel := widget.NewLabel("Active Events")
+	titleLabel.TextStyle.Bold = true
+
+	entries := container.NewVBox()
+
+	eventsWidget := &EventsWidget{
+		eventTracker: eventTracker,
+		vbox:         container.NewVBox(widget.NewSeparator(), titleLabel, entries),
+		entries:      entries,
+	}
+
+	eventTracker.SetUpdateCallback(eventsWidget.Refresh)
+	eventsWidget.Refresh()
+
+	return eventsWidget
+}
+
+func (eventsWidget *EventsWidget) Refresh() {
+	eventsWidget.mutex.Lock()
+	defer eventsWidget.mutex.Unlock()
+
+	activeEvents := eventsWidget.eventTracker.Events()
+
+	entries := make([]fyne.CanvasObject, 0, len(activeEvents))
+
+	for _, event := range activeEvents {
+		var title fyne.CanvasObject
+
+		link, err := url.Parse(event.Url)
+		if !event.Active {
+			label := widget.NewLabel(event.Name + " (ended)")
+			label.TextStyle.Italic = true
+			title = label
+		} else if event.Url != "" && err == nil {
+			title = widget.NewHyperlink(event.Name, link)
+		} else {
+			label := widget.NewLabel(event.Name)
+			label.TextStyle.Italic = true
+			title = label
+		}
+
+		shortNameLabel := widget.NewLabel(event.ShortName)
+
+		entry := container.NewVBox(container.NewHBox(title, layout.NewSpacer(), shortNameLabel))
+
+		progress := eventsWidget.eventTracker.Progress(event.Name)
+		if len(progress) == 0 {
+			emptyLabel := widget.NewLabel("No progress this session")
+			emptyLabel.TextStyle.Italic = true
+			entry.Add(emptyLabel)
+		}
+		for i := range progress {
+			progressLabel := widget.NewLabel(describeProgress(&progress[i]))
+			progressLabel.Wrapping = fyne.TextWrapWord
+			entry.Add(progressLabel)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	eventsWidget.entries.Objects = entries
+
+	if len(activeEvents) == 0 {
+		eventsWidget.vbox.Hide()
+	} else {
+		eventsWidget.vbox.Show()
+	}
+
+	eventsWidget.vbox.Refresh()
+}
+
+func describeProgress(progress *events.Progress) string {
+	profileName := progress.ProfileName
+	if profileName == "" {
+		profileName = "unnamed player"
+	}
+
+	parts := make([]string, 0, 3)
+	if progress.Scores == 1 {
+		parts = append(parts, "1 score improved")
+	} else if progress.Scores > 1 {
+		parts = append(parts, fmt.Sprintf("%d scores improved", progress.Scores))
+	}
+	if progress.Points > 0 {
+		parts = append(parts, fmt.Sprintf("+%d points", progress.Points))
+	}
+	if len(progress.Quests) > 0 {
+		parts = append(parts, "completed "+strings.Join(progress.Quests, ", "))
+	}
+
+	return fmt.Sprintf("%s: %s", profileName, strings.Join(parts, ", "))
+}
@@ -16,28 +16,41 @@ import (
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 
+	"github.com/GrooveStats/gslauncher/internal/events"
+	"github.com/GrooveStats/gslauncher/internal/history"
+	"github.com/GrooveStats/gslauncher/internal/scorequeue"
 	"github.com/GrooveStats/gslauncher/internal/session"
 	"github.com/GrooveStats/gslauncher/internal/settings"
-	"github.com/GrooveStats/gslauncher/internal/stats"
 	"github.com/GrooveStats/gslauncher/internal/unlocks"
+	"github.com/GrooveStats/gslauncher/internal/usage"
 	"github.com/GrooveStats/gslauncher/internal/version"
 )
 
 type App struct {
-	app           fyne.App
-	mainWin       fyne.Window
-	unlockManager *unlocks.Manager
-	unlockWidget  *UnlockWidget
-	launchButton  *widget.Button
-	session       *session.Session
-	autolaunch    bool
-	cacheDir      string
+	app              fyne.App
+	mainWin          fyne.Window
+	unlockManager    *unlocks.Manager
+	unlockWidget     *UnlockWidget
+	scoreQueue       *scorequeue.Queue
+	scoreQueueWidget *ScoreQueueWidget
+	history          *history.History
+	usage            *usage.Stats
+	eventTracker     *events.Tracker
+	eventsWidget     *EventsWidget
+	launchButton     *widget.Button
+	session          *session.Session
+	autolaunch       bool
+	cacheDir         string
 }
 
-func NewApp(unlockManager *unlocks.Manager, autolaunch bool, cacheDir string) *App {
+func NewApp(unlockManager *unlocks.Manager, scoreQueue *scorequeue.Queue, scoreHistory *history.History, usageStats *usage.Stats, eventTracker *events.Tracker, autolaunch bool, cacheDir string) *App {
 	app := &App{
 		app:           app.New(),
 		unlockManager: unlockManager,
+		scoreQueue:    scoreQueue,
+		history:       scoreHistory,
+		usage:         usageStats,
+		eventTracker:  eventTracker,
 		autolaunch:    autolaunch || settings.Get().AutoLaunch,
 		cacheDir:      cacheDir,
 	}
@@ -108,8 +121,11 @@ func NewApp(unlockManager *unlocks.Manager, autolaunch bool, cacheDir string) *A
 			filename := filepath.Join(cacheDir, "groovestats-launcher", "log.txt")
 			app.viewLogfile(filename)
 		}),
+		fyne.NewMenuItem("Score History", func() {
+			app.showHistoryWindow()
+		}),
 		fyne.NewMenuItem("Statistics", func() {
-			app.showStatisticsDialog()
+			app.showStatisticsWindow()
 		}),
 	)
 	if runtime.GOOS == "darwin" {
@@ -151,9 +167,13 @@ func NewApp(unlockManager *unlocks.Manager, autolaunch bool, cacheDir string) *A
 	app.launchButton.Importance = widget.HighImportance
 
 	app.unlockWidget = NewUnlockWidget(unlockManager)
+	app.scoreQueueWidget = NewScoreQueueWidget(scoreQueue)
+	app.eventsWidget = NewEventsWidget(eventTracker)
 
 	app.mainWin.SetContent(container.NewVScroll(container.NewVBox(
+		app.scoreQueueWidget.vbox,
 		app.unlockWidget.vbox,
+		app.eventsWidget.vbox,
 		layout.NewSpacer(),
 		container.NewPadded(app.launchButton),
 	)))
@@ -179,7 +199,7 @@ func (app *App) Run() {
 }
 
 func (app *App) launchSM() {
-	session, err := session.Launch(app.unlockManager)
+	session, err := session.Launch(app.unlockManager, app.scoreQueue, app.history, app.usage, app.eventTracker, app.cacheDir)
 	if err != nil {
 		dialog.ShowError(err, app.mainWin)
 		return
@@ -274,16 +294,6 @@ func (app *App) showClearCacheDialog() {
 	confirmDialog.Show()
 }
 
-func (app *App) showStatisticsDialog() {
-	message := fmt.Sprintf("GET /new-session.php: %d\n", stats.GsNewSessionCount)
-	message += fmt.Sprintf("GET /player-scores.php: %d\n", stats.GsPlayerScoresCount)
-	message += fmt.Sprintf("GET /player-scores.php (cached): %d\n", stats.GsPlayerScoresCachedCount)
-	message += fmt.Sprintf("GET /player-leaderboards.php: %d\n", stats.GsPlayerLeaderboardsCount)
-	message += fmt.Sprintf("POST /score-submit.php: %d\n", stats.GsScoreSubmitCount)
-
-	dialog.ShowInformation("Statistics", message, app.mainWin)
-}
-
 func (app *App) showAboutDialog() {
 	message := fmt.Sprintf(
 		"GrooveStats Launcher\n%s (%s %s)",
@@ -0,0 +1,251 @@
+package gui
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/data/validation"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/GrooveStats/gslauncher/internal/history"
+)
+
+const (
+	allProfiles = "All Profiles"
+	allResults  = "All Results"
+)
+
+var historyColumns = []struct {
+	title string
+	width float32
+}{
+	{"Submitted", 140},
+	{"Profile", 110},
+	{"Chart", 150},
+	{"Score", 70},
+	{"Rate", 50},
+	{"Result", 140},
+	{"Delta", 60},
+	{"Events", 220},
+}
+
+type historyView struct {
+	history *history.History
+	window  fyne.Window
+	filter  history.Filter
+	entries []history.Entry
+
+	profileSelect *widget.Select
+	resultSelect  *widget.Select
+	table         *widget.Table
+}
+
+func (app *App) showHistoryWindow() {
+	view := &historyView{
+		history: app.history,
+		window:  app.app.NewWindow("Score History"),
+	}
+
+	view.profileSelect = widget.NewSelect(nil, func(selected string) {
+		view.filter.ProfileName = selected
+		if selected == allProfiles {
+			view.filter.ProfileName = ""
+		}
+		view.refresh()
+	})
+
+	view.resultSelect = widget.NewSelect(nil, func(selected string) {
+		view.filter.Result = selected
+		if selected == allResults {
+			view.filter.Result = ""
+		}
+		view.refresh()
+	})
+
+	chartHashEntry := widget.NewEntry()
+	chartHashEntry.SetPlaceHolder("Chart Hash")
+	chartHashEntry.OnChanged = func(s string) {
+		view.filter.ChartHash = s
+		view.refresh()
+	}
+
+	fromEntry := newDateEntry("From", func(date time.Time) {
+		view.filter.From = date
+		view.refresh()
+	})
+
+	toEntry := newDateEntry("To", func(date time.Time) {
+		// include the whole day
+		if !date.IsZero() {
+			date = date.AddDate(0, 0, 1)
+		}
+		view.filter.To = date
+		view.refresh()
+	})
+
+	csvButton := widget.NewButton("Export CSV", func() {
+		view.export("score-history.csv", history.WriteCsv)
+	})
+
+	jsonButton := widget.NewButton("Export JSON", func() {
+		view.export("score-history.json", history.WriteJson)
+	})
+
+	view.table = widget.NewTable(
+		func() (int, int) {
+			return len(view.entries) + 1, len(historyColumns)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(id widget.TableCellID, object fyne.CanvasObject) {
+			label := object.(*widget.Label)
+
+			if id.Row == 0 {
+				label.TextStyle.Bold = true
+				label.SetText(historyColumns[id.Col].title)
+				return
+			}
+
+			label.TextStyle.Bold = false
+			label.SetText(describeHistoryCell(&view.entries[id.Row-1], id.Col))
+		},
+	)
+	for i, column := range historyColumns {
+		view.table.SetColumnWidth(i, column.width)
+	}
+
+	filters := container.NewGridWithColumns(
+		5,
+		view.profileSelect,
+		view.resultSelect,
+		chartHashEntry,
+		fromEntry,
+		toEntry,
+	)
+
+	view.window.SetContent(container.NewBorder(
+		container.NewVBox(filters, container.NewHBox(csvButton, jsonButton)),
+		nil,
+		nil,
+		nil,
+		view.table,
+	))
+
+	app.history.SetUpdateCallback(view.refresh)
+	view.window.SetOnClosed(func() {
+		app.history.SetUpdateCallback(func() {})
+	})
+
+	view.profileSelect.SetSelected(allProfiles)
+	view.resultSelect.SetSelected(allResults)
+	view.refresh()
+
+	view.window.Resize(fyne.NewSize(1000, 600))
+	view.window.Show()
+}
+
+func newDateEntry(placeHolder string, changed func(date time.Time)) *widget.Entry {
+	entry := widget.NewEntry()
+	entry.SetPlaceHolder(placeHolder + " (YYYY-MM-DD)")
+	entry.Validator = validation.NewRegexp(`^(\d{4}-\d{2}-\d{2})?$`, "Must be a date like 2022-06-30")
+	entry.OnChanged = func(s string) {
+		if s == "" {
+			changed(time.Time{})
+			return
+		}
+
+		date, err := time.ParseInLocation("2006-01-02", s, time.Local)
+		if err == nil {
+			changed(date)
+		}
+	}
+
+	return entry
+}
+
+func (view *historyView) refresh() {
+	entries, err := view.history.Entries(view.filter)
+	if err != nil {
+		dialog.ShowError(err, view.window)
+		return
+	}
+	view.entries = entries
+
+	profileNames, results, err := view.history.Values()
+	if err == nil {
+		view.profileSelect.Options = append([]string{allProfiles}, profileNames...)
+		view.resultSelect.Options = append([]string{allResults}, results...)
+	}
+
+	view.table.Refresh()
+}
+
+func (view *historyView) export(filename string, write func(w io.Writer, entries []history.Entry) error) {
+	entries := view.entries
+
+	saveDialog := dialog.NewFileSave(func(file fyne.URIWriteCloser, err error) {
+		if err != nil || file == nil {
+			return
+		}
+		defer file.Close()
+
+		err = write(file, entries)
+		if err != nil {
+			dialog.ShowError(err, view.window)
+		}
+	}, view.window)
+	saveDialog.SetFileName(filename)
+	saveDialog.Resize(fyne.NewSize(700, 500))
+	saveDialog.Show()
+}
+
+func describeHistoryCell(entry *history.Entry, col int) string {
+	switch col {
+	case 0:
+		return entry.Submitted.Format("2006-01-02 15:04")
+	case 1:
+		if entry.ProfileName == "" {
+			return "unnamed player"
+		}
+		return entry.ProfileName
+	case 2:
+		return entry.ChartHash
+	case 3:
+		return fmt.Sprintf("%.2f%%", float64(entry.Score)/100)
+	case 4:
+		return fmt.Sprintf("%.2fx", float64(entry.Rate)/100)
+	case 5:
+		return entry.Result
+	case 6:
+		if entry.ScoreDelta == nil {
+			return ""
+		}
+		return fmt.Sprintf("%+.2f", float64(*entry.ScoreDelta)/100)
+	case 7:
+		return describeHistoryEvents(entry)
+	}
+
+	return ""
+}
+
+func describeHistoryEvents(entry *history.Entry) string {
+	description := ""
+
+	if entry.Rpg != nil {
+		description = fmt.Sprintf("%s: %s", entry.Rpg.Name, entry.Rpg.Result)
+	}
+
+	if entry.Itl != nil {
+		if description != "" {
+			description += ", "
+		}
+		description += fmt.Sprintf("%s: %d points", entry.Itl.Name, entry.Itl.CurrentPointTotal)
+	}
+
+	return description
+}
@@ -0,0 +1,132 @@
+package gui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/GrooveStats/gslauncher/internal/scorequeue"
+)
+
+type ScoreQueueWidget struct {
+	scoreQueue *scorequeue.Queue
+	vbox       *fyne.Container
+	entries    *fyne.Container
+}
+
+func NewScoreQueueWidget(scoreQueue *scorequeue.Queue) *ScoreQueueWidget {
+	titleLabel := widget.NewLabel("Pending Score Submissions")
+	titleLabel.TextStyle.Bold = true
+
+	entries := container.NewVBox()
+
+	scoreQueueWidget := &ScoreQueueWidget{
+		scoreQueue: scoreQueue,
+		vbox:       container.NewVBox(titleLabel, entries, widget.NewSeparator()),
+		entries:    entries,
+	}
+
+	scoreQueue.SetUpdateCallback(scoreQueueWidget.Refresh)
+	scoreQueueWidget.Refresh()
+
+	return scoreQueueWidget
+}
+
+func (scoreQueueWidget *ScoreQueueWidget) Refresh() {
+	entries := scoreQueueWidget.scoreQueue.Entries()
+
+	scoreQueueWidget.entries.Objects = nil
+
+	for _, e := range entries {
+		entry := e
+
+		descriptionLabel := widget.NewLabel(describeQueueEntry(&entry))
+
+		var statusText string
+		switch entry.Status {
+		case scorequeue.Queued:
+			if entry.Attempts == 0 {
+				statusText = "Waiting for GrooveStats"
+			} else {
+				statusText = fmt.Sprintf(
+					"Attempt %d failed, retrying at %s",
+					entry.Attempts,
+					entry.NextAttempt.Format("15:04"),
+				)
+			}
+		case scorequeue.Submitting:
+			statusText = "Submitting..."
+		case scorequeue.Failed:
+			statusText = fmt.Sprintf("Submission failed: %s", entry.Error)
+		}
+
+		statusLabel := widget.NewLabel(statusText)
+		statusLabel.Wrapping = fyne.TextWrapWord
+
+		retryButton := widget.NewButton("Retry", func() {
+			scoreQueueWidget.scoreQueue.Retry(entry.Id)
+		})
+		retryButton.SetIcon(theme.ViewRefreshIcon())
+		if entry.Status != scorequeue.Failed {
+			retryButton.Hide()
+		}
+
+		discardButton := widget.NewButton("Discard", func() {
+			scoreQueueWidget.scoreQueue.Discard(entry.Id)
+		})
+		discardButton.SetIcon(theme.DeleteIcon())
+		if entry.Status == scorequeue.Submitting {
+			discardButton.Disable()
+		}
+
+		scoreQueueWidget.entries.Add(container.NewVBox(
+			container.NewHBox(
+				descriptionLabel,
+				layout.NewSpacer(),
+				retryButton,
+				discardButton,
+			),
+			statusLabel,
+		))
+	}
+
+	if len(entries) == 0 {
+		scoreQueueWidget.vbox.Hide()
+	} else {
+		scoreQueueWidget.vbox.Show()
+	}
+
+	scoreQueueWidget.vbox.Refresh()
+}
+
+func describeQueueEntry(entry *scorequeue.Entry) string {
+	players := make([]string, 0, 2)
+
+	request := entry.Request
+	if request.Player1 != nil {
+		players = append(players, describeQueuedScore(request.Player1.ProfileName, request.Player1.Score))
+	}
+	if request.Player2 != nil {
+		players = append(players, describeQueuedScore(request.Player2.ProfileName, request.Player2.Score))
+	}
+
+	return fmt.Sprintf(
+		"%s (played %s)",
+		strings.Join(players, ", "),
+		entry.Added.Format(time.Stamp),
+	)
+}
+
+func describeQueuedScore(profileName string, score int) string {
+	if profileName == "" {
+		profileName = "unnamed player"
+	}
+
+	return fmt.Sprintf("%s: %.2f%%", profileName, float64(score)/100)
+}
@@ -2,6 +2,7 @@ package gui
 
 import (
 	"errors"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -15,6 +16,8 @@ import (
 	"fyne.io/fyne/v2/storage"
 	"fyne.io/fyne/v2/widget"
 
+	"github.com/GrooveStats/gslauncher/internal/httpclient"
+	"github.com/GrooveStats/gslauncher/internal/logging"
 	"github.com/GrooveStats/gslauncher/internal/settings"
 )
 
@@ -230,6 +233,204 @@ func (app *App) getSettingsForm(data *settings.Settings) fyne.CanvasObject {
 	})
 	autoLaunchCheck.SetChecked(data.AutoLaunch)
 
+	ipcFsyncCheck := widget.NewCheck("", func(checked bool) {
+		data.IpcFsync = checked
+	})
+	ipcFsyncCheck.SetChecked(data.IpcFsync)
+
+	ipcFsyncFormItem := widget.NewFormItem("Flush Responses to Disk", ipcFsyncCheck)
+	ipcFsyncFormItem.HintText = "Slower, but more reliable on network shares"
+
+	ipcRecordCheck := widget.NewCheck("", func(checked bool) {
+		data.IpcRecord = checked
+	})
+	ipcRecordCheck.SetChecked(data.IpcRecord)
+
+	ipcRecordFormItem := widget.NewFormItem("Record Theme Communication", ipcRecordCheck)
+	ipcRecordFormItem.HintText = "Saves transcripts for bug reports in the launcher's cache directory"
+
+	ipcServerPortEntry := widget.NewEntry()
+	ipcServerPortEntry.Validator = validation.NewRegexp(`^\d+$`, "Must contain a number")
+	ipcServerPortEntry.Text = strconv.Itoa(data.IpcServerPort)
+	ipcServerPortEntry.OnChanged = func(s string) {
+		n, err := strconv.Atoi(s)
+		if err == nil && n >= 0 && n <= 65535 {
+			data.IpcServerPort = n
+		}
+	}
+
+	options = []string{"Automatic", "File System Events", "Polling"}
+	ipcWatchModeSelect := widget.NewSelect(options, func(selected string) {
+		switch selected {
+		case "Automatic":
+			data.IpcWatchMode = settings.WatchAuto
+		case "File System Events":
+			data.IpcWatchMode = settings.WatchFsnotify
+		case "Polling":
+			data.IpcWatchMode = settings.WatchPoll
+		}
+	})
+	switch data.IpcWatchMode {
+	case settings.WatchAuto:
+		ipcWatchModeSelect.SetSelected("Automatic")
+	case settings.WatchFsnotify:
+		ipcWatchModeSelect.SetSelected("File System Events")
+	case settings.WatchPoll:
+		ipcWatchModeSelect.SetSelected("Polling")
+	}
+
+	ipcWatchModeFormItem := widget.NewFormItem("Request Detection", ipcWatchModeSelect)
+	ipcWatchModeFormItem.HintText = "Use polling if your Save directory is on a network share"
+
+	ipcServerPortFormItem := widget.NewFormItem("Local IPC Server Port", ipcServerPortEntry)
+	ipcServerPortFormItem.HintText = "HTTP/WebSocket access for themes and tools on this computer, 0 to disable"
+
+	cachePlayerScoresTtlEntry := widget.NewEntry()
+	cachePlayerScoresTtlEntry.Validator = validation.NewRegexp(`^\d+$`, "Must contain a number")
+	cachePlayerScoresTtlEntry.Text = strconv.Itoa(data.CachePlayerScoresTtl)
+	cachePlayerScoresTtlEntry.OnChanged = func(s string) {
+		n, err := strconv.Atoi(s)
+		if err == nil {
+			data.CachePlayerScoresTtl = n
+		}
+	}
+
+	cachePlayerScoresTtlFormItem := widget.NewFormItem("Cache Player Scores (Minutes)", cachePlayerScoresTtlEntry)
+	cachePlayerScoresTtlFormItem.HintText = "How long scores are reused before asking GrooveStats again"
+
+	cachePlayerLeaderboardsTtlEntry := widget.NewEntry()
+	cachePlayerLeaderboardsTtlEntry.Validator = validation.NewRegexp(`^\d+$`, "Must contain a number")
+	cachePlayerLeaderboardsTtlEntry.Text = strconv.Itoa(data.CachePlayerLeaderboardsTtl)
+	cachePlayerLeaderboardsTtlEntry.OnChanged = func(s string) {
+		n, err := strconv.Atoi(s)
+		if err == nil {
+			data.CachePlayerLeaderboardsTtl = n
+		}
+	}
+
+	cachePlayerLeaderboardsTtlFormItem := widget.NewFormItem("Cache Leaderboards (Minutes)", cachePlayerLeaderboardsTtlEntry)
+	cachePlayerLeaderboardsTtlFormItem.HintText = "How long leaderboards are reused before asking GrooveStats again"
+
+	cacheStaleCheck := widget.NewCheck("", func(checked bool) {
+		data.CacheStaleWhileRevalidate = checked
+	})
+	cacheStaleCheck.SetChecked(data.CacheStaleWhileRevalidate)
+
+	cacheStaleFormItem := widget.NewFormItem("Answer With Outdated Scores", cacheStaleCheck)
+	cacheStaleFormItem.HintText = "Faster responses, the cache is refreshed in the background"
+
+	gsUrlEntry := widget.NewEntry()
+	gsUrlEntry.SetPlaceHolder(settings.DefaultGrooveStatsUrl)
+	gsUrlEntry.Validator = settings.ValidateGrooveStatsUrl
+	gsUrlEntry.Text = data.GrooveStatsUrl
+	gsUrlEntry.OnChanged = func(url string) {
+		data.GrooveStatsUrl = url
+	}
+
+	gsUrlFormItem := widget.NewFormItem("GrooveStats URL", gsUrlEntry)
+	gsUrlFormItem.HintText = "Only change this to test against a fake GrooveStats server"
+
+	httpProxyEntry := widget.NewEntry()
+	httpProxyEntry.SetPlaceHolder("System Proxy")
+	httpProxyEntry.Validator = func(s string) error {
+		_, err := httpclient.ParseProxy(s)
+		return err
+	}
+	httpProxyEntry.Text = data.HttpProxy
+	httpProxyEntry.OnChanged = func(proxy string) {
+		data.HttpProxy = proxy
+	}
+
+	httpProxyFormItem := widget.NewFormItem("HTTP Proxy", httpProxyEntry)
+	httpProxyFormItem.HintText = "e.g. http://proxy.example.com:3128, empty to use the system settings"
+
+	caBundleButton := widget.NewButton("Select", nil)
+	caBundleButton.OnTapped = func() {
+		fileDialog := dialog.NewFileOpen(func(file fyne.URIReadCloser, err error) {
+			if err != nil || file == nil {
+				return
+			}
+			file.Close()
+
+			path := filepath.FromSlash(file.URI().Path())
+
+			_, err = httpclient.LoadCaBundle(path)
+			if err != nil {
+				dialog.ShowError(err, app.mainWin)
+				return
+			}
+
+			data.HttpCaBundle = path
+			caBundleButton.SetText(abbreviatePath(path))
+		}, app.mainWin)
+		fileDialog.SetFilter(storage.NewExtensionFileFilter([]string{".pem", ".crt"}))
+		fileDialog.Resize(fyne.NewSize(700, 500))
+		fileDialog.Show()
+	}
+	if data.HttpCaBundle != "" {
+		caBundleButton.SetText(abbreviatePath(data.HttpCaBundle))
+	}
+
+	caBundleClearButton := widget.NewButton("Clear", func() {
+		data.HttpCaBundle = ""
+		caBundleButton.SetText("Select")
+	})
+
+	caBundleFormItem := widget.NewFormItem("Extra CA Certificates", container.NewBorder(nil, nil, nil, caBundleClearButton, caBundleButton))
+	caBundleFormItem.HintText = "PEM file for networks that intercept TLS connections"
+
+	httpTimeoutEntry := newSecondsEntry(&data.HttpTimeout)
+	httpTimeoutFormItem := widget.NewFormItem("Request Timeout (Seconds)", httpTimeoutEntry)
+	httpTimeoutFormItem.HintText = "How long to wait for GrooveStats to answer"
+
+	httpSubmitTimeoutEntry := newSecondsEntry(&data.HttpSubmitTimeout)
+	httpSubmitTimeoutFormItem := widget.NewFormItem("Score Submit Timeout (Seconds)", httpSubmitTimeoutEntry)
+	httpSubmitTimeoutFormItem.HintText = "How long to wait for GrooveStats to accept a score"
+
+	httpDownloadTimeoutEntry := newSecondsEntry(&data.HttpDownloadTimeout)
+	httpDownloadTimeoutFormItem := widget.NewFormItem("Download Timeout (Seconds)", httpDownloadTimeoutEntry)
+	httpDownloadTimeoutFormItem.HintText = "How long to wait for an unlock download to start"
+
+	metricsPortEntry := widget.NewEntry()
+	metricsPortEntry.Validator = validation.NewRegexp(`^\d+$`, "Must contain a number")
+	metricsPortEntry.Text = strconv.Itoa(data.MetricsPort)
+	metricsPortEntry.OnChanged = func(s string) {
+		n, err := strconv.Atoi(s)
+		if err == nil && n >= 0 && n <= 65535 {
+			data.MetricsPort = n
+		}
+	}
+
+	metricsPortFormItem := widget.NewFormItem("Metrics Port", metricsPortEntry)
+	metricsPortFormItem.HintText = "Serves /metrics for Prometheus on this computer, 0 to disable. Takes effect after a restart"
+
+	options = []string{"Debug", "Info", "Warning", "Error"}
+	logLevelSelect := widget.NewSelect(options, func(selected string) {
+		switch selected {
+		case "Debug":
+			data.LogLevel = "debug"
+		case "Info":
+			data.LogLevel = "info"
+		case "Warning":
+			data.LogLevel = "warn"
+		case "Error":
+			data.LogLevel = "error"
+		}
+	})
+	switch logging.ParseLevel(data.LogLevel) {
+	case slog.LevelDebug:
+		logLevelSelect.SetSelected("Debug")
+	case slog.LevelWarn:
+		logLevelSelect.SetSelected("Warning")
+	case slog.LevelError:
+		logLevelSelect.SetSelected("Error")
+	default:
+		logLevelSelect.SetSelected("Info")
+	}
+
+	logLevelFormItem := widget.NewFormItem("Log Level", logLevelSelect)
+	logLevelFormItem.HintText = "Takes effect immediately, Debug also logs the GrooveStats responses"
+
 	form := widget.NewForm(
 		smExeButtonFormItem,
 		smSaveDirFormItem,
@@ -238,11 +439,40 @@ func (app *App) getSettingsForm(data *settings.Settings) fyne.CanvasObject {
 		autoDownloadFormItem,
 		widget.NewFormItem("Separate Unlocks by User", userUnlocksCheck),
 		widget.NewFormItem("Launch StepMania at Startup", autoLaunchCheck),
+		ipcWatchModeFormItem,
+		ipcFsyncFormItem,
+		ipcServerPortFormItem,
+		ipcRecordFormItem,
+		cachePlayerScoresTtlFormItem,
+		cachePlayerLeaderboardsTtlFormItem,
+		cacheStaleFormItem,
+		gsUrlFormItem,
+		httpProxyFormItem,
+		caBundleFormItem,
+		httpTimeoutFormItem,
+		httpSubmitTimeoutFormItem,
+		httpDownloadTimeoutFormItem,
+		metricsPortFormItem,
+		logLevelFormItem,
 	)
 
 	return form
 }
 
+func newSecondsEntry(seconds *int) *widget.Entry {
+	entry := widget.NewEntry()
+	entry.Validator = validation.NewRegexp(`^[1-9]\d*$`, "Must contain a number greater than 0")
+	entry.Text = strconv.Itoa(*seconds)
+	entry.OnChanged = func(s string) {
+		n, err := strconv.Atoi(s)
+		if err == nil && n > 0 {
+			*seconds = n
+		}
+	}
+
+	return entry
+}
+
 func (app *App) showFirstLaunchDialog() {
 	data := settings.Get()
 
@@ -264,6 +494,7 @@ func (app *App) showFirstLaunchDialog() {
 	firstLaunchDialog := dialog.NewCustom("Welcome!", "Save", content, app.mainWin)
 	firstLaunchDialog.SetOnClosed(func() {
 		settings.Update(data)
+		logging.SetLevel(logging.ParseLevel(data.LogLevel))
 
 		err := settings.Save()
 		if err != nil {
@@ -283,6 +514,7 @@ func (app *App) showSettingsDialog() {
 	settingsDialog := dialog.NewCustomConfirm("Settings", "Save", "Cancel", content, func(save bool) {
 		if save {
 			settings.Update(data)
+			logging.SetLevel(logging.ParseLevel(data.LogLevel))
 
 			err := settings.Save()
 			if err != nil {
@@ -336,6 +568,15 @@ func (app *App) showDebugSettingsDialog() {
 	})
 	fakeGsItlCheck.SetChecked(data.FakeGsItl)
 
+	fakeGsFixtureDirEntry := widget.NewEntry()
+	fakeGsFixtureDirEntry.Text = data.FakeGsFixtureDir
+	fakeGsFixtureDirEntry.OnChanged = func(dir string) {
+		data.FakeGsFixtureDir = dir
+	}
+
+	fakeGsFixtureDirFormItem := widget.NewFormItem(">> Fixture Directory", fakeGsFixtureDirEntry)
+	fakeGsFixtureDirFormItem.HintText = "Overrides the built-in responses, <chart hash>/<endpoint>.json for single charts"
+
 	fakeGsCheck := widget.NewCheck("", func(checked bool) {
 		data.FakeGs = checked
 
@@ -345,6 +586,7 @@ func (app *App) showDebugSettingsDialog() {
 			fakeGsSubmitResultSelect.Enable()
 			fakeGsRpgCheck.Enable()
 			fakeGsItlCheck.Enable()
+			fakeGsFixtureDirEntry.Enable()
 			fakeGsNetDelayEntry.Enable()
 		} else {
 			fakeGsNetworkErrorCheck.Disable()
@@ -352,6 +594,7 @@ func (app *App) showDebugSettingsDialog() {
 			fakeGsSubmitResultSelect.Disable()
 			fakeGsRpgCheck.Disable()
 			fakeGsItlCheck.Disable()
+			fakeGsFixtureDirEntry.Disable()
 			fakeGsNetDelayEntry.Disable()
 		}
 	})
@@ -363,15 +606,10 @@ func (app *App) showDebugSettingsDialog() {
 		fakeGsSubmitResultSelect.Disable()
 		fakeGsRpgCheck.Disable()
 		fakeGsItlCheck.Disable()
+		fakeGsFixtureDirEntry.Disable()
 		fakeGsNetDelayEntry.Disable()
 	}
 
-	gsUrlEntry := widget.NewEntry()
-	gsUrlEntry.Text = data.GrooveStatsUrl
-	gsUrlEntry.OnChanged = func(url string) {
-		data.GrooveStatsUrl = url
-	}
-
 	items := []*widget.FormItem{
 		widget.NewFormItem("Simulate GrooveStats Requests", fakeGsCheck),
 		widget.NewFormItem(">> Network Error", fakeGsNetworkErrorCheck),
@@ -380,12 +618,17 @@ func (app *App) showDebugSettingsDialog() {
 		widget.NewFormItem(">> Score Submit Result", fakeGsSubmitResultSelect),
 		widget.NewFormItem(">> RPG active", fakeGsRpgCheck),
 		widget.NewFormItem(">> ITL active", fakeGsItlCheck),
-		widget.NewFormItem("GrooveStats URL", gsUrlEntry),
+		fakeGsFixtureDirFormItem,
 	}
 
 	formDialog := dialog.NewForm("Debug Settings", "Save", "Cancel", items, func(save bool) {
 		if save {
 			settings.Update(data)
+
+			session := app.session
+			if session != nil {
+				session.ApplyDebugSettings()
+			}
 		}
 	}, app.mainWin)
 	formDialog.Show()
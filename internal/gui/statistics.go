@@ -0,0 +1,167 @@
+package gui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+
+	"github.com/GrooveStats/gslauncher/internal/usage"
+)
+
+var statisticsColumns = []struct {
+	title string
+	width float32
+}{
+	{"Start", 140},
+	{"End", 140},
+	{"StepMania", 80},
+	{"Requests", 70},
+	{"Failures", 70},
+	{"Disabled", 70},
+	{"Scores", 60},
+	{"Unlocks", 60},
+	{"Failures by Endpoint", 300},
+}
+
+type statisticsView struct {
+	usage    *usage.Stats
+	sessions []usage.Session
+
+	totalsLabel *widget.Label
+	table       *widget.Table
+}
+
+func (app *App) showStatisticsWindow() {
+	view := &statisticsView{
+		usage:       app.usage,
+		totalsLabel: widget.NewLabel(""),
+	}
+
+	window := app.app.NewWindow("Statistics")
+
+	view.table = widget.NewTable(
+		func() (int, int) {
+			return len(view.sessions) + 1, len(statisticsColumns)
+		},
+		func() fyne.CanvasObject {
+			return widget.NewLabel("")
+		},
+		func(id widget.TableCellID, object fyne.CanvasObject) {
+			label := object.(*widget.Label)
+
+			if id.Row == 0 {
+				label.TextStyle.Bold = true
+				label.SetText(statisticsColumns[id.Col].title)
+				return
+			}
+
+			label.TextStyle.Bold = false
+			label.SetText(describeSessionCell(&view.sessions[id.Row-1], id.Col))
+		},
+	)
+	for i, column := range statisticsColumns {
+		view.table.SetColumnWidth(i, column.width)
+	}
+
+	// the running session keeps changing
+	refreshButton := widget.NewButton("Refresh", view.refresh)
+
+	window.SetContent(container.NewBorder(
+		container.NewBorder(nil, nil, nil, refreshButton, view.totalsLabel),
+		nil,
+		nil,
+		nil,
+		view.table,
+	))
+
+	view.refresh()
+
+	window.Resize(fyne.NewSize(1000, 600))
+	window.Show()
+}
+
+func (view *statisticsView) refresh() {
+	view.sessions = view.usage.Sessions()
+
+	totals := view.usage.Totals()
+	all := usage.Session{Endpoints: totals.Endpoints}
+	requests := all.Requests()
+
+	view.totalsLabel.SetText(fmt.Sprintf(
+		"%d sessions, %s in StepMania, %d requests (%s failed), %d scores submitted, %d unlocks",
+		totals.Sessions,
+		formatDuration(totals.SmRuntime),
+		requests.Requests,
+		formatFailureRate(requests),
+		totals.ScoresSubmitted,
+		totals.UnlocksObtained,
+	))
+
+	view.table.Refresh()
+}
+
+func describeSessionCell(session *usage.Session, col int) string {
+	requests := session.Requests()
+
+	switch col {
+	case 0:
+		return session.Start.Format("2006-01-02 15:04")
+	case 1:
+		if session.End == nil {
+			return "-"
+		}
+		return session.End.Format("2006-01-02 15:04")
+	case 2:
+		return formatDuration(session.SmRuntime)
+	case 3:
+		return fmt.Sprint(requests.Requests)
+	case 4:
+		return formatFailureRate(requests)
+	case 5:
+		return fmt.Sprint(requests.Disabled)
+	case 6:
+		return fmt.Sprint(session.ScoresSubmitted)
+	case 7:
+		return fmt.Sprint(session.UnlocksObtained)
+	case 8:
+		return describeEndpointFailures(session)
+	}
+
+	return ""
+}
+
+func describeEndpointFailures(session *usage.Session) string {
+	names := make([]string, 0, len(session.Endpoints))
+	for name, endpoint := range session.Endpoints {
+		if endpoint.Failures > 0 || endpoint.Disabled > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	descriptions := make([]string, 0, len(names))
+	for _, name := range names {
+		endpoint := session.Endpoints[name]
+		descriptions = append(descriptions, fmt.Sprintf("%s %d/%d", name, endpoint.Failures+endpoint.Disabled, endpoint.Requests))
+	}
+
+	return strings.Join(descriptions, ", ")
+}
+
+func formatFailureRate(requests usage.Endpoint) string {
+	if requests.Requests == 0 {
+		return "0"
+	}
+
+	return fmt.Sprintf("%d (%.0f%%)", requests.Failures, 100*float64(requests.Failures)/float64(requests.Requests))
+}
+
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	return fmt.Sprintf("%d:%02d h", int(d.Hours()), int(d.Minutes())%60)
+}
@@ -66,21 +66,23 @@ func (unlockWidget *UnlockWidget) handleUpdate(unlock *unlocks.Unlock) {
 		})
 		downloadButton.SetIcon(theme.DownloadIcon())
 
-		unpackButton := newUnpackButton(unlock)
+		unpackButton := newUnpackButton(unlockWidget.unlockManager, unlock)
 
 		downloadProgress := widget.NewProgressBar()
 		downloadProgress.Min = 0
 		downloadProgress.Max = 1
 		downloadProgress.SetValue(0)
 		downloadProgress.TextFormatter = func() string {
-			if unlock.DownloadSize == -1 {
+			state := unlockWidget.unlockManager.SnapshotOf(unlock)
+
+			if state.DownloadSize == -1 {
 				return "Connecting..."
 			}
 
 			return fmt.Sprintf(
 				"%s / %s",
-				formatBytes(unlock.DownloadProgress),
-				formatBytes(unlock.DownloadSize),
+				formatBytes(state.DownloadProgress),
+				formatBytes(state.DownloadSize),
 			)
 		}
 
@@ -139,8 +141,11 @@ func (unlockWidget *UnlockWidget) handleUpdate(unlock *unlocks.Unlock) {
 		unlockWidget.unlockInfos[unlock] = info
 	}
 
+	// the unlock is changed while it is downloaded or unpacked
+	state := unlockWidget.unlockManager.SnapshotOf(unlock)
+
 	unpacked := true
-	for _, user := range unlock.Users {
+	for _, user := range state.Users {
 		if user.UnpackStatus != unlocks.Unpacked {
 			unpacked = false
 		}
@@ -159,7 +164,7 @@ func (unlockWidget *UnlockWidget) handleUpdate(unlock *unlocks.Unlock) {
 		return
 	}
 
-	switch unlock.DownloadStatus {
+	switch state.DownloadStatus {
 	case unlocks.NotDownloaded:
 		info.downloadButton.Show()
 		info.downloadProgress.Hide()
@@ -168,16 +173,16 @@ func (unlockWidget *UnlockWidget) handleUpdate(unlock *unlocks.Unlock) {
 		info.unpackProgress.Stop()
 		info.successIcon.Hide()
 
-		if unlock.DownloadError == nil {
+		if state.DownloadError == nil {
 			info.errorIcon.Hide()
 			info.errorLabel.Hide()
 		} else {
 			info.errorIcon.Show()
 			info.errorLabel.Show()
-			info.errorLabel.SetText(fmt.Sprintf("Download failed: %v", unlock.DownloadError))
+			info.errorLabel.SetText(fmt.Sprintf("Download failed: %v", state.DownloadError))
 		}
 	case unlocks.Downloading:
-		progress := float64(unlock.DownloadProgress) / float64(unlock.DownloadSize)
+		progress := float64(state.DownloadProgress) / float64(state.DownloadSize)
 
 		info.downloadButton.Hide()
 		info.downloadProgress.Show()
@@ -196,7 +201,7 @@ func (unlockWidget *UnlockWidget) handleUpdate(unlock *unlocks.Unlock) {
 		unpacking := false
 		unpackErrors := make([]string, 0)
 
-		for _, user := range unlock.Users {
+		for _, user := range state.Users {
 			switch user.UnpackStatus {
 			case unlocks.NotUnpacked:
 				unpacked = false
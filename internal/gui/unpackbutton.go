@@ -14,21 +14,24 @@ import (
 type unpackButton struct {
 	widget.Button
 
-	unlock *unlocks.Unlock
+	unlockManager *unlocks.Manager
+	unlock        *unlocks.Unlock
 }
 
 func (button *unpackButton) Tapped(e *fyne.PointEvent) {
 	if settings.Get().UserUnlocks {
 		items := make([]*fyne.MenuItem, 0)
 
-		for _, u := range button.unlock.Users {
+		state := button.unlockManager.SnapshotOf(button.unlock)
+
+		for _, u := range state.Users {
 			user := u
 
 			if user.UnpackStatus == unlocks.NotUnpacked {
 				menuItem := fyne.NewMenuItem(
 					"Unpack for "+user.ProfileName,
 					func() {
-						button.unlock.QueueUnpack(user)
+						button.unlock.QueueUnpack(user.ProfileName)
 					},
 				)
 				items = append(items, menuItem)
@@ -48,18 +51,19 @@ func (button *unpackButton) Tapped(e *fyne.PointEvent) {
 			e.AbsolutePosition,
 		)
 	} else {
-		button.unlock.QueueUnpack(nil)
+		button.unlock.QueueUnpack("")
 	}
 }
 
-func newUnpackButton(unlock *unlocks.Unlock) *unpackButton {
+func newUnpackButton(unlockManager *unlocks.Manager, unlock *unlocks.Unlock) *unpackButton {
 	button := &unpackButton{
 		Button: widget.Button{
 			Text: "Unpack",
 			Icon: theme.FolderOpenIcon(),
 		},
 
-		unlock: unlock,
+		unlockManager: unlockManager,
+		unlock:        unlock,
 	}
 
 	button.ExtendBaseWidget(button)
@@ -0,0 +1,103 @@
+package history
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+var csvHeader = []string{
+	"submitted",
+	"profileName",
+	"chartHash",
+	"score",
+	"rate",
+	"result",
+	"scoreDelta",
+	"isRanked",
+	"comment",
+	"rpgName",
+	"rpgResult",
+	"rpgScoreDelta",
+	"itlName",
+	"itlScoreDelta",
+	"itlPointTotal",
+	"itlRankingPointTotal",
+}
+
+// WriteCsv exports entries as CSV with a header line. Scores and score
+// deltas are written as percentages, rates as multipliers and ITL values as
+// points.
+func WriteCsv(w io.Writer, entries []Entry) error {
+	writer := csv.NewWriter(w)
+
+	err := writer.Write(csvHeader)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		record := []string{
+			entry.Submitted.Format(time.RFC3339),
+			entry.ProfileName,
+			entry.ChartHash,
+			formatHundredths(entry.Score),
+			formatHundredths(entry.Rate),
+			entry.Result,
+			formatScoreDelta(entry.ScoreDelta),
+			strconv.FormatBool(entry.IsRanked),
+			entry.Comment,
+			"", "", "",
+			"", "", "", "",
+		}
+
+		if entry.Rpg != nil {
+			record[9] = entry.Rpg.Name
+			record[10] = entry.Rpg.Result
+			record[11] = formatScoreDelta(entry.Rpg.ScoreDelta)
+		}
+
+		if entry.Itl != nil {
+			record[12] = entry.Itl.Name
+			record[13] = formatDelta(entry.Itl.ScoreDelta)
+			record[14] = strconv.Itoa(entry.Itl.CurrentPointTotal)
+			record[15] = strconv.Itoa(entry.Itl.CurrentRankingPointTotal)
+		}
+
+		err := writer.Write(record)
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteJson exports entries as a JSON array.
+func WriteJson(w io.Writer, entries []Entry) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(entries)
+}
+
+func formatHundredths(n int) string {
+	return fmt.Sprintf("%.2f", float64(n)/100)
+}
+
+func formatScoreDelta(delta *int) string {
+	if delta == nil {
+		return ""
+	}
+	return formatHundredths(*delta)
+}
+
+func formatDelta(delta *int) string {
+	if delta == nil {
+		return ""
+	}
+	return strconv.Itoa(*delta)
+}
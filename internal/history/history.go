@@ -0,0 +1,276 @@
+package history
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/GrooveStats/gslauncher/internal/fsipc"
+	"github.com/GrooveStats/gslauncher/internal/groovestats"
+	"github.com/GrooveStats/gslauncher/internal/logging"
+)
+
+var scoresBucket = []byte("scores")
+
+type RpgResult struct {
+	Name       string `json:"name"`
+	Result     string `json:"result"`
+	ScoreDelta *int   `json:"scoreDelta,omitempty"`
+	RateDelta  *int   `json:"rateDelta,omitempty"`
+}
+
+type ItlResult struct {
+	Name                      string `json:"name"`
+	TopScorePoints            *int   `json:"topScorePoints,omitempty"`
+	ScoreDelta                *int   `json:"scoreDelta,omitempty"`
+	CurrentPointTotal         int    `json:"currentPointTotal"`
+	PreviousPointTotal        *int   `json:"previousPointTotal,omitempty"`
+	CurrentRankingPointTotal  int    `json:"currentRankingPointTotal"`
+	PreviousRankingPointTotal *int   `json:"previousRankingPointTotal,omitempty"`
+}
+
+// Entry is the score of a single player. Api keys are never stored.
+type Entry struct {
+	Id             uint64                `json:"id"`
+	Submitted      time.Time             `json:"submitted"`
+	ProfileName    string                `json:"profileName"`
+	ChartHash      string                `json:"chartHash"`
+	Score          int                   `json:"score"`
+	Rate           int                   `json:"rate"`
+	Comment        string                `json:"comment"`
+	UsedCmod       *bool                 `json:"usedCmod,omitempty"`
+	JudgmentCounts *fsipc.JudgmentCounts `json:"judgmentCounts,omitempty"`
+	IsRanked       bool                  `json:"isRanked"`
+	Result         string                `json:"result"`
+	ScoreDelta     *int                  `json:"scoreDelta,omitempty"`
+	Rpg            *RpgResult            `json:"rpg,omitempty"`
+	Itl            *ItlResult            `json:"itl,omitempty"`
+}
+
+// Filter selects history entries. Empty fields match everything.
+type Filter struct {
+	ProfileName string
+	ChartHash   string
+	Result      string
+	From        time.Time
+	To          time.Time
+}
+
+func (filter *Filter) matches(entry *Entry) bool {
+	if filter.ProfileName != "" && entry.ProfileName != filter.ProfileName {
+		return false
+	}
+	if filter.ChartHash != "" && entry.ChartHash != filter.ChartHash {
+		return false
+	}
+	if filter.Result != "" && entry.Result != filter.Result {
+		return false
+	}
+	if !filter.From.IsZero() && entry.Submitted.Before(filter.From) {
+		return false
+	}
+	if !filter.To.IsZero() && !entry.Submitted.Before(filter.To) {
+		return false
+	}
+
+	return true
+}
+
+type History struct {
+	db             *bolt.DB
+	logger         *slog.Logger
+	mutex          sync.Mutex
+	updateCallback func()
+}
+
+func NewHistory(cacheDir string) (*History, error) {
+	dir := filepath.Join(cacheDir, "groovestats-launcher")
+
+	err := os.MkdirAll(dir, os.ModeDir|0700)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only one launcher can use the database, don't wait forever if
+	// another one is running.
+	db, err := bolt.Open(filepath.Join(dir, "history.db"), 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(scoresBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &History{
+		db:             db,
+		logger:         logging.New("History"),
+		updateCallback: func() {},
+	}, nil
+}
+
+func (history *History) Close() error {
+	return history.db.Close()
+}
+
+func (history *History) SetUpdateCallback(callback func()) {
+	history.mutex.Lock()
+	history.updateCallback = callback
+	history.mutex.Unlock()
+}
+
+// Add stores the scores of a successful submission, one entry per player.
+func (history *History) Add(request *fsipc.GsScoreSubmitRequest, response *groovestats.ScoreSubmitResponse, submitted time.Time) error {
+	entries := make([]*Entry, 0, 2)
+
+	if request.Player1 != nil && response.Player1 != nil {
+		entry, err := newEntry(request.Player1, response.Player1, submitted)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+	}
+
+	if request.Player2 != nil && response.Player2 != nil {
+		entry, err := newEntry(request.Player2, response.Player2, submitted)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+	}
+
+	if len(entries) == 0 {
+		return nil
+	}
+
+	err := history.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(scoresBucket)
+
+		for _, entry := range entries {
+			id, err := bucket.NextSequence()
+			if err != nil {
+				return err
+			}
+			entry.Id = id
+
+			data, err := json.Marshal(entry)
+			if err != nil {
+				return err
+			}
+
+			err = bucket.Put(idKey(id), data)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	history.mutex.Lock()
+	callback := history.updateCallback
+	history.mutex.Unlock()
+	callback()
+
+	return nil
+}
+
+// newEntry combines the data of a player from the request and the response.
+// Both use the same field names as the entry, so the fields are copied with a
+// JSON round trip. The api key has no field in the entry and is dropped.
+func newEntry(player interface{}, result interface{}, submitted time.Time) (*Entry, error) {
+	entry := &Entry{}
+
+	for _, v := range []interface{}{player, result} {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal(data, entry)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	entry.Submitted = submitted
+
+	return entry, nil
+}
+
+// Entries returns the entries matching the filter, newest first.
+func (history *History) Entries(filter Filter) ([]Entry, error) {
+	entries := make([]Entry, 0)
+
+	err := history.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(scoresBucket).Cursor()
+
+		for key, value := cursor.Last(); key != nil; key, value = cursor.Prev() {
+			var entry Entry
+
+			err := json.Unmarshal(value, &entry)
+			if err != nil {
+				history.logger.Warn("skipping corrupt entry", "id", binary.BigEndian.Uint64(key), "err", err)
+				continue
+			}
+
+			if filter.matches(&entry) {
+				entries = append(entries, entry)
+			}
+		}
+
+		return nil
+	})
+
+	return entries, err
+}
+
+// Values returns the distinct profile names and results of all entries, to
+// offer them as filter options.
+func (history *History) Values() (profileNames []string, results []string, err error) {
+	entries, err := history.Entries(Filter{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return distinct(entries, func(entry *Entry) string { return entry.ProfileName }),
+		distinct(entries, func(entry *Entry) string { return entry.Result }),
+		nil
+}
+
+func distinct(entries []Entry, field func(entry *Entry) string) []string {
+	seen := make(map[string]bool)
+	values := make([]string, 0)
+
+	for i := range entries {
+		value := field(&entries[i])
+		if value != "" && !seen[value] {
+			seen[value] = true
+			values = append(values, value)
+		}
+	}
+
+	sort.Strings(values)
+	return values
+}
+
+func idKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
@@ -0,0 +1,207 @@
+package history
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GrooveStats/gslauncher/internal/fsipc"
+	"github.com/GrooveStats/gslauncher/internal/groovestats"
+)
+
+const submitRequestJson = `{
+	"player1": {
+		"apiKey": "topsecret",
+		"profileName": "domp",
+		"chartHash": "hash1",
+		"score": 9876,
+		"rate": 100,
+		"comment": "C700"
+	},
+	"player2": {
+		"apiKey": "topsecret2",
+		"profileName": "natano",
+		"chartHash": "hash1",
+		"score": 8630,
+		"rate": 150
+	}
+}`
+
+const submitResponseJson = `{
+	"player1": {
+		"chartHash": "hash1",
+		"isRanked": true,
+		"result": "improved",
+		"scoreDelta": 12,
+		"gsLeaderboard": [],
+		"itl": {
+			"name": "ITL Online 2022",
+			"scoreDelta": 40,
+			"currentPointTotal": 1200,
+			"currentRankingPointTotal": 900
+		}
+	},
+	"player2": {
+		"chartHash": "hash1",
+		"isRanked": true,
+		"result": "score-added",
+		"gsLeaderboard": []
+	}
+}`
+
+func newTestHistory(t *testing.T) *History {
+	history, err := NewHistory(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { history.Close() })
+
+	return history
+}
+
+func addTestScores(t *testing.T, history *History, submitted time.Time) {
+	var request fsipc.GsScoreSubmitRequest
+	var response groovestats.ScoreSubmitResponse
+
+	err := json.Unmarshal([]byte(submitRequestJson), &request)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = json.Unmarshal([]byte(submitResponseJson), &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = history.Add(&request, &response, submitted)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	history := newTestHistory(t)
+
+	updates := 0
+	history.SetUpdateCallback(func() { updates++ })
+
+	day := time.Date(2022, 6, 30, 20, 0, 0, 0, time.UTC)
+	addTestScores(t, history, day)
+	addTestScores(t, history, day.AddDate(0, 0, 1))
+
+	if updates != 2 {
+		t.Fatalf("expected 2 updates, got %d", updates)
+	}
+
+	entries, err := history.Entries(Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 entries, got %d", len(entries))
+	}
+
+	// newest first
+	if !entries[0].Submitted.After(entries[3].Submitted) {
+		t.Fatal("entries not sorted")
+	}
+
+	var entry *Entry
+	for i := range entries {
+		if entries[i].ProfileName == "domp" {
+			entry = &entries[i]
+			break
+		}
+	}
+	if entry.Score != 9876 || entry.Rate != 100 || entry.Comment != "C700" || entry.Result != "improved" {
+		t.Fatalf("unexpected entry %+v", entry)
+	}
+	if entry.ScoreDelta == nil || *entry.ScoreDelta != 12 {
+		t.Fatal("score delta missing")
+	}
+	if entry.Itl == nil || entry.Itl.CurrentPointTotal != 1200 {
+		t.Fatal("itl result missing")
+	}
+
+	data, _ := json.Marshal(entries)
+	if bytes.Contains(data, []byte("topsecret")) {
+		t.Fatal("api key stored")
+	}
+
+	tests := []struct {
+		filter Filter
+		count  int
+	}{
+		{Filter{ProfileName: "domp"}, 2},
+		{Filter{ChartHash: "hash2"}, 0},
+		{Filter{Result: "score-added"}, 2},
+		{Filter{From: day.AddDate(0, 0, 1)}, 2},
+		{Filter{To: day.AddDate(0, 0, 1)}, 2},
+		{Filter{ProfileName: "natano", From: day.AddDate(0, 0, 1)}, 1},
+	}
+
+	for _, test := range tests {
+		entries, err := history.Entries(test.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != test.count {
+			t.Errorf("filter %+v: expected %d entries, got %d", test.filter, test.count, len(entries))
+		}
+	}
+
+	profileNames, results, err := history.Values()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(profileNames, ",") != "domp,natano" || strings.Join(results, ",") != "improved,score-added" {
+		t.Fatalf("unexpected values %v %v", profileNames, results)
+	}
+}
+
+func TestExport(t *testing.T) {
+	history := newTestHistory(t)
+	addTestScores(t, history, time.Date(2022, 6, 30, 20, 0, 0, 0, time.UTC))
+
+	entries, err := history.Entries(Filter{ProfileName: "domp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	err = WriteCsv(&buf, entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected header and one record, got %d lines", len(records))
+	}
+
+	expected := "2022-06-30T20:00:00Z,domp,hash1,98.76,1.00,improved,0.12,true,C700,,,,ITL Online 2022,40,1200,900"
+	if got := strings.Join(records[1], ","); got != expected {
+		t.Fatalf("unexpected record\n got: %s\nwant: %s", got, expected)
+	}
+
+	buf.Reset()
+	err = WriteJson(&buf, entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var exported []Entry
+	err = json.Unmarshal(buf.Bytes(), &exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exported) != 1 || exported[0].ProfileName != "domp" {
+		t.Fatalf("unexpected export %+v", exported)
+	}
+}
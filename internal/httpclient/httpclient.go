@@ -0,0 +1,180 @@
+// Package httpclient builds the HTTP clients used to talk to GrooveStats and
+// to download unlocks. They use the proxy and CA certificates from the
+// settings and identify the launcher in the User-Agent header.
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/GrooveStats/gslauncher/internal/settings"
+	"github.com/GrooveStats/gslauncher/internal/version"
+)
+
+// UserAgent returns the User-Agent header sent with every request, e.g.
+// "GrooveStatsLauncher/1.6.1 (windows)".
+func UserAgent() string {
+	return fmt.Sprintf("GrooveStatsLauncher/%s (%s)", version.Formatted(), runtime.GOOS)
+}
+
+// the settings the transports are built from
+type transportSettings struct {
+	proxy           string
+	caBundle        string
+	downloadTimeout int
+}
+
+// The transports are only rebuilt when their settings change, so the clients
+// share connections and the CA bundle isn't read for every download.
+var (
+	transportMutex    sync.Mutex
+	transportKey      transportSettings
+	transport         *http.Transport
+	downloadTransport *http.Transport
+)
+
+// New returns a client configured from the current settings. It has no
+// overall timeout, callers limit their requests with a context.
+func New() (*http.Client, error) {
+	transport, _, err := getTransports()
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{
+		Transport: &userAgentTransport{base: transport},
+	}, nil
+}
+
+// NewDownloadClient returns a client for downloads. Downloads take as long
+// as they take, but the server must start answering within the download
+// timeout.
+func NewDownloadClient() (*http.Client, error) {
+	_, downloadTransport, err := getTransports()
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{
+		Transport: &userAgentTransport{base: downloadTransport},
+	}, nil
+}
+
+func getTransports() (*http.Transport, *http.Transport, error) {
+	s := settings.Get()
+	key := transportSettings{
+		proxy:           s.HttpProxy,
+		caBundle:        s.HttpCaBundle,
+		downloadTimeout: s.HttpDownloadTimeout,
+	}
+
+	transportMutex.Lock()
+	defer transportMutex.Unlock()
+
+	if transport != nil && key == transportKey {
+		return transport, downloadTransport, nil
+	}
+
+	newTransport, err := buildTransport(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	newDownloadTransport := newTransport.Clone()
+	newDownloadTransport.ResponseHeaderTimeout = time.Duration(key.downloadTimeout) * time.Second
+
+	// clients of the old settings keep working, but don't keep their
+	// connections around
+	if transport != nil {
+		transport.CloseIdleConnections()
+		downloadTransport.CloseIdleConnections()
+	}
+
+	transportKey = key
+	transport = newTransport
+	downloadTransport = newDownloadTransport
+
+	return transport, downloadTransport, nil
+}
+
+func buildTransport(key transportSettings) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	proxy, err := ParseProxy(key.proxy)
+	if err != nil {
+		return nil, err
+	}
+	if proxy != nil {
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	if key.caBundle != "" {
+		pool, err := LoadCaBundle(key.caBundle)
+		if err != nil {
+			return nil, err
+		}
+
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return transport, nil
+}
+
+// ParseProxy parses the proxy setting. An empty string returns nil, the
+// proxy from the environment is used then.
+func ParseProxy(proxy string) (*url.URL, error) {
+	if proxy == "" {
+		return nil, nil
+	}
+
+	proxyUrl, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy: %w", err)
+	}
+	if proxyUrl.Scheme == "" || proxyUrl.Host == "" {
+		return nil, errors.New("invalid proxy: must look like http://host:port")
+	}
+
+	return proxyUrl, nil
+}
+
+// LoadCaBundle returns the system certificates plus the ones in the given PEM
+// file.
+func LoadCaBundle(filename string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", filename)
+	}
+
+	return pool, nil
+}
+
+type userAgentTransport struct {
+	base http.RoundTripper
+}
+
+func (transport *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", UserAgent())
+	}
+
+	return transport.base.RoundTrip(req)
+}
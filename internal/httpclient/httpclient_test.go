@@ -0,0 +1,188 @@
+package httpclient
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GrooveStats/gslauncher/internal/settings"
+)
+
+func updateSettings(t *testing.T, update func(s *settings.Settings)) {
+	oldSettings := settings.Get()
+	t.Cleanup(func() { settings.Update(oldSettings) })
+
+	newSettings := settings.Get()
+	update(&newSettings)
+	settings.Update(newSettings)
+}
+
+func TestUserAgent(t *testing.T) {
+	var userAgent atomic.Value
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent.Store(r.UserAgent())
+	}))
+	defer server.Close()
+
+	client, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	got := userAgent.Load().(string)
+	if got != UserAgent() || !strings.HasPrefix(got, "GrooveStatsLauncher/") {
+		t.Fatalf("unexpected User-Agent %q", got)
+	}
+}
+
+func TestProxy(t *testing.T) {
+	var proxied int32
+
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// proxies receive the absolute url
+		if r.URL.Host == "groovestats.invalid" {
+			atomic.AddInt32(&proxied, 1)
+		}
+	}))
+	defer proxy.Close()
+
+	updateSettings(t, func(s *settings.Settings) {
+		s.HttpProxy = proxy.URL
+	})
+
+	client, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Get("http://groovestats.invalid/new-session.php")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if atomic.LoadInt32(&proxied) != 1 {
+		t.Fatal("request didn't go through the proxy")
+	}
+}
+
+func TestParseProxy(t *testing.T) {
+	tests := []struct {
+		proxy string
+		valid bool
+	}{
+		{"", true},
+		{"http://proxy:3128", true},
+		{"socks5://127.0.0.1:1080", true},
+		{"proxy:3128", false},
+		{"http://", false},
+	}
+
+	for _, test := range tests {
+		_, err := ParseProxy(test.proxy)
+		if (err == nil) != test.valid {
+			t.Errorf("%q: unexpected result %v", test.proxy, err)
+		}
+	}
+}
+
+func TestCaBundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	client, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Get(server.URL)
+	if err == nil {
+		t.Fatal("untrusted certificate accepted")
+	}
+
+	filename := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	err = os.WriteFile(filename, data, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updateSettings(t, func(s *settings.Settings) {
+		s.HttpCaBundle = filename
+	})
+
+	client, err = New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// not a certificate
+	invalidFilename := filepath.Join(t.TempDir(), "invalid.pem")
+	err = os.WriteFile(invalidFilename, []byte("nope"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updateSettings(t, func(s *settings.Settings) {
+		s.HttpCaBundle = invalidFilename
+	})
+
+	_, err = New()
+	if err == nil {
+		t.Fatal("expected an error for an invalid bundle")
+	}
+}
+
+func TestTransportReuse(t *testing.T) {
+	transports := func() (http.RoundTripper, http.RoundTripper) {
+		client, err := New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		downloadClient, err := NewDownloadClient()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return client.Transport.(*userAgentTransport).base, downloadClient.Transport.(*userAgentTransport).base
+	}
+
+	first, firstDownload := transports()
+	second, secondDownload := transports()
+	if first != second || firstDownload != secondDownload {
+		t.Error("transports rebuilt without a settings change")
+	}
+
+	updateSettings(t, func(s *settings.Settings) {
+		s.HttpDownloadTimeout++
+	})
+
+	third, thirdDownload := transports()
+	if third == first || thirdDownload == firstDownload {
+		t.Error("transports not rebuilt after a settings change")
+	}
+
+	timeout := time.Duration(settings.Get().HttpDownloadTimeout) * time.Second
+	if thirdDownload.(*http.Transport).ResponseHeaderTimeout != timeout {
+		t.Errorf("download timeout not applied")
+	}
+}
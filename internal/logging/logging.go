@@ -0,0 +1,74 @@
+// Package logging sets up the structured log of the launcher. Records are
+// written as JSON lines to rotated files in the cache directory, the level
+// can be changed while the launcher is running, and api keys and profile
+// names are redacted from every record.
+package logging
+
+import (
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var level = new(slog.LevelVar)
+
+// Setup sends all records, including the ones of the standard log package,
+// to the log files in the cache directory. Debug builds write them to stderr
+// as well.
+func Setup(cacheDir string, debug bool) (io.Closer, error) {
+	rotator, err := OpenRotator(filepath.Join(cacheDir, "groovestats-launcher", "log.txt"))
+	if err != nil {
+		return nil, err
+	}
+
+	var w io.Writer = rotator
+	if debug {
+		w = io.MultiWriter(os.Stderr, rotator)
+	}
+
+	slog.SetDefault(slog.New(NewHandler(w)))
+
+	// slog.SetDefault sends the standard log package to the handler, its
+	// own timestamps would only be noise in the message
+	log.SetFlags(0)
+
+	return rotator, nil
+}
+
+// NewHandler returns a JSON handler that observes the current level and
+// redacts secrets.
+func NewHandler(w io.Writer) slog.Handler {
+	return slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redactAttr,
+	})
+}
+
+// New returns the logger of a component, e.g. "GS". Call it after Setup.
+func New(component string) *slog.Logger {
+	return slog.Default().With("component", component)
+}
+
+func SetLevel(l slog.Level) {
+	level.Set(l)
+}
+
+func Level() slog.Level {
+	return level.Level()
+}
+
+// ParseLevel parses a level setting like "debug" or "warn". Unknown levels
+// fall back to info.
+func ParseLevel(s string) slog.Level {
+	var l slog.Level
+
+	err := l.UnmarshalText([]byte(strings.TrimSpace(s)))
+	if err != nil {
+		return slog.LevelInfo
+	}
+
+	return l
+}
@@ -0,0 +1,248 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedact(t *testing.T) {
+	AddSecrets([]byte(`{"player1":{"apiKey":"0123456789abcdef","profileName":"Alice"}}`))
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`{"apiKey":"fedcba9876543210","profileName":"Bob"}`,
+			`{"apiKey":"<redacted>","profileName":"<redacted>"}`,
+		},
+		{
+			`x-api-key-player-1: fedcba9876543210`,
+			`x-api-key-player-1: <redacted>`,
+		},
+		{
+			`map[X-Api-Key-Player-2:[fedcba9876543210]]`,
+			`map[X-Api-Key-Player-2:[<redacted>]]`,
+		},
+		{
+			`{"x-api-key-player-1":"fedcba9876543210"}`,
+			`{"x-api-key-player-1":"<redacted>"}`,
+		},
+		{
+			`request failed: key 0123456789abcdef rejected`,
+			`request failed: key <redacted> rejected`,
+		},
+		{
+			`Alice unlocked a song, Alice's score`,
+			`<redacted> unlocked a song, <redacted>'s score`,
+		},
+		{
+			`Alicella and MalicE are other players`,
+			`Alicella and MalicE are other players`,
+		},
+	}
+
+	for _, test := range tests {
+		actual := Redact(test.input)
+		if actual != test.expected {
+			t.Errorf("Redact(%q) = %q, expected %q", test.input, actual, test.expected)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	AddApiKey("handlerkey123456")
+
+	type request struct {
+		ApiKey      string `json:"apiKey"`
+		ProfileName string `json:"profileName"`
+	}
+
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(&buf))
+
+	logger.Info(
+		"using handlerkey123456",
+		"err", errors.New("rejected handlerkey123456"),
+		"request", request{ApiKey: "anotherkey987654", ProfileName: "Carol"},
+		"apiKey", "short",
+	)
+
+	output := buf.String()
+	for _, secret := range []string{"handlerkey123456", "anotherkey987654", "Carol", "short"} {
+		if strings.Contains(output, secret) {
+			t.Errorf("%q not redacted: %s", secret, output)
+		}
+	}
+}
+
+func TestLevel(t *testing.T) {
+	defer SetLevel(Level())
+
+	var buf bytes.Buffer
+	logger := slog.New(NewHandler(&buf))
+
+	SetLevel(ParseLevel("info"))
+	logger.Debug("hidden")
+	if buf.Len() != 0 {
+		t.Errorf("debug record logged at info level: %s", buf.String())
+	}
+
+	SetLevel(ParseLevel("debug"))
+	logger.Debug("shown")
+	if !strings.Contains(buf.String(), "shown") {
+		t.Errorf("debug record not logged at debug level")
+	}
+
+	if ParseLevel("bogus") != slog.LevelInfo {
+		t.Errorf("unknown levels should fall back to info")
+	}
+}
+
+func TestRotateSize(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "log.txt")
+
+	rotator, err := OpenRotator(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rotator.Close()
+
+	rotator.MaxSize = 10
+	rotator.MaxFiles = 2
+
+	for i := 0; i < 5; i++ {
+		_, err = rotator.Write([]byte("0123456789\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range []string{"log.txt", "log.1.txt", "log.2.txt"} {
+		_, err = os.Stat(filepath.Join(filepath.Dir(filename), name))
+		if err != nil {
+			t.Errorf("%s missing: %v", name, err)
+		}
+	}
+
+	_, err = os.Stat(filepath.Join(filepath.Dir(filename), "log.3.txt"))
+	if !os.IsNotExist(err) {
+		t.Errorf("more than MaxFiles rotated files are kept")
+	}
+}
+
+func TestRotateRenameFails(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "log.txt")
+
+	rotator, err := OpenRotator(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rotator.Close()
+
+	rotator.MaxSize = 10
+	rotator.MaxFiles = 3
+
+	for n := 1; n <= 3; n++ {
+		err = os.WriteFile(rotator.backupName(n), []byte(fmt.Sprintf("backup %d\n", n)), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a directory that isn't empty can't be replaced by the log
+	blocker := filepath.Join(filename+".rotating", "blocked")
+	err = os.MkdirAll(blocker, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := ""
+	for i := 0; i < 10; i++ {
+		line := fmt.Sprintf("%010d\n", i)
+		expected += line
+
+		_, err = rotator.Write([]byte(line))
+		if err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil || string(data) != expected {
+		t.Errorf("logging didn't continue in the current file: %q %v", data, err)
+	}
+
+	for n := 1; n <= 3; n++ {
+		data, err := os.ReadFile(rotator.backupName(n))
+		if err != nil || string(data) != fmt.Sprintf("backup %d\n", n) {
+			t.Errorf("backup %d lost after failed rotations: %q %v", n, data, err)
+		}
+	}
+
+	// rotating works again once the rename succeeds
+	err = os.RemoveAll(filepath.Dir(blocker))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = rotator.Write([]byte("last\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, content := range map[string]string{
+		filename:              "last\n",
+		rotator.backupName(1): expected,
+		rotator.backupName(2): "backup 1\n",
+		rotator.backupName(3): "backup 2\n",
+	} {
+		data, err := os.ReadFile(name)
+		if err != nil || string(data) != content {
+			t.Errorf("unexpected %s after rotating: %q %v", filepath.Base(name), data, err)
+		}
+	}
+}
+
+func TestRotateAge(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "log.txt")
+
+	err := os.WriteFile(filename, []byte("old\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old := time.Now().Add(-48 * time.Hour)
+	err = os.Chtimes(filename, old, old)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotator, err := OpenRotator(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rotator.Close()
+
+	_, err = rotator.Write([]byte("new\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(rotator.backupName(1))
+	if err != nil || string(data) != "old\n" {
+		t.Errorf("old log not rotated: %q %v", data, err)
+	}
+
+	data, err = os.ReadFile(filename)
+	if err != nil || string(data) != "new\n" {
+		t.Errorf("unexpected log: %q %v", data, err)
+	}
+}
@@ -0,0 +1,186 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+const redacted = "<redacted>"
+
+// api keys shorter than this are ignored, they would redact too much
+const minApiKeyLength = 8
+
+var (
+	// "apiKey": "..." and "profileName": "..." in JSON
+	fieldRegexp = regexp.MustCompile(`(?i)("(?:apiKey|profileName)"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+
+	// x-api-key-player-1: ..., "x-api-key-player-1":"..." and the
+	// X-Api-Key-Player-1:[...] of a printed http.Header
+	headerRegexp = regexp.MustCompile(`(?i)(x-api-key-player-\d+"?\s*[:=]\s*"?\[?)[^\s"\],}]+`)
+
+	// captures the values of the fields above, to learn the secrets of
+	// the players
+	fieldValueRegexp = regexp.MustCompile(`"(apiKey|profileName)"\s*:\s*("(?:[^"\\]|\\.)*")`)
+)
+
+var secrets = struct {
+	mutex        sync.RWMutex
+	apiKeys      map[string]bool
+	profileNames map[string]bool
+	apiKeyList   *strings.Replacer
+}{
+	apiKeys:      make(map[string]bool),
+	profileNames: make(map[string]bool),
+	apiKeyList:   strings.NewReplacer(),
+}
+
+// AddApiKey makes sure the api key is redacted wherever it appears.
+func AddApiKey(apiKey string) {
+	if len(apiKey) < minApiKeyLength {
+		return
+	}
+
+	secrets.mutex.Lock()
+	defer secrets.mutex.Unlock()
+
+	if secrets.apiKeys[apiKey] {
+		return
+	}
+	secrets.apiKeys[apiKey] = true
+
+	pairs := make([]string, 0, 2*len(secrets.apiKeys))
+	for key := range secrets.apiKeys {
+		pairs = append(pairs, key, redacted)
+	}
+	secrets.apiKeyList = strings.NewReplacer(pairs...)
+}
+
+// AddProfileName makes sure the profile name is redacted wherever it
+// appears as a whole word.
+func AddProfileName(profileName string) {
+	profileName = strings.TrimSpace(profileName)
+	if profileName == "" {
+		return
+	}
+
+	secrets.mutex.Lock()
+	secrets.profileNames[profileName] = true
+	secrets.mutex.Unlock()
+}
+
+// AddSecrets learns the api keys and profile names in a JSON request, so
+// they are redacted in all later records.
+func AddSecrets(data []byte) {
+	for _, match := range fieldValueRegexp.FindAllSubmatch(data, -1) {
+		var value string
+		if json.Unmarshal(match[2], &value) != nil {
+			continue
+		}
+
+		if string(match[1]) == "apiKey" {
+			AddApiKey(value)
+		} else {
+			AddProfileName(value)
+		}
+	}
+}
+
+// Redact removes api keys and profile names from a string.
+func Redact(s string) string {
+	s = fieldRegexp.ReplaceAllString(s, `$1"`+redacted+`"`)
+	s = headerRegexp.ReplaceAllString(s, "${1}"+redacted)
+
+	secrets.mutex.RLock()
+	defer secrets.mutex.RUnlock()
+
+	s = secrets.apiKeyList.Replace(s)
+	for profileName := range secrets.profileNames {
+		s = replaceWord(s, profileName, redacted)
+	}
+
+	return s
+}
+
+// replaceWord replaces word in s, unless it is part of a longer word.
+func replaceWord(s, word, replacement string) string {
+	var b strings.Builder
+
+	for {
+		i := strings.Index(s, word)
+		if i < 0 {
+			b.WriteString(s)
+			return b.String()
+		}
+
+		before, _ := utf8.DecodeLastRuneInString(s[:i])
+		after, _ := utf8.DecodeRuneInString(s[i+len(word):])
+
+		b.WriteString(s[:i])
+		if i > 0 && isWordRune(before) || i+len(word) < len(s) && isWordRune(after) {
+			b.WriteString(word)
+		} else {
+			b.WriteString(replacement)
+		}
+		s = s[i+len(word):]
+	}
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// redactAttr is the ReplaceAttr function of the handler. It redacts the
+// message and the attributes of every record.
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	if key == "apikey" || key == "profilename" || strings.HasPrefix(key, "x-api-key") {
+		return slog.String(a.Key, redacted)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, Redact(a.Value.String()))
+	case slog.KindAny:
+		return slog.Any(a.Key, redactValue(a.Value.Any()))
+	}
+
+	return a
+}
+
+func redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case error:
+		return Redact(v.Error())
+	case []byte:
+		return redactJson(v)
+	case json.RawMessage:
+		return redactJson(v)
+	case fmt.Stringer:
+		return Redact(v.String())
+	}
+
+	// structs and maps, e.g. requests, are redacted in their JSON form
+	data, err := json.Marshal(v)
+	if err != nil {
+		return Redact(fmt.Sprint(v))
+	}
+
+	return redactJson(data)
+}
+
+func redactJson(data []byte) interface{} {
+	s := Redact(string(data))
+	if json.Valid([]byte(s)) {
+		return json.RawMessage(s)
+	}
+
+	return s
+}
@@ -0,0 +1,168 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rotator is a log file that is rotated when it gets too big or too old.
+// Rotated files are named like log.1.txt, log.2.txt, ... with the highest
+// number being the oldest.
+type Rotator struct {
+	filename string
+
+	// rotate once the file reaches this size or age
+	MaxSize int64
+	MaxAge  time.Duration
+
+	// rotated files are deleted when there are too many or when they are
+	// older than this
+	MaxFiles     int
+	MaxRetention time.Duration
+
+	mutex sync.Mutex
+	file  *os.File
+
+	// bytes and time since the last rotation, or since the last attempt
+	// if it failed
+	size   int64
+	opened time.Time
+}
+
+func OpenRotator(filename string) (*Rotator, error) {
+	rotator := &Rotator{
+		filename:     filename,
+		MaxSize:      1024 * 1024, // 1 MiB
+		MaxAge:       24 * time.Hour,
+		MaxFiles:     5,
+		MaxRetention: 30 * 24 * time.Hour,
+	}
+
+	err := os.MkdirAll(filepath.Dir(filename), os.ModeDir|0700)
+	if err != nil {
+		return nil, err
+	}
+
+	rotator.mutex.Lock()
+	defer rotator.mutex.Unlock()
+
+	// continue the file of the last start, unless it is due anyway
+	info, err := os.Stat(filename)
+	if err == nil && (info.Size() >= rotator.MaxSize || time.Since(info.ModTime()) >= rotator.MaxAge) {
+		err = rotator.rotate()
+	} else {
+		err = rotator.open()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return rotator, nil
+}
+
+func (rotator *Rotator) Write(p []byte) (int, error) {
+	rotator.mutex.Lock()
+	defer rotator.mutex.Unlock()
+
+	if rotator.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	// records bigger than MaxSize still go into the current file when it is empty
+	full := rotator.size > 0 && rotator.size+int64(len(p)) > rotator.MaxSize
+	if full || time.Since(rotator.opened) >= rotator.MaxAge {
+		err := rotator.rotate()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := rotator.file.Write(p)
+	rotator.size += int64(n)
+
+	return n, err
+}
+
+func (rotator *Rotator) Close() error {
+	rotator.mutex.Lock()
+	defer rotator.mutex.Unlock()
+
+	if rotator.file == nil {
+		return nil
+	}
+
+	err := rotator.file.Close()
+	rotator.file = nil
+
+	return err
+}
+
+func (rotator *Rotator) open() error {
+	file, err := os.OpenFile(rotator.filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	rotator.file = file
+	rotator.size = info.Size()
+	rotator.opened = time.Now()
+
+	return nil
+}
+
+// backupName returns the name of the nth rotated file.
+func (rotator *Rotator) backupName(n int) string {
+	ext := filepath.Ext(rotator.filename)
+	return fmt.Sprintf("%s.%d%s", strings.TrimSuffix(rotator.filename, ext), n, ext)
+}
+
+func (rotator *Rotator) rotate() error {
+	if rotator.file != nil {
+		rotator.file.Close()
+		rotator.file = nil
+	}
+
+	// The file is moved out of the way first, the backups are only shifted
+	// once that worked. If it can't be renamed, logging continues in it and
+	// rotating is tried again at the next size or age limit.
+	rotating := rotator.filename + ".rotating"
+	err := os.Rename(rotator.filename, rotating)
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "failed to rotate %s: %v\n", rotator.filename, err)
+
+		err = rotator.open()
+		rotator.size = 0
+		return err
+	}
+
+	if err == nil {
+		os.Remove(rotator.backupName(rotator.MaxFiles))
+		for n := rotator.MaxFiles - 1; n >= 1; n-- {
+			os.Rename(rotator.backupName(n), rotator.backupName(n+1))
+		}
+
+		err = os.Rename(rotating, rotator.backupName(1))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to rotate %s: %v\n", rotator.filename, err)
+		}
+	}
+
+	for n := 1; n <= rotator.MaxFiles; n++ {
+		info, err := os.Stat(rotator.backupName(n))
+		if err == nil && time.Since(info.ModTime()) > rotator.MaxRetention {
+			os.Remove(rotator.backupName(n))
+		}
+	}
+
+	return rotator.open()
+}
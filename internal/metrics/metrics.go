@@ -0,0 +1,86 @@
+package metrics
+
+// Default is the registry served on the metrics port.
+var Default = NewRegistry()
+
+// LatencyBuckets are the histogram bounds in seconds for GrooveStats
+// requests.
+var LatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// DownloadBuckets are the histogram bounds in seconds for unlock downloads.
+var DownloadBuckets = []float64{1, 5, 15, 30, 60, 120, 300, 600}
+
+var (
+	// GsRequests counts the answers to the theme by endpoint (e.g.
+	// "player-scores") and outcome: success, fail, disabled or cached.
+	GsRequests = Default.NewCounterVec(
+		"gslauncher_groovestats_requests",
+		"GrooveStats requests by endpoint and outcome.",
+		"endpoint", "outcome",
+	)
+
+	// GsDeduplicated counts requests that shared the response of an
+	// identical request in flight.
+	GsDeduplicated = Default.NewCounterVec(
+		"gslauncher_groovestats_deduplicated_requests",
+		"GrooveStats requests answered by an identical request in flight.",
+		"endpoint",
+	)
+
+	// GsRequestDuration observes every HTTP request sent to GrooveStats,
+	// including retries.
+	GsRequestDuration = Default.NewHistogramVec(
+		"gslauncher_groovestats_request_duration_seconds",
+		"Duration of HTTP requests to GrooveStats.",
+		LatencyBuckets,
+		"endpoint",
+	)
+
+	// IpcRequests counts the requests of the theme by action.
+	IpcRequests = Default.NewCounterVec(
+		"gslauncher_ipc_requests",
+		"Requests received from the theme by action.",
+		"action",
+	)
+
+	// IpcCoalesced counts the requests of the theme answered with the
+	// response of an identical newer request.
+	IpcCoalesced = Default.NewCounterVec(
+		"gslauncher_ipc_coalesced",
+		"Requests from the theme answered together with an identical newer one.",
+		"action",
+	)
+
+	// IpcQueueDepth is the number of theme requests waiting to be handled.
+	IpcQueueDepth = Default.NewGauge(
+		"gslauncher_ipc_queue_depth",
+		"Requests from the theme waiting to be handled.",
+	)
+
+	// ScoresSubmitted counts the scores accepted by GrooveStats, one per
+	// player.
+	ScoresSubmitted = Default.NewCounter(
+		"gslauncher_scores_submitted",
+		"Scores accepted by GrooveStats.",
+	)
+
+	// UnlocksObtained counts the unlocks earned by the players.
+	UnlocksObtained = Default.NewCounter(
+		"gslauncher_unlocks_obtained",
+		"Unlocks earned by completing quests.",
+	)
+
+	DownloadBytes = Default.NewCounter(
+		"gslauncher_download_bytes",
+		"Bytes downloaded for unlocks.",
+	)
+
+	// DownloadDuration observes unlock downloads by outcome: success,
+	// fail or cancelled.
+	DownloadDuration = Default.NewHistogramVec(
+		"gslauncher_download_duration_seconds",
+		"Duration of unlock downloads by outcome.",
+		DownloadBuckets,
+		"outcome",
+	)
+)
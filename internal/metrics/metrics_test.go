@@ -0,0 +1,108 @@
+package metrics
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	registry := NewRegistry()
+
+	requests := registry.NewCounterVec("test_requests", "Requests by endpoint.", "endpoint", "outcome")
+	requests.With("player-scores", "success").Add(2)
+	requests.With("new-session", "fail").Inc()
+
+	depth := registry.NewGauge("test_queue_depth", "Queued requests.")
+	depth.Inc()
+	depth.Inc()
+	depth.Dec()
+
+	latency := registry.NewHistogramVec("test_duration_seconds", "Request duration.", []float64{0.1, 1}, "endpoint")
+	latency.With(`a"b`).Observe(0.05)
+	latency.With(`a"b`).Observe(0.5)
+	latency.With(`a"b`).Observe(3)
+
+	var buf bytes.Buffer
+	err := registry.Write(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# TYPE test_requests counter
+# HELP test_requests Requests by endpoint.
+test_requests_total{endpoint="new-session",outcome="fail"} 1
+test_requests_total{endpoint="player-scores",outcome="success"} 2
+# TYPE test_queue_depth gauge
+# HELP test_queue_depth Queued requests.
+test_queue_depth 1
+# TYPE test_duration_seconds histogram
+# HELP test_duration_seconds Request duration.
+test_duration_seconds_bucket{endpoint="a\"b",le="0.1"} 1
+test_duration_seconds_bucket{endpoint="a\"b",le="1"} 2
+test_duration_seconds_bucket{endpoint="a\"b",le="+Inf"} 3
+test_duration_seconds_sum{endpoint="a\"b"} 3.55
+test_duration_seconds_count{endpoint="a\"b"} 3
+# EOF
+`
+	if buf.String() != expected {
+		t.Fatalf("unexpected output:\n%s", buf.String())
+	}
+}
+
+func TestConcurrentUpdates(t *testing.T) {
+	registry := NewRegistry()
+	requests := registry.NewCounterVec("test_requests", "Requests.", "outcome")
+	latency := registry.NewHistogram("test_duration_seconds", "Duration.", []float64{1})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 1000; j++ {
+				requests.With("success").Inc()
+				latency.Observe(0.5)
+			}
+			registry.Write(io.Discard)
+		}()
+	}
+	wg.Wait()
+
+	if n := requests.With("success").Value(); n != 8000 {
+		t.Fatalf("expected 8000 requests, got %d", n)
+	}
+	if latency.Count() != 8000 || latency.Sum() != 4000 {
+		t.Fatalf("unexpected histogram count %d sum %f", latency.Count(), latency.Sum())
+	}
+}
+
+func TestHandler(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewCounter("test_downloads", "Downloads.").Inc()
+
+	server := httptest.NewServer(Handler(registry))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.Header.Get("Content-Type") != contentType {
+		t.Fatalf("unexpected content type %q", resp.Header.Get("Content-Type"))
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(body, []byte("test_downloads_total 1\n")) {
+		t.Fatalf("counter missing:\n%s", body)
+	}
+}
@@ -0,0 +1,323 @@
+// Package metrics collects counters, gauges and histograms about the
+// launcher and exposes them in the OpenMetrics text format. All metrics are
+// safe for concurrent use.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+type metricType string
+
+const (
+	counterType   metricType = "counter"
+	gaugeType     metricType = "gauge"
+	histogramType metricType = "histogram"
+)
+
+// Registry holds metric families in the order they were registered.
+type Registry struct {
+	mutex    sync.Mutex
+	families []*family
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// family is a metric with all its label combinations.
+type family struct {
+	name       string
+	help       string
+	kind       metricType
+	labelNames []string
+	buckets    []float64
+
+	mutex  sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	metric      interface{}
+}
+
+func (registry *Registry) register(name, help string, kind metricType, buckets []float64, labelNames []string) *family {
+	f := &family{
+		name:       name,
+		help:       help,
+		kind:       kind,
+		labelNames: labelNames,
+		buckets:    buckets,
+		series:     make(map[string]*series),
+	}
+
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	for _, other := range registry.families {
+		if other.name == name {
+			panic("metric registered twice: " + name)
+		}
+	}
+	registry.families = append(registry.families, f)
+
+	return f
+}
+
+// with returns the metric for the label values, creating it if necessary.
+func (f *family) with(labelValues []string) interface{} {
+	if len(labelValues) != len(f.labelNames) {
+		panic(fmt.Sprintf("%s: expected %d label values, got %d", f.name, len(f.labelNames), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+
+		switch f.kind {
+		case counterType:
+			s.metric = &Counter{}
+		case gaugeType:
+			s.metric = &Gauge{}
+		case histogramType:
+			s.metric = newHistogram(f.buckets)
+		}
+
+		f.series[key] = s
+	}
+
+	return s.metric
+}
+
+// sorted returns the series ordered by their label values.
+func (f *family) sorted() []*series {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	list := make([]*series, 0, len(f.series))
+	for _, s := range f.series {
+		list = append(list, s)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i].labelValues, list[j].labelValues
+		for k := range a {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return false
+	})
+
+	return list
+}
+
+// Counter is a value that only goes up.
+type Counter struct {
+	value uint64
+}
+
+func (c *Counter) Inc() {
+	atomic.AddUint64(&c.value, 1)
+}
+
+func (c *Counter) Add(n uint64) {
+	atomic.AddUint64(&c.value, n)
+}
+
+func (c *Counter) Value() uint64 {
+	return atomic.LoadUint64(&c.value)
+}
+
+// Gauge is a value that goes up and down.
+type Gauge struct {
+	value int64
+}
+
+func (g *Gauge) Inc() {
+	atomic.AddInt64(&g.value, 1)
+}
+
+func (g *Gauge) Dec() {
+	atomic.AddInt64(&g.value, -1)
+}
+
+func (g *Gauge) Set(n int64) {
+	atomic.StoreInt64(&g.value, n)
+}
+
+func (g *Gauge) Value() int64 {
+	return atomic.LoadInt64(&g.value)
+}
+
+// Histogram counts observations in buckets.
+type Histogram struct {
+	// upper bounds, ascending
+	buckets []float64
+
+	// observations per bucket, not cumulative, the last one is +Inf
+	counts  []uint64
+	count   uint64
+	sumBits uint64
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	return &Histogram{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)+1),
+	}
+}
+
+func (h *Histogram) Observe(value float64) {
+	i := sort.SearchFloat64s(h.buckets, value)
+	atomic.AddUint64(&h.counts[i], 1)
+	atomic.AddUint64(&h.count, 1)
+
+	for {
+		old := atomic.LoadUint64(&h.sumBits)
+		sum := math.Float64frombits(old) + value
+		if atomic.CompareAndSwapUint64(&h.sumBits, old, math.Float64bits(sum)) {
+			return
+		}
+	}
+}
+
+func (h *Histogram) Count() uint64 {
+	return atomic.LoadUint64(&h.count)
+}
+
+func (h *Histogram) Sum() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&h.sumBits))
+}
+
+// CounterVec is a counter partitioned by labels.
+type CounterVec struct {
+	family *family
+}
+
+func (vec *CounterVec) With(labelValues ...string) *Counter {
+	return vec.family.with(labelValues).(*Counter)
+}
+
+// Each calls fn for every label combination, ordered by the label values.
+func (vec *CounterVec) Each(fn func(labelValues []string, value uint64)) {
+	for _, s := range vec.family.sorted() {
+		fn(s.labelValues, s.metric.(*Counter).Value())
+	}
+}
+
+// HistogramVec is a histogram partitioned by labels.
+type HistogramVec struct {
+	family *family
+}
+
+func (vec *HistogramVec) With(labelValues ...string) *Histogram {
+	return vec.family.with(labelValues).(*Histogram)
+}
+
+func (registry *Registry) NewCounter(name, help string) *Counter {
+	return registry.register(name, help, counterType, nil, nil).with(nil).(*Counter)
+}
+
+func (registry *Registry) NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{registry.register(name, help, counterType, nil, labelNames)}
+}
+
+func (registry *Registry) NewGauge(name, help string) *Gauge {
+	return registry.register(name, help, gaugeType, nil, nil).with(nil).(*Gauge)
+}
+
+func (registry *Registry) NewHistogram(name, help string, buckets []float64) *Histogram {
+	return registry.register(name, help, histogramType, buckets, nil).with(nil).(*Histogram)
+}
+
+func (registry *Registry) NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	return &HistogramVec{registry.register(name, help, histogramType, buckets, labelNames)}
+}
+
+// Write writes all metrics in the OpenMetrics text format.
+func (registry *Registry) Write(w io.Writer) error {
+	registry.mutex.Lock()
+	families := append([]*family(nil), registry.families...)
+	registry.mutex.Unlock()
+
+	bw := bufio.NewWriter(w)
+
+	for _, f := range families {
+		fmt.Fprintf(bw, "# TYPE %s %s\n", f.name, f.kind)
+		fmt.Fprintf(bw, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+
+		for _, s := range f.sorted() {
+			labels := formatLabels(f.labelNames, s.labelValues)
+
+			switch metric := s.metric.(type) {
+			case *Counter:
+				fmt.Fprintf(bw, "%s_total%s %d\n", f.name, labels(), metric.Value())
+			case *Gauge:
+				fmt.Fprintf(bw, "%s%s %d\n", f.name, labels(), metric.Value())
+			case *Histogram:
+				var cumulative uint64
+				for i, bound := range metric.buckets {
+					cumulative += atomic.LoadUint64(&metric.counts[i])
+					fmt.Fprintf(bw, "%s_bucket%s %d\n", f.name, labels("le", formatFloat(bound)), cumulative)
+				}
+				cumulative += atomic.LoadUint64(&metric.counts[len(metric.buckets)])
+				fmt.Fprintf(bw, "%s_bucket%s %d\n", f.name, labels("le", "+Inf"), cumulative)
+				fmt.Fprintf(bw, "%s_sum%s %s\n", f.name, labels(), formatFloat(metric.Sum()))
+				fmt.Fprintf(bw, "%s_count%s %d\n", f.name, labels(), cumulative)
+			}
+		}
+	}
+
+	fmt.Fprint(bw, "# EOF\n")
+
+	return bw.Flush()
+}
+
+// formatLabels returns a function that formats the labels of a series, with
+// an optional extra label like the bucket bound of a histogram.
+func formatLabels(names, values []string) func(extra ...string) string {
+	return func(extra ...string) string {
+		pairs := make([]string, 0, len(names)+1)
+		for i, name := range names {
+			pairs = append(pairs, name+`="`+escapeLabel(values[i])+`"`)
+		}
+		if len(extra) == 2 {
+			pairs = append(pairs, extra[0]+`="`+escapeLabel(extra[1])+`"`)
+		}
+
+		if len(pairs) == 0 {
+			return ""
+		}
+		return "{" + strings.Join(pairs, ",") + "}"
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/GrooveStats/gslauncher/internal/logging"
+)
+
+const contentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// Handler serves the metrics of the registry.
+func Handler(registry *Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		registry.Write(w)
+	})
+}
+
+// Serve serves the default registry at /metrics on the loopback interface.
+// It returns once the port is open and keeps serving in the background.
+func Serve(port int) (net.Addr, error) {
+	listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler(Default))
+
+	logger := logging.New("Metrics")
+	logger.Info("serving metrics", "url", fmt.Sprintf("http://%s/metrics", listener.Addr()))
+
+	go func() {
+		err := http.Serve(listener, mux)
+		logger.Error("metrics endpoint stopped", "err", err)
+	}()
+
+	return listener.Addr(), nil
+}
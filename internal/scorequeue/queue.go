@@ -0,0 +1,364 @@
+package scorequeue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GrooveStats/gslauncher/internal/fsipc"
+	"github.com/GrooveStats/gslauncher/internal/groovestats"
+	"github.com/GrooveStats/gslauncher/internal/logging"
+)
+
+type Status int
+
+const (
+	Queued Status = iota
+	Submitting
+	Failed
+)
+
+const (
+	minBackoff = 30 * time.Second
+	maxBackoff = 30 * time.Minute
+)
+
+type Entry struct {
+	Id          string                      `json:"-"`
+	Request     *fsipc.GsScoreSubmitRequest `json:"request"`
+	Added       time.Time                   `json:"added"`
+	Attempts    int                         `json:"attempts"`
+	NextAttempt time.Time                   `json:"-"`
+	Status      Status                      `json:"status"`
+	Error       string                      `json:"error"`
+}
+
+// SubmitFunc submits a queued score. Network errors and disabled endpoints
+// keep the entry queued, every other error marks the entry as failed.
+type SubmitFunc func(request *fsipc.GsScoreSubmitRequest) error
+
+type Queue struct {
+	Dir string
+
+	mutex          sync.Mutex
+	entries        []*Entry
+	logger         *slog.Logger
+	updateCallback func()
+}
+
+func NewQueue(cacheDir string) (*Queue, error) {
+	dir := filepath.Join(cacheDir, "groovestats-launcher", "score-queue")
+
+	err := os.MkdirAll(dir, os.ModeDir|0700)
+	if err != nil {
+		return nil, err
+	}
+
+	queue := Queue{
+		Dir:            dir,
+		entries:        make([]*Entry, 0),
+		logger:         logging.New("Queue"),
+		updateCallback: func() {},
+	}
+
+	err = queue.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return &queue, nil
+}
+
+func (queue *Queue) load() error {
+	dirEntries, err := os.ReadDir(queue.Dir)
+	if err != nil {
+		return err
+	}
+
+	for _, dirEntry := range dirEntries {
+		name := dirEntry.Name()
+		if !strings.HasSuffix(name, ".json") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(queue.Dir, name))
+		if err != nil {
+			queue.logger.Warn("failed to read entry", "file", name, "err", err)
+			continue
+		}
+
+		entry := &Entry{}
+		err = json.Unmarshal(data, entry)
+		if err != nil || entry.Request == nil {
+			// kept aside, the score might still be recovered by hand
+			queue.logger.Warn("skipping corrupt entry", "file", name)
+			filename := filepath.Join(queue.Dir, name)
+			os.Rename(filename, filename+".corrupt")
+			continue
+		}
+
+		entry.Id = strings.TrimSuffix(name, ".json")
+		if entry.Status == Submitting {
+			entry.Status = Queued
+		}
+
+		queue.entries = append(queue.entries, entry)
+	}
+
+	sort.Slice(queue.entries, func(i, j int) bool {
+		return queue.entries[i].Added.Before(queue.entries[j].Added)
+	})
+
+	return nil
+}
+
+func (queue *Queue) SetUpdateCallback(callback func()) {
+	queue.updateCallback = callback
+}
+
+// Entries returns a snapshot of all queued and failed submissions.
+func (queue *Queue) Entries() []Entry {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	entries := make([]Entry, 0, len(queue.entries))
+	for _, entry := range queue.entries {
+		entries = append(entries, *entry)
+	}
+
+	return entries
+}
+
+func (queue *Queue) Add(request *fsipc.GsScoreSubmitRequest) error {
+	id, err := newId()
+	if err != nil {
+		return err
+	}
+
+	entry := &Entry{
+		Id:      id,
+		Request: request,
+		Added:   time.Now(),
+		Status:  Queued,
+	}
+
+	err = queue.save(entry)
+	if err != nil {
+		return err
+	}
+
+	queue.mutex.Lock()
+	queue.entries = append(queue.entries, entry)
+	queue.mutex.Unlock()
+
+	queue.logger.Info("queued score submission", "id", id)
+	queue.updateCallback()
+
+	return nil
+}
+
+// Flush submits all entries that are due. It returns the time until the next
+// entry is due, or -1 if there is nothing left to retry.
+func (queue *Queue) Flush(submit SubmitFunc) time.Duration {
+	for {
+		entry := queue.nextDue()
+		if entry == nil {
+			break
+		}
+
+		err := submit(entry.Request)
+		queue.finish(entry, err)
+	}
+
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	var next time.Duration = -1
+	now := time.Now()
+
+	for _, entry := range queue.entries {
+		if entry.Status != Queued {
+			continue
+		}
+
+		wait := entry.NextAttempt.Sub(now)
+		if wait < 0 {
+			wait = 0
+		}
+		if next == -1 || wait < next {
+			next = wait
+		}
+	}
+
+	return next
+}
+
+func (queue *Queue) nextDue() *Entry {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+
+	now := time.Now()
+
+	for _, entry := range queue.entries {
+		if entry.Status == Queued && !entry.NextAttempt.After(now) {
+			entry.Status = Submitting
+			entry.Attempts++
+			go queue.updateCallback()
+			return entry
+		}
+	}
+
+	return nil
+}
+
+func (queue *Queue) finish(entry *Entry, err error) {
+	if err == nil {
+		queue.logger.Info("submitted queued score", "id", entry.Id)
+		queue.remove(entry)
+		return
+	}
+
+	var networkError *groovestats.NetworkError
+	var disabledError *groovestats.DisabledError
+
+	queue.mutex.Lock()
+
+	entry.Error = err.Error()
+	// submissions cancelled because StepMania exited are retried next time
+	if errors.As(err, &networkError) || errors.As(err, &disabledError) || errors.Is(err, context.Canceled) {
+		backoff := retryDelay(entry.Attempts)
+		entry.Status = Queued
+		entry.NextAttempt = time.Now().Add(backoff)
+		queue.logger.Warn("retrying score submission", "id", entry.Id, "delay", backoff, "err", err)
+	} else {
+		entry.Status = Failed
+		queue.logger.Error("score submission failed", "id", entry.Id, "err", err)
+	}
+
+	queue.mutex.Unlock()
+
+	err = queue.save(entry)
+	if err != nil {
+		queue.logger.Error("failed to save entry", "id", entry.Id, "err", err)
+	}
+
+	queue.updateCallback()
+}
+
+// Retry requeues a failed entry. It is submitted on the next flush.
+func (queue *Queue) Retry(id string) {
+	queue.mutex.Lock()
+
+	var entry *Entry
+	for _, e := range queue.entries {
+		if e.Id == id && e.Status == Failed {
+			entry = e
+			entry.Status = Queued
+			entry.NextAttempt = time.Time{}
+		}
+	}
+
+	queue.mutex.Unlock()
+
+	if entry == nil {
+		return
+	}
+
+	err := queue.save(entry)
+	if err != nil {
+		queue.logger.Error("failed to save entry", "id", id, "err", err)
+	}
+
+	queue.updateCallback()
+}
+
+// Discard drops an entry that isn't currently being submitted.
+func (queue *Queue) Discard(id string) {
+	queue.mutex.Lock()
+
+	var entry *Entry
+	for _, e := range queue.entries {
+		if e.Id == id && e.Status != Submitting {
+			entry = e
+		}
+	}
+
+	queue.mutex.Unlock()
+
+	if entry != nil {
+		queue.logger.Info("discarding entry", "id", id)
+		queue.remove(entry)
+	}
+}
+
+func (queue *Queue) remove(entry *Entry) {
+	queue.mutex.Lock()
+	for i, e := range queue.entries {
+		if e == entry {
+			queue.entries = append(queue.entries[:i], queue.entries[i+1:]...)
+			break
+		}
+	}
+	queue.mutex.Unlock()
+
+	err := os.Remove(filepath.Join(queue.Dir, entry.Id+".json"))
+	if err != nil {
+		queue.logger.Warn("failed to delete entry", "id", entry.Id, "err", err)
+	}
+
+	queue.updateCallback()
+}
+
+func (queue *Queue) save(entry *Entry) error {
+	queue.mutex.Lock()
+	data, err := json.Marshal(entry)
+	queue.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	filename := filepath.Join(queue.Dir, entry.Id+".json")
+	tmpfile := filename + ".new"
+
+	err = os.WriteFile(tmpfile, data, 0600)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpfile, filename)
+}
+
+// retryDelay returns the delay after the given number of failed attempts. It
+// doubles with every attempt, up to maxBackoff.
+func retryDelay(attempts int) time.Duration {
+	if attempts < 1 {
+		return minBackoff
+	}
+
+	delay := minBackoff << (attempts - 1)
+	if delay > maxBackoff || delay <= 0 || attempts > 32 {
+		delay = maxBackoff
+	}
+
+	return delay
+}
+
+func newId() (string, error) {
+	b := make([]byte, 8)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%d-%x", time.Now().Unix(), b), nil
+}
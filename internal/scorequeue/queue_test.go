@@ -0,0 +1,201 @@
+package scorequeue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GrooveStats/gslauncher/internal/fsipc"
+	"github.com/GrooveStats/gslauncher/internal/groovestats"
+)
+
+func newTestRequest(t *testing.T) *fsipc.GsScoreSubmitRequest {
+	req := &fsipc.GsScoreSubmitRequest{}
+
+	err := json.Unmarshal([]byte(`{"player1": {"apiKey": "key", "profileName": "domp", "chartHash": "hash", "score": 9876, "rate": 100}}`), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return req
+}
+
+func TestLoad(t *testing.T) {
+	cacheDir := t.TempDir()
+
+	queue, err := NewQueue(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = queue.Add(newTestRequest(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queue.Flush(func(request *fsipc.GsScoreSubmitRequest) error {
+		return &groovestats.DisabledError{}
+	})
+
+	err = queue.Add(newTestRequest(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queue, err = NewQueue(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := queue.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	for _, entry := range entries {
+		if entry.Status != Queued || !entry.NextAttempt.IsZero() {
+			t.Errorf("entry %s not due: %v %s", entry.Id, entry.Status, entry.NextAttempt)
+		}
+		if entry.Request.Player1 == nil || entry.Request.Player1.Score != 9876 {
+			t.Errorf("request of entry %s not restored", entry.Id)
+		}
+	}
+	if entries[0].Attempts != 1 || entries[1].Attempts != 0 {
+		t.Errorf("attempts not restored: %d %d", entries[0].Attempts, entries[1].Attempts)
+	}
+}
+
+func TestCorruptEntry(t *testing.T) {
+	cacheDir := t.TempDir()
+	dir := filepath.Join(cacheDir, "groovestats-launcher", "score-queue")
+
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range map[string]string{
+		"1-truncated.json": `{"request": {"player1"`,
+		"2-empty.json":     `{"added": "2022-01-01T00:00:00Z"}`,
+	} {
+		err = os.WriteFile(filepath.Join(dir, name), []byte(data), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	queue, err := NewQueue(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if entries := queue.Entries(); len(entries) != 0 {
+		t.Errorf("corrupt entries loaded: %+v", entries)
+	}
+
+	for _, name := range []string{"1-truncated.json", "2-empty.json"} {
+		_, err = os.Stat(filepath.Join(dir, name+".corrupt"))
+		if err != nil {
+			t.Errorf("%s not kept aside: %v", name, err)
+		}
+	}
+
+	// and they stay skipped
+	queue, err = NewQueue(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries := queue.Entries(); len(entries) != 0 {
+		t.Errorf("corrupt entries loaded: %+v", entries)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{1, minBackoff},
+		{2, 2 * minBackoff},
+		{3, 4 * minBackoff},
+		{6, 32 * minBackoff},
+		{7, maxBackoff},
+		{64, maxBackoff},
+		{1000, maxBackoff},
+	}
+
+	for _, test := range tests {
+		actual := retryDelay(test.attempts)
+		if actual != test.expected {
+			t.Errorf("retryDelay(%d) = %s, expected %s", test.attempts, actual, test.expected)
+		}
+	}
+}
+
+func TestFlush(t *testing.T) {
+	tests := []struct {
+		err    error
+		status Status
+	}{
+		{&groovestats.DisabledError{}, Queued},
+		{fmt.Errorf("submit: %w", context.Canceled), Queued},
+		{errors.New("invalid api key"), Failed},
+	}
+
+	for _, test := range tests {
+		queue, err := NewQueue(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = queue.Add(newTestRequest(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		submits := 0
+		wait := queue.Flush(func(request *fsipc.GsScoreSubmitRequest) error {
+			submits++
+			return test.err
+		})
+
+		if submits != 1 {
+			t.Errorf("%v: submitted %d times", test.err, submits)
+		}
+
+		entries := queue.Entries()
+		if len(entries) != 1 || entries[0].Status != test.status {
+			t.Fatalf("%v: unexpected entries %+v", test.err, entries)
+		}
+
+		if test.status == Queued {
+			if wait < minBackoff-time.Second || wait > minBackoff {
+				t.Errorf("%v: retried in %s", test.err, wait)
+			}
+		} else if wait != -1 {
+			t.Errorf("%v: failed entry is retried in %s", test.err, wait)
+		}
+	}
+
+	queue, err := NewQueue(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = queue.Add(newTestRequest(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wait := queue.Flush(func(request *fsipc.GsScoreSubmitRequest) error { return nil })
+	if wait != -1 || len(queue.Entries()) != 0 {
+		t.Errorf("submitted entry not removed")
+	}
+	if dirEntries, _ := os.ReadDir(queue.Dir); len(dirEntries) != 0 {
+		t.Errorf("submitted entry not deleted")
+	}
+}
@@ -0,0 +1,190 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/GrooveStats/gslauncher/internal/fsipc"
+	"github.com/GrooveStats/gslauncher/internal/groovestats"
+	"github.com/GrooveStats/gslauncher/internal/version"
+)
+
+func (sess *Session) registerActions(registry *fsipc.Registry) error {
+	actions := []fsipc.Action{
+		{
+			Name: "ping",
+			NewRequest: func(id string) interface{} {
+				return &fsipc.PingRequest{Id: id}
+			},
+			Policy:  fsipc.PolicyShared,
+			Handler: sess.handlePing,
+		},
+		{
+			Name: "groovestats/new-session",
+			NewRequest: func(id string) interface{} {
+				return &fsipc.GsNewSessionRequest{Id: id}
+			},
+			Policy:  fsipc.PolicyShared,
+			Handler: sess.handleNewSession,
+		},
+		{
+			Name: "groovestats/player-scores",
+			NewRequest: func(id string) interface{} {
+				return &fsipc.GsPlayerScoresRequest{Id: id}
+			},
+			Policy:  fsipc.PolicyLatestOnly,
+			Handler: sess.handlePlayerScores,
+		},
+		{
+			Name: "groovestats/player-leaderboards",
+			NewRequest: func(id string) interface{} {
+				return &fsipc.GsPlayerLeaderboardsRequest{Id: id}
+			},
+			Policy:  fsipc.PolicyLatestOnly,
+			Handler: sess.handlePlayerLeaderboards,
+		},
+		{
+			Name: "groovestats/score-submit",
+			NewRequest: func(id string) interface{} {
+				return &fsipc.GsScoreSubmitRequest{Id: id}
+			},
+			Policy:  fsipc.PolicyShared,
+			Handler: sess.handleScoreSubmit,
+		},
+		{
+			Name: "launcher/unlocks/list",
+			NewRequest: func(id string) interface{} {
+				return &fsipc.LauncherUnlocksListRequest{Id: id}
+			},
+			Policy:  fsipc.PolicyShared,
+			Handler: sess.handleUnlocksList,
+		},
+		{
+			Name: "launcher/unlocks/download",
+			NewRequest: func(id string) interface{} {
+				return &fsipc.LauncherUnlocksDownloadRequest{Id: id}
+			},
+			Policy:  fsipc.PolicyShared,
+			Handler: sess.handleUnlocksDownload,
+		},
+		{
+			Name: "launcher/unlocks/unpack",
+			NewRequest: func(id string) interface{} {
+				return &fsipc.LauncherUnlocksUnpackRequest{Id: id}
+			},
+			Policy:  fsipc.PolicyShared,
+			Handler: sess.handleUnlocksUnpack,
+		},
+	}
+
+	for _, action := range actions {
+		err := registry.Register(action)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (sess *Session) handlePing(request interface{}) interface{} {
+	req := request.(*fsipc.PingRequest)
+
+	protocol, ok := negotiateProtocol(req)
+	if !ok {
+		return fsipc.NewInvalidResponse(&fsipc.RequestError{
+			Code:        fsipc.ErrorUnsupportedProtocol,
+			Message:     fmt.Sprintf("protocol %d is not supported", req.Protocol),
+			Field:       "protocol",
+			Protocol:    version.Protocol,
+			MinProtocol: version.MinProtocol,
+		})
+	}
+
+	return fsipc.PingResponse{
+		Version: fsipc.PingVersion{
+			Major: version.Major,
+			Minor: version.Minor,
+			Patch: version.Patch,
+		},
+		Protocol:        protocol,
+		LauncherVersion: version.Formatted(),
+		Actions:         sess.registry.Names(),
+		Features:        sess.features(),
+	}
+}
+
+func (sess *Session) handleNewSession(request interface{}) interface{} {
+	req := request.(*fsipc.GsNewSessionRequest)
+
+	resp, err := sess.gsClient.NewSession(sess.ctx, req)
+
+	if err == nil {
+		sess.setNewSessionResponse(resp)
+	} else {
+		sess.updateStatus()
+	}
+
+	if err == nil && resp.ServicesAllowed.ScoreSubmit {
+		select {
+		case sess.flushQueue <- struct{}{}:
+		default:
+		}
+	}
+
+	return newNetworkResponse(resp, err)
+}
+
+func (sess *Session) handlePlayerScores(request interface{}) interface{} {
+	resp, err := sess.gsClient.PlayerScores(sess.ctx, request.(*fsipc.GsPlayerScoresRequest))
+	return newNetworkResponse(resp, err)
+}
+
+func (sess *Session) handlePlayerLeaderboards(request interface{}) interface{} {
+	resp, err := sess.gsClient.PlayerLeaderboards(sess.ctx, request.(*fsipc.GsPlayerLeaderboardsRequest))
+	return newNetworkResponse(resp, err)
+}
+
+func (sess *Session) handleScoreSubmit(request interface{}) interface{} {
+	req := request.(*fsipc.GsScoreSubmitRequest)
+
+	// Don't keep the theme waiting for a request that is bound to fail.
+	// The score queue notices when GrooveStats is back.
+	if !sess.gsClient.OfflineSince().IsZero() {
+		return sess.queueScore(req)
+	}
+
+	resp, err := sess.gsClient.ScoreSubmit(sess.ctx, req)
+
+	// Keep the score for later if GrooveStats can't be reached or
+	// StepMania exited while it was submitted.
+	var networkError *groovestats.NetworkError
+	if errors.As(err, &networkError) || errors.Is(err, context.Canceled) {
+		return sess.queueScore(req)
+	}
+
+	if err == nil {
+		sess.handleScoreSubmitResponse(req, resp, false)
+	}
+
+	// new unlocks or a protocol violation
+	sess.updateStatus()
+
+	return newNetworkResponse(resp, err)
+}
+
+func (sess *Session) queueScore(req *fsipc.GsScoreSubmitRequest) interface{} {
+	response := &fsipc.NetworkResponse{Status: "fail"}
+
+	err := sess.scoreQueue.Add(req)
+	if err != nil {
+		sess.logger.Error("failed to queue score submission", "err", err)
+	} else {
+		response.Queued = true
+	}
+
+	sess.updateStatus()
+
+	return response
+}
@@ -0,0 +1,263 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GrooveStats/gslauncher/internal/events"
+	"github.com/GrooveStats/gslauncher/internal/fsipc"
+	"github.com/GrooveStats/gslauncher/internal/groovestats"
+	"github.com/GrooveStats/gslauncher/internal/history"
+	"github.com/GrooveStats/gslauncher/internal/logging"
+	"github.com/GrooveStats/gslauncher/internal/metrics"
+	"github.com/GrooveStats/gslauncher/internal/settings"
+	"github.com/GrooveStats/gslauncher/internal/unlocks"
+	"github.com/GrooveStats/gslauncher/internal/usage"
+)
+
+// newTestSession returns a session with the real actions that talks to the
+// given GrooveStats server. Requests are sent through the filesystem
+// transport in the returned directory.
+func newTestSession(t *testing.T, handler http.HandlerFunc) (*Session, string) {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	oldSettings := settings.Get()
+	t.Cleanup(func() { settings.Update(oldSettings) })
+
+	newSettings := settings.Get()
+	newSettings.GrooveStatsUrl = server.URL
+	newSettings.IpcWatchMode = settings.WatchPoll
+	settings.Update(newSettings)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	gsClient, err := groovestats.NewClient(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sess := &Session{
+		events:        events.NewTracker(),
+		logger:        logging.New("Session"),
+		gsClient:      gsClient,
+		statusChanged: make(chan struct{}, 1),
+		ctx:           ctx,
+		cancel:        cancel,
+	}
+	t.Cleanup(sess.gsClient.Close)
+
+	sess.registry = fsipc.NewRegistry()
+	err = sess.registerActions(sess.registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	sess.ipc, err = fsipc.New(dir, sess.registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sess.ipc.Close() })
+
+	return sess, dir
+}
+
+func sendRequest(t *testing.T, dir, id string, data string) {
+	action := "groovestats/player-scores"
+	received := metrics.IpcRequests.With(action).Value()
+
+	filename := filepath.Join(dir, "requests", id+".json")
+	err := os.WriteFile(filename+".new", []byte(data), 0600)
+	if err == nil {
+		err = os.Rename(filename+".new", filename)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// wait until it is queued, so the requests arrive in order
+	deadline := time.Now().Add(10 * time.Second)
+	for metrics.IpcRequests.With(action).Value() == received {
+		if time.Now().After(deadline) {
+			t.Fatalf("request %s not received", id)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func readResponse(t *testing.T, dir, id string) map[string]interface{} {
+	filename := filepath.Join(dir, "responses", id+".json")
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		data, err := os.ReadFile(filename)
+		if err == nil {
+			var response map[string]interface{}
+			err = json.Unmarshal(data, &response)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return response
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("no response for %s", id)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPlayerScoresCoalescing(t *testing.T) {
+	arrived := make(chan struct{})
+	release := make(chan struct{})
+
+	var mutex sync.Mutex
+	calls := make(map[string]int)
+
+	sess, dir := newTestSession(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/new-session.php":
+			w.Write([]byte(`{
+				"activeEvents": [],
+				"servicesAllowed": {"scoreSubmit": true, "playerScores": true, "playerLeaderboards": true},
+				"servicesResult": "OK"
+			}`))
+		case "/player-scores.php":
+			chartHash := r.URL.Query().Get("chartHashP1")
+
+			mutex.Lock()
+			calls[chartHash]++
+			first := len(calls) == 1 && calls[chartHash] == 1
+			mutex.Unlock()
+
+			// keep the worker busy while the other requests arrive
+			if first {
+				close(arrived)
+				<-release
+			}
+
+			fmt.Fprintf(w, `{"player1": {"chartHash": %q, "isRanked": true, "gsLeaderboard": []}}`, chartHash)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	sess.handleNewSession(&fsipc.GsNewSessionRequest{ChartHashVersion: 3})
+
+	request := func(chartHash string) string {
+		return fmt.Sprintf(`{
+			"action": "groovestats/player-scores",
+			"player1": {"chartHash": %q, "apiKey": "0123456789abcdef"}
+		}`, chartHash)
+	}
+
+	sendRequest(t, dir, "00000000000000000000000000000001", request("A"))
+	select {
+	case <-arrived:
+	case <-time.After(10 * time.Second):
+		t.Fatal("request not sent to GrooveStats")
+	}
+
+	// X is replaced by B, the theme scrolled on
+	sendRequest(t, dir, "00000000000000000000000000000002", request("X"))
+	sendRequest(t, dir, "00000000000000000000000000000003", request("B"))
+	sendRequest(t, dir, "00000000000000000000000000000004", request("B"))
+
+	response := readResponse(t, dir, "00000000000000000000000000000002")
+	if response["status"] != "superseded" {
+		t.Errorf("unexpected response for the superseded request: %v", response)
+	}
+
+	close(release)
+
+	for _, test := range []struct {
+		id        string
+		chartHash string
+	}{
+		{"00000000000000000000000000000001", "A"},
+		{"00000000000000000000000000000003", "B"},
+		{"00000000000000000000000000000004", "B"},
+	} {
+		response := readResponse(t, dir, test.id)
+		data, _ := response["data"].(map[string]interface{})
+		player1, _ := data["player1"].(map[string]interface{})
+		if response["status"] != "success" || player1["chartHash"] != test.chartHash {
+			t.Errorf("unexpected response for %s: %v", test.id, response)
+		}
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if calls["A"] != 1 || calls["B"] != 1 || calls["X"] != 0 {
+		t.Errorf("unexpected GrooveStats calls: %v", calls)
+	}
+}
+
+func TestReplayedScoreNoEventProgress(t *testing.T) {
+	sess, _ := newTestSession(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/new-session.php":
+			w.Write([]byte(`{
+				"activeEvents": [{"name": "Stamina RPG 6", "shortName": "SRPG6", "url": ""}],
+				"servicesAllowed": {"scoreSubmit": true, "playerScores": true, "playerLeaderboards": true},
+				"servicesResult": "OK"
+			}`))
+		case "/score-submit.php":
+			w.Write([]byte(`{"player1": {
+				"chartHash": "hash",
+				"isRanked": true,
+				"result": "improved",
+				"rpg": {"name": "Stamina RPG 6", "result": "improved", "scoreDelta": 12}
+			}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	sess.handleNewSession(&fsipc.GsNewSessionRequest{ChartHashVersion: 3})
+
+	cacheDir := t.TempDir()
+	var err error
+	sess.history, err = history.NewHistory(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sess.history.Close() })
+
+	sess.usage, err = usage.Open(cacheDir)
+	if err == nil {
+		sess.unlockManager, err = unlocks.NewManager(cacheDir)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var req fsipc.GsScoreSubmitRequest
+	err = json.Unmarshal([]byte(`{"player1": {"apiKey": "0123456789abcdef", "profileName": "domp", "chartHash": "hash", "score": 9876, "rate": 100}}`), &req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sess.submitQueuedScore(&req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := sess.history.Entries(history.Filter{})
+	if err != nil || len(entries) != 1 {
+		t.Errorf("replayed score not added to the history: %v", err)
+	}
+	if progress := sess.events.Progress("Stamina RPG 6"); len(progress) != 0 {
+		t.Errorf("replayed score counted as event progress: %+v", progress)
+	}
+}
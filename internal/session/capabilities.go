@@ -0,0 +1,49 @@
+package session
+
+import (
+	"github.com/GrooveStats/gslauncher/internal/fsipc"
+	"github.com/GrooveStats/gslauncher/internal/version"
+)
+
+// negotiateProtocol picks the newest protocol version supported by both the
+// theme and the launcher.
+func negotiateProtocol(req *fsipc.PingRequest) (int, bool) {
+	minProtocol := req.MinProtocol
+	if minProtocol == 0 {
+		minProtocol = req.Protocol
+	}
+
+	protocol := req.Protocol
+	if protocol > version.Protocol {
+		protocol = version.Protocol
+	}
+
+	if protocol < minProtocol || protocol < version.MinProtocol {
+		return 0, false
+	}
+
+	return protocol, true
+}
+
+// features lists optional launcher features, so themes don't have to guess
+// them from the launcher version.
+func (sess *Session) features() []string {
+	features := []string{
+		"invalid-responses",
+		"atomic-responses",
+		"player-scores-cache",
+		"player-leaderboards-cache",
+		"score-submit-queue",
+		"status-file",
+		"offline-mode",
+		"unlocks-list",
+		"unlocks-download",
+		"unlocks-unpack",
+	}
+
+	if sess.netIpc {
+		features = append(features, "net-ipc")
+	}
+
+	return features
+}
@@ -0,0 +1,74 @@
+package session
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/GrooveStats/gslauncher/internal/fakegs"
+	"github.com/GrooveStats/gslauncher/internal/settings"
+)
+
+// startFakeGs runs a fake GrooveStats server on a random local port. The
+// client talks to it while requests are simulated in the debug settings.
+func (sess *Session) startFakeGs() error {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+
+	sess.fakeGs = fakegs.NewServer(fakeGsScenario())
+	sess.loadFakeGsFixtures()
+
+	sess.fakeGsHttp = &http.Server{Handler: sess.fakeGs}
+	go sess.fakeGsHttp.Serve(listener)
+
+	sess.gsClient.SetFakeUrl("http://" + listener.Addr().String())
+
+	return nil
+}
+
+// ApplyDebugSettings passes changed debug settings on to the fake GrooveStats
+// server. This starts the scenario over, so it is only done when they are
+// saved.
+func (sess *Session) ApplyDebugSettings() {
+	if sess.fakeGs == nil {
+		return
+	}
+
+	sess.fakeGs.SetScenario(fakeGsScenario())
+	sess.loadFakeGsFixtures()
+}
+
+func (sess *Session) loadFakeGsFixtures() {
+	err := sess.fakeGs.LoadFixtures(settings.Get().FakeGsFixtureDir)
+	if err != nil {
+		sess.logger.Warn("failed to load fixtures", "err", err)
+	}
+}
+
+func fakeGsScenario() fakegs.Scenario {
+	s := settings.Get()
+
+	scenario := fakegs.DefaultScenario()
+	scenario.Rpg = s.FakeGsRpg
+	scenario.Itl = s.FakeGsItl
+	scenario.NewSessionResult = s.FakeGsNewSessionResult
+	scenario.SubmitResult = s.FakeGsSubmitResult
+
+	step := fakegs.Step{
+		DelayMs: s.FakeGsNetworkDelay * 1000,
+		Drop:    s.FakeGsNetworkError,
+	}
+	for _, endpoint := range []string{"new-session", "player-scores", "player-leaderboards", "score-submit"} {
+		scenario.Endpoints[endpoint] = []fakegs.Step{step}
+	}
+
+	return scenario
+}
+
+func (sess *Session) closeFakeGs() {
+	if sess.fakeGs != nil {
+		sess.fakeGsHttp.Close()
+		sess.fakeGs.Close()
+	}
+}
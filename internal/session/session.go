@@ -1,40 +1,102 @@
 package session
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"sync"
+	"time"
 
+	"github.com/GrooveStats/gslauncher/internal/events"
+	"github.com/GrooveStats/gslauncher/internal/fakegs"
 	"github.com/GrooveStats/gslauncher/internal/fsipc"
 	"github.com/GrooveStats/gslauncher/internal/groovestats"
+	"github.com/GrooveStats/gslauncher/internal/history"
+	"github.com/GrooveStats/gslauncher/internal/logging"
+	"github.com/GrooveStats/gslauncher/internal/metrics"
+	"github.com/GrooveStats/gslauncher/internal/scorequeue"
 	"github.com/GrooveStats/gslauncher/internal/settings"
 	"github.com/GrooveStats/gslauncher/internal/unlocks"
-	"github.com/GrooveStats/gslauncher/internal/version"
+	"github.com/GrooveStats/gslauncher/internal/usage"
 )
 
 type Session struct {
 	unlockManager *unlocks.Manager
+	scoreQueue    *scorequeue.Queue
+	history       *history.History
+	usage         *usage.Stats
+	events        *events.Tracker
+	logger        *slog.Logger
 	gsClient      *groovestats.Client
 	ipc           *fsipc.FsIpc
+	registry      *fsipc.Registry
 	cmd           *exec.Cmd
+	fakeGs        *fakegs.Server
+	fakeGsHttp    *http.Server
+	netIpc        bool
+	cacheDir      string
+	flushQueue    chan struct{}
+	shutdown      chan struct{}
+	ctx           context.Context
+	cancel        context.CancelFunc
 	wg            sync.WaitGroup
+
+	statusMutex    sync.Mutex
+	lastNewSession *groovestats.NewSessionResponse
+	statusChanged  chan struct{}
 }
 
-func Launch(unlockManager *unlocks.Manager) (*Session, error) {
+func Launch(unlockManager *unlocks.Manager, scoreQueue *scorequeue.Queue, scoreHistory *history.History, usageStats *usage.Stats, eventTracker *events.Tracker, cacheDir string) (*Session, error) {
+	start := time.Now()
+
+	gsClient, err := groovestats.NewClient(cacheDir)
+	if err != nil {
+		return nil, fmt.Errorf("Please check the HTTP proxy and CA bundle in the settings: %w", err)
+	}
+
+	// cancels the GrooveStats requests in flight when StepMania exits
+	ctx, cancel := context.WithCancel(context.Background())
+
 	sess := &Session{
 		unlockManager: unlockManager,
-		gsClient:      groovestats.NewClient(),
+		scoreQueue:    scoreQueue,
+		history:       scoreHistory,
+		usage:         usageStats,
+		events:        eventTracker,
+		logger:        logging.New("Session"),
+		cacheDir:      cacheDir,
+		gsClient:      gsClient,
+		flushQueue:    make(chan struct{}, 1),
+		shutdown:      make(chan struct{}),
+		statusChanged: make(chan struct{}, 1),
+		ctx:           ctx,
+		cancel:        cancel,
 	}
 
+	sess.gsClient.SetConnectivityCallback(sess.connectivityChanged)
+
 	if settings.Get().SmExePath == "" || settings.Get().SmSaveDir == "" || settings.Get().SmSongsDir == "" {
+		cancel()
 		return nil, fmt.Errorf("Please set paths to your StepMania executable, the Save directory, and the Songs directory in the settings!")
 	}
 
-	err := sess.startIpc()
+	if settings.Get().Debug {
+		err := sess.startFakeGs()
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to start fake GrooveStats server: %w", err)
+		}
+	}
+
+	err = sess.startIpc()
 	if err != nil {
+		sess.closeFakeGs()
+		cancel()
 		return nil, fmt.Errorf("failed to initialize fsipc: %w", err)
 	}
 
@@ -42,13 +104,31 @@ func Launch(unlockManager *unlocks.Manager) (*Session, error) {
 	if err != nil {
 		sess.ipc.Close()
 		sess.wg.Wait()
+		sess.closeFakeGs()
+		cancel()
 		return nil, fmt.Errorf("failed to run StepMania: %w", err)
 	}
 
-	sess.wg.Add(1)
+	err = sess.usage.StartSession(start)
+	if err != nil {
+		sess.logger.Warn("failed to save statistics", "err", err)
+	}
+	sess.events.StartSession()
+
+	sess.wg.Add(3)
+	go sess.processScoreQueue()
+	go sess.heartbeat()
 	go func() {
 		sess.cmd.Wait()
+		sess.cancel()
 		sess.ipc.Close()
+		sess.gsClient.Close()
+		sess.closeFakeGs()
+		err := sess.usage.EndSession(time.Now())
+		if err != nil {
+			sess.logger.Warn("failed to save statistics", "err", err)
+		}
+		close(sess.shutdown)
 		sess.wg.Done()
 	}()
 
@@ -78,23 +158,37 @@ func (sess *Session) startIpc() error {
 		return err
 	}
 
-	ipc, err := fsipc.New(dataDir)
+	sess.registry = fsipc.NewRegistry()
+	err = sess.registerActions(sess.registry)
 	if err != nil {
 		return err
 	}
 
-	processChannel := func(ch chan interface{}) {
-		defer sess.wg.Done()
+	ipc, err := fsipc.New(dataDir, sess.registry)
+	if err != nil {
+		return err
+	}
 
-		for request := range ch {
-			sess.processRequest(request)
+	if settings.Get().IpcRecord {
+		name := time.Now().Format("2006-01-02T15-04-05") + ".jsonl"
+		filename := filepath.Join(sess.cacheDir, "groovestats-launcher", "transcripts", name)
+
+		err = ipc.Record(filename)
+		if err != nil {
+			sess.logger.Warn("failed to start recording", "err", err)
 		}
 	}
 
-	sess.wg.Add(3)
-	go processChannel(ipc.GsPlayerScoresRequests)
-	go processChannel(ipc.GsPlayerLeaderboardsRequests)
-	go processChannel(ipc.Requests)
+	port := settings.Get().IpcServerPort
+	if port != 0 {
+		_, err = ipc.Listen(fmt.Sprintf("127.0.0.1:%d", port))
+		if err != nil {
+			// the filesystem transport still works, so carry on
+			sess.logger.Warn("failed to start network transport", "err", err)
+		} else {
+			sess.netIpc = true
+		}
+	}
 
 	sess.ipc = ipc
 	return nil
@@ -133,153 +227,210 @@ func (sess *Session) startSM() error {
 }
 
 func newNetworkResponse(data interface{}, err error) *fsipc.NetworkResponse {
-	status := "success"
+	response := &fsipc.NetworkResponse{
+		Status: "success",
+		Data:   data,
+	}
+
 	if err != nil {
-		switch err.(type) {
+		switch e := err.(type) {
 		case *groovestats.DisabledError:
-			status = "disabled"
+			response.Status = "disabled"
+			response.Reason = e.Reason()
+			if retryAt := e.RetryAt(); !retryAt.IsZero() {
+				response.RetryAt = &retryAt
+			}
 		default:
-			status = "fail"
+			response.Status = "fail"
 		}
 	}
 
-	return &fsipc.NetworkResponse{
-		Status: status,
-		Data:   data,
-	}
+	return response
 }
 
-func (sess *Session) processRequest(request interface{}) {
-	switch req := request.(type) {
-	case *fsipc.PingRequest:
-		if req.Protocol != version.Protocol {
-			break
-		}
+// handleScoreSubmitResponse records a submitted score. Queued scores are
+// replayed, they may have been played in an earlier session and don't count
+// as event progress.
+func (sess *Session) handleScoreSubmitResponse(req *fsipc.GsScoreSubmitRequest, resp *groovestats.ScoreSubmitResponse, replayed bool) {
+	err := sess.history.Add(req, resp, time.Now())
+	if err != nil {
+		sess.logger.Error("failed to add score to history", "err", err)
+	}
+
+	if !replayed {
+		sess.events.AddScoreSubmit(req, resp)
+	}
 
-		response := fsipc.PingResponse{
-			Version: fsipc.PingVersion{
-				Major: version.Major,
-				Minor: version.Minor,
-				Patch: version.Patch,
-			},
+	if req.Player1 != nil && resp.Player1 != nil {
+		metrics.ScoresSubmitted.Inc()
+	}
+	if req.Player2 != nil && resp.Player2 != nil {
+		metrics.ScoresSubmitted.Inc()
+	}
+
+	// saved once the unlocks below are counted as well
+	defer func() {
+		err := sess.usage.Checkpoint()
+		if err != nil {
+			sess.logger.Warn("failed to save statistics", "err", err)
 		}
+	}()
+
+	if req.Player1 != nil && resp.Player1 != nil {
+		if resp.Player1.Rpg != nil && resp.Player1.Rpg.Progress != nil {
+			for _, quest := range resp.Player1.Rpg.Progress.QuestsCompleted {
+				if quest.SongDownloadUrl == nil {
+					continue
+				}
 
-		sess.ipc.WriteResponse(req.Id, response)
-	case *fsipc.GsNewSessionRequest:
-		resp, err := sess.gsClient.NewSession(req)
-		response := newNetworkResponse(resp, err)
-		sess.ipc.WriteResponse(req.Id, response)
-	case *fsipc.GsPlayerScoresRequest:
-		resp, err := sess.gsClient.PlayerScores(req)
-		response := newNetworkResponse(resp, err)
-		sess.ipc.WriteResponse(req.Id, response)
-	case *fsipc.GsPlayerLeaderboardsRequest:
-		resp, err := sess.gsClient.PlayerLeaderboards(req)
-		response := newNetworkResponse(resp, err)
-		sess.ipc.WriteResponse(req.Id, response)
-	case *fsipc.GsScoreSubmitRequest:
-		resp, err := sess.gsClient.ScoreSubmit(req)
-		response := newNetworkResponse(resp, err)
-		sess.ipc.WriteResponse(req.Id, response)
-
-		if err == nil {
-			if req.Player1 != nil && resp.Player1 != nil {
-				if resp.Player1.Rpg != nil && resp.Player1.Rpg.Progress != nil {
-					for _, quest := range resp.Player1.Rpg.Progress.QuestsCompleted {
-						if quest.SongDownloadUrl == nil {
-							continue
-						}
-
-						descriptions := make([]string, 0)
-						for _, reward := range quest.Rewards {
-							if reward.Type == "song" {
-								descriptions = append(descriptions, reward.Description)
-							}
-						}
-
-						sess.unlockManager.AddUnlock(
-							quest.Title,
-							*quest.SongDownloadUrl,
-							resp.Player1.Rpg.Name,
-							req.Player1.ProfileName,
-							descriptions,
-						)
+				descriptions := make([]string, 0)
+				for _, reward := range quest.Rewards {
+					if reward.Type == "song" {
+						descriptions = append(descriptions, reward.Description)
 					}
 				}
 
-				if resp.Player1.Itl != nil && resp.Player1.Itl.Progress != nil {
-					for _, quest := range resp.Player1.Itl.Progress.QuestsCompleted {
-						if quest.SongDownloadUrl == nil {
-							continue
-						}
-
-						descriptions := make([]string, 0)
-						for _, reward := range quest.Rewards {
-							if reward.Type == "song" {
-								descriptions = append(descriptions, reward.Description)
-							}
-						}
-
-						sess.unlockManager.AddUnlock(
-							quest.Title,
-							*quest.SongDownloadUrl,
-							resp.Player1.Itl.Name,
-							req.Player1.ProfileName,
-							descriptions,
-						)
+				sess.unlockManager.AddUnlock(
+					quest.Title,
+					*quest.SongDownloadUrl,
+					resp.Player1.Rpg.Name,
+					req.Player1.ProfileName,
+					descriptions,
+				)
+			}
+		}
+
+		if resp.Player1.Itl != nil && resp.Player1.Itl.Progress != nil {
+			for _, quest := range resp.Player1.Itl.Progress.QuestsCompleted {
+				if quest.SongDownloadUrl == nil {
+					continue
+				}
+
+				descriptions := make([]string, 0)
+				for _, reward := range quest.Rewards {
+					if reward.Type == "song" {
+						descriptions = append(descriptions, reward.Description)
 					}
 				}
+
+				sess.unlockManager.AddUnlock(
+					quest.Title,
+					*quest.SongDownloadUrl,
+					resp.Player1.Itl.Name,
+					req.Player1.ProfileName,
+					descriptions,
+				)
 			}
+		}
+	}
+
+	if req.Player2 != nil && resp.Player2 != nil {
+		if resp.Player2.Rpg != nil && resp.Player2.Rpg.Progress != nil {
+			for _, quest := range resp.Player2.Rpg.Progress.QuestsCompleted {
+				if quest.SongDownloadUrl == nil {
+					continue
+				}
 
-			if req.Player2 != nil && resp.Player2 != nil {
-				if resp.Player2.Rpg != nil && resp.Player2.Rpg.Progress != nil {
-					for _, quest := range resp.Player2.Rpg.Progress.QuestsCompleted {
-						if quest.SongDownloadUrl == nil {
-							continue
-						}
-
-						descriptions := make([]string, 0)
-						for _, reward := range quest.Rewards {
-							if reward.Type == "song" {
-								descriptions = append(descriptions, reward.Description)
-							}
-						}
-
-						sess.unlockManager.AddUnlock(
-							quest.Title,
-							*quest.SongDownloadUrl,
-							resp.Player2.Rpg.Name,
-							req.Player2.ProfileName,
-							descriptions,
-						)
+				descriptions := make([]string, 0)
+				for _, reward := range quest.Rewards {
+					if reward.Type == "song" {
+						descriptions = append(descriptions, reward.Description)
 					}
 				}
 
-				if resp.Player2.Itl != nil && resp.Player2.Itl.Progress != nil {
-					for _, quest := range resp.Player2.Itl.Progress.QuestsCompleted {
-						if quest.SongDownloadUrl == nil {
-							continue
-						}
-
-						descriptions := make([]string, 0)
-						for _, reward := range quest.Rewards {
-							if reward.Type == "song" {
-								descriptions = append(descriptions, reward.Description)
-							}
-						}
-
-						sess.unlockManager.AddUnlock(
-							quest.Title,
-							*quest.SongDownloadUrl,
-							resp.Player2.Itl.Name,
-							req.Player2.ProfileName,
-							descriptions,
-						)
+				sess.unlockManager.AddUnlock(
+					quest.Title,
+					*quest.SongDownloadUrl,
+					resp.Player2.Rpg.Name,
+					req.Player2.ProfileName,
+					descriptions,
+				)
+			}
+		}
+
+		if resp.Player2.Itl != nil && resp.Player2.Itl.Progress != nil {
+			for _, quest := range resp.Player2.Itl.Progress.QuestsCompleted {
+				if quest.SongDownloadUrl == nil {
+					continue
+				}
+
+				descriptions := make([]string, 0)
+				for _, reward := range quest.Rewards {
+					if reward.Type == "song" {
+						descriptions = append(descriptions, reward.Description)
 					}
 				}
+
+				sess.unlockManager.AddUnlock(
+					quest.Title,
+					*quest.SongDownloadUrl,
+					resp.Player2.Itl.Name,
+					req.Player2.ProfileName,
+					descriptions,
+				)
 			}
 		}
-	default:
-		panic("unknown request type")
 	}
 }
+
+func (sess *Session) processScoreQueue() {
+	defer sess.wg.Done()
+
+	// Queued scores can only be submitted once GrooveStats allowed score
+	// submission for this session.
+	select {
+	case <-sess.flushQueue:
+	case <-sess.shutdown:
+		return
+	}
+
+	for {
+		wait := sess.scoreQueue.Flush(sess.submitQueuedScore)
+		if wait < 0 {
+			wait = time.Hour
+		}
+
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-timer.C:
+		case <-sess.flushQueue:
+			timer.Stop()
+		case <-sess.shutdown:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+func (sess *Session) connectivityChanged(offline bool) {
+	if offline {
+		sess.logger.Warn("GrooveStats can't be reached, switching to offline mode")
+	} else {
+		sess.logger.Info("GrooveStats can be reached again")
+
+		sess.statusMutex.Lock()
+		allowed := sess.lastNewSession != nil && sess.lastNewSession.ServicesAllowed.ScoreSubmit
+		sess.statusMutex.Unlock()
+
+		// submit the scores queued while offline
+		if allowed {
+			select {
+			case sess.flushQueue <- struct{}{}:
+			default:
+			}
+		}
+	}
+
+	sess.updateStatus()
+}
+
+func (sess *Session) submitQueuedScore(req *fsipc.GsScoreSubmitRequest) error {
+	resp, err := sess.gsClient.ScoreSubmit(sess.ctx, req)
+	if err != nil {
+		return err
+	}
+
+	sess.handleScoreSubmitResponse(req, resp, true)
+	return nil
+}
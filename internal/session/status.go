@@ -0,0 +1,81 @@
+package session
+
+import (
+	"os"
+	"time"
+
+	"github.com/GrooveStats/gslauncher/internal/fsipc"
+	"github.com/GrooveStats/gslauncher/internal/groovestats"
+	"github.com/GrooveStats/gslauncher/internal/version"
+)
+
+// Themes can tell the launcher is gone when the heartbeat stops updating.
+const heartbeatInterval = 5 * time.Second
+
+func (sess *Session) setNewSessionResponse(resp *groovestats.NewSessionResponse) {
+	sess.statusMutex.Lock()
+	sess.lastNewSession = resp
+	sess.statusMutex.Unlock()
+
+	sess.events.SetActiveEvents(resp)
+	sess.updateStatus()
+}
+
+// updateStatus rewrites the status file without waiting for the next
+// heartbeat.
+func (sess *Session) updateStatus() {
+	select {
+	case sess.statusChanged <- struct{}{}:
+	default:
+	}
+}
+
+func (sess *Session) writeStatus() {
+	status := fsipc.Status{
+		Version: fsipc.PingVersion{
+			Major: version.Major,
+			Minor: version.Minor,
+			Patch: version.Patch,
+		},
+		LauncherVersion: version.Formatted(),
+		Protocol:        version.Protocol,
+		Pid:             os.Getpid(),
+		Heartbeat:       time.Now(),
+		PermanentError:  sess.gsClient.Disabled(),
+		Endpoints:       sess.gsClient.Breakers(),
+		PendingUnlocks:  sess.unlockManager.PendingCount(),
+	}
+
+	if offlineSince := sess.gsClient.OfflineSince(); !offlineSince.IsZero() {
+		status.OfflineSince = &offlineSince
+	}
+
+	sess.statusMutex.Lock()
+	if sess.lastNewSession != nil {
+		status.NewSession = sess.lastNewSession
+	}
+	sess.statusMutex.Unlock()
+
+	err := sess.ipc.WriteStatus(&status)
+	if err != nil {
+		sess.logger.Warn("failed to write status file", "err", err)
+	}
+}
+
+func (sess *Session) heartbeat() {
+	defer sess.wg.Done()
+
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		sess.writeStatus()
+
+		select {
+		case <-ticker.C:
+		case <-sess.statusChanged:
+		case <-sess.shutdown:
+			return
+		}
+	}
+}
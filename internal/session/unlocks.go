@@ -0,0 +1,178 @@
+package session
+
+import (
+	"fmt"
+
+	"github.com/GrooveStats/gslauncher/internal/fsipc"
+	"github.com/GrooveStats/gslauncher/internal/settings"
+	"github.com/GrooveStats/gslauncher/internal/unlocks"
+)
+
+func describeDownloadStatus(status unlocks.DownloadStatus) string {
+	switch status {
+	case unlocks.Downloading:
+		return "downloading"
+	case unlocks.Downloaded:
+		return "downloaded"
+	default:
+		return "not-downloaded"
+	}
+}
+
+func describeUnpackStatus(status unlocks.UnpackStatus) string {
+	switch status {
+	case unlocks.Unpacking:
+		return "unpacking"
+	case unlocks.Unpacked:
+		return "unpacked"
+	default:
+		return "not-unpacked"
+	}
+}
+
+func newLauncherUnlock(unlock *unlocks.Unlock) fsipc.LauncherUnlock {
+	data := fsipc.LauncherUnlock{
+		Id:               unlock.Id(),
+		RpgName:          unlock.RpgName,
+		QuestTitle:       unlock.QuestTitle,
+		SongDescriptions: unlock.SongDescriptions,
+		DownloadStatus:   describeDownloadStatus(unlock.DownloadStatus),
+		DownloadSize:     unlock.DownloadSize,
+		DownloadProgress: unlock.DownloadProgress,
+		Users:            make([]fsipc.LauncherUnlockUser, 0, len(unlock.Users)),
+	}
+
+	if unlock.DownloadError != nil {
+		data.DownloadError = unlock.DownloadError.Error()
+	}
+
+	for _, user := range unlock.Users {
+		userData := fsipc.LauncherUnlockUser{
+			ProfileName:  user.ProfileName,
+			UnpackStatus: describeUnpackStatus(user.UnpackStatus),
+		}
+		if user.UnpackError != nil {
+			userData.UnpackError = user.UnpackError.Error()
+		}
+
+		data.Users = append(data.Users, userData)
+	}
+
+	return data
+}
+
+func newUnlocksResponse(unlockList []unlocks.Unlock) *fsipc.NetworkResponse {
+	data := fsipc.LauncherUnlocksResponse{
+		UserUnlocks: settings.Get().UserUnlocks,
+		Unlocks:     make([]fsipc.LauncherUnlock, 0, len(unlockList)),
+	}
+
+	for i := range unlockList {
+		data.Unlocks = append(data.Unlocks, newLauncherUnlock(&unlockList[i]))
+	}
+
+	return &fsipc.NetworkResponse{
+		Status: "success",
+		Data:   data,
+	}
+}
+
+func newUnknownUnlockResponse(id string) *fsipc.InvalidResponse {
+	return fsipc.NewInvalidResponse(&fsipc.RequestError{
+		Code:    fsipc.ErrorValidation,
+		Message: fmt.Sprintf("unknown unlock %s", id),
+		Field:   "unlockId",
+	})
+}
+
+func (sess *Session) handleUnlocksList(request interface{}) interface{} {
+	return newUnlocksResponse(sess.unlockManager.Snapshot())
+}
+
+// unpackStarted reports whether the unlock is unpacked or being unpacked for
+// the profile, or for everyone if the profile name is empty.
+func unpackStarted(unlock *unlocks.Unlock, profileName string) bool {
+	for _, user := range unlock.Users {
+		if profileName != "" && user.ProfileName != profileName {
+			continue
+		}
+		if user.UnpackStatus == unlocks.NotUnpacked {
+			return false
+		}
+	}
+
+	return true
+}
+
+// newBusyResponse answers a request that couldn't be queued because the
+// unlock has too many actions waiting already.
+func (sess *Session) newBusyResponse(unlock *unlocks.Unlock) *fsipc.NetworkResponse {
+	response := newUnlocksResponse([]unlocks.Unlock{sess.unlockManager.SnapshotOf(unlock)})
+	response.Status = "busy"
+	return response
+}
+
+func (sess *Session) handleUnlocksDownload(request interface{}) interface{} {
+	req := request.(*fsipc.LauncherUnlocksDownloadRequest)
+
+	unlock := sess.unlockManager.GetUnlock(req.UnlockId)
+	if unlock == nil {
+		return newUnknownUnlockResponse(req.UnlockId)
+	}
+
+	// The zip is deleted after unpacking when unlocks aren't separated by
+	// user, it isn't needed again then.
+	state := sess.unlockManager.SnapshotOf(unlock)
+	if state.DownloadStatus == unlocks.NotDownloaded && !unpackStarted(&state, "") {
+		if !unlock.TryQueueDownload() {
+			return sess.newBusyResponse(unlock)
+		}
+	}
+
+	return newUnlocksResponse([]unlocks.Unlock{sess.unlockManager.SnapshotOf(unlock)})
+}
+
+func (sess *Session) handleUnlocksUnpack(request interface{}) interface{} {
+	req := request.(*fsipc.LauncherUnlocksUnpackRequest)
+
+	unlock := sess.unlockManager.GetUnlock(req.UnlockId)
+	if unlock == nil {
+		return newUnknownUnlockResponse(req.UnlockId)
+	}
+
+	state := sess.unlockManager.SnapshotOf(unlock)
+
+	profileName := ""
+	if settings.Get().UserUnlocks {
+		found := false
+		for _, user := range state.Users {
+			if user.ProfileName == req.ProfileName {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return fsipc.NewInvalidResponse(&fsipc.RequestError{
+				Code:    fsipc.ErrorValidation,
+				Message: fmt.Sprintf("%s didn't unlock %s", req.ProfileName, req.UnlockId),
+				Field:   "profileName",
+			})
+		}
+
+		profileName = req.ProfileName
+	}
+
+	if !unpackStarted(&state, profileName) {
+		// the queue of an unlock is processed in order, so the download is
+		// done by the time the unpacking starts
+		if state.DownloadStatus == unlocks.NotDownloaded && !unlock.TryQueueDownload() {
+			return sess.newBusyResponse(unlock)
+		}
+		if !unlock.TryQueueUnpack(profileName) {
+			return sess.newBusyResponse(unlock)
+		}
+	}
+
+	return newUnlocksResponse([]unlocks.Unlock{sess.unlockManager.SnapshotOf(unlock)})
+}
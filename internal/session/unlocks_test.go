@@ -0,0 +1,189 @@
+package session
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GrooveStats/gslauncher/internal/fsipc"
+	"github.com/GrooveStats/gslauncher/internal/settings"
+	"github.com/GrooveStats/gslauncher/internal/unlocks"
+)
+
+// newTestPack returns a zip with one song. It isn't compressed, so it takes a
+// moment to download.
+func newTestPack(t *testing.T) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	f, err := w.CreateHeader(&zip.FileHeader{Name: "Song/song.sm", Method: zip.Store})
+	if err == nil {
+		_, err = f.Write(bytes.Repeat([]byte("#TITLE:Song;\n"), 1000))
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestUnlocksListDuringDownload(t *testing.T) {
+	pack := newTestPack(t)
+
+	// send the pack in small pieces, so it is listed while downloading
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < len(pack); i += 64 {
+			end := i + 64
+			if end > len(pack) {
+				end = len(pack)
+			}
+			w.Write(pack[i:end])
+			w.(http.Flusher).Flush()
+			time.Sleep(time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	oldSettings := settings.Get()
+	defer settings.Update(oldSettings)
+
+	newSettings := settings.Get()
+	newSettings.AutoDownloadMode = settings.AutoDownloadOff
+	newSettings.UserUnlocks = true
+	newSettings.SmSongsDir = t.TempDir()
+	settings.Update(newSettings)
+
+	unlockManager, err := unlocks.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess := &Session{unlockManager: unlockManager}
+
+	unlockManager.AddUnlock("Quest", server.URL+"/pack.zip", "Rpg", "player1", []string{"Song"})
+	unlockId := unlockManager.Snapshot()[0].Id()
+
+	sess.handleUnlocksUnpack(&fsipc.LauncherUnlocksUnpackRequest{
+		UnlockId:    unlockId,
+		ProfileName: "player1",
+	})
+
+	// a second player unlocks it while it is being downloaded
+	go unlockManager.AddUnlock("Quest", server.URL+"/pack.zip", "Rpg", "player2", []string{"Song"})
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		response := sess.handleUnlocksList(&fsipc.LauncherUnlocksListRequest{}).(*fsipc.NetworkResponse)
+		data := response.Data.(fsipc.LauncherUnlocksResponse)
+
+		if len(data.Unlocks) != 1 {
+			t.Fatalf("unexpected unlocks: %+v", data.Unlocks)
+		}
+
+		unlock := data.Unlocks[0]
+		if unlock.DownloadError != "" {
+			t.Fatalf("download failed: %s", unlock.DownloadError)
+		}
+
+		unpacked := false
+		for _, user := range unlock.Users {
+			if user.ProfileName == "player1" {
+				unpacked = user.UnpackStatus == "unpacked"
+			}
+		}
+		if unpacked {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("unlock not unpacked: %+v", unlock)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestUnlocksUnpackOnce(t *testing.T) {
+	pack := newTestPack(t)
+
+	var mutex sync.Mutex
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		downloads++
+		mutex.Unlock()
+
+		w.Write(pack)
+	}))
+	defer server.Close()
+
+	oldSettings := settings.Get()
+	defer settings.Update(oldSettings)
+
+	newSettings := settings.Get()
+	newSettings.AutoDownloadMode = settings.AutoDownloadOff
+	newSettings.UserUnlocks = false
+	newSettings.SmSongsDir = t.TempDir()
+	settings.Update(newSettings)
+
+	unlockManager, err := unlocks.NewManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess := &Session{unlockManager: unlockManager}
+
+	updates := make(chan struct{}, 100)
+	unlockManager.SetUpdateCallback(func(*unlocks.Unlock) {
+		select {
+		case updates <- struct{}{}:
+		default:
+		}
+	})
+
+	unlockManager.AddUnlock("Quest", server.URL+"/pack.zip", "Rpg", "player1", []string{"Song"})
+	unlockId := unlockManager.Snapshot()[0].Id()
+
+	unpack := func() fsipc.LauncherUnlock {
+		response := sess.handleUnlocksUnpack(&fsipc.LauncherUnlocksUnpackRequest{UnlockId: unlockId}).(*fsipc.NetworkResponse)
+		if response.Status != "success" {
+			t.Fatalf("unexpected status %s", response.Status)
+		}
+		return response.Data.(fsipc.LauncherUnlocksResponse).Unlocks[0]
+	}
+
+	unpack()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for unpack().Users[0].UnpackStatus != "unpacked" {
+		if time.Now().After(deadline) {
+			t.Fatal("unlock not unpacked")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// the zip is gone after unpacking, but isn't needed again
+	sess.handleUnlocksDownload(&fsipc.LauncherUnlocksDownloadRequest{UnlockId: unlockId})
+	unpack()
+
+	// The refresh is handled after the actions queued before. It is the
+	// only one left that reports an update.
+	for len(updates) > 0 {
+		<-updates
+	}
+	unlockManager.GetUnlock(unlockId).QueueRefresh()
+	select {
+	case <-updates:
+	case <-time.After(10 * time.Second):
+		t.Fatal("refresh not handled")
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if downloads != 1 {
+		t.Errorf("unlock downloaded %d times", downloads)
+	}
+}
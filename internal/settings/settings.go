@@ -2,8 +2,24 @@ package settings
 
 import (
 	"encoding/json"
+	"errors"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/GrooveStats/gslauncher/internal/logging"
+)
+
+const DefaultGrooveStatsUrl = "https://api.groovestats.com"
+
+// default timeouts in seconds, also used when a stored timeout isn't positive
+const (
+	DefaultHttpTimeout         = 12
+	DefaultHttpSubmitTimeout   = 32
+	DefaultHttpDownloadTimeout = 30
 )
 
 type AutoDownloadMode int
@@ -51,6 +67,51 @@ func (m AutoDownloadMode) MarshalJSON() ([]byte, error) {
 	return json.Marshal(s)
 }
 
+type WatchMode int
+
+const (
+	WatchAuto WatchMode = iota
+	WatchFsnotify
+	WatchPoll
+)
+
+func (m *WatchMode) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	switch s {
+	case "auto":
+		*m = WatchAuto
+	case "fsnotify":
+		*m = WatchFsnotify
+	case "poll":
+		*m = WatchPoll
+	default:
+		*m = WatchAuto
+	}
+
+	return nil
+}
+
+func (m WatchMode) MarshalJSON() ([]byte, error) {
+	var s string
+
+	switch m {
+	case WatchAuto:
+		s = "auto"
+	case WatchFsnotify:
+		s = "fsnotify"
+	case WatchPoll:
+		s = "poll"
+	default:
+		s = "auto"
+	}
+
+	return json.Marshal(s)
+}
+
 type Settings struct {
 	FirstLaunch      bool `json:"-"`
 	SmExePath        string
@@ -60,6 +121,37 @@ type Settings struct {
 	AutoDownloadMode AutoDownloadMode
 	UserUnlocks      bool
 	AutoLaunch       bool
+	IpcServerPort    int
+	IpcWatchMode     WatchMode
+	IpcFsync         bool
+	IpcRecord        bool
+
+	// cache lifetimes in minutes
+	CachePlayerScoresTtl       int
+	CachePlayerLeaderboardsTtl int
+	CacheStaleWhileRevalidate  bool
+
+	// base url of the GrooveStats API, can point to cmd/fakegs for testing.
+	// Empty to use DefaultGrooveStatsUrl.
+	GrooveStatsUrl string `json:",omitempty"`
+
+	// proxy url, empty to use the proxy from the environment
+	HttpProxy string
+
+	// PEM file with CA certificates trusted in addition to the system ones
+	HttpCaBundle string
+
+	// timeouts in seconds for GrooveStats requests, score submissions and
+	// until an unlock download starts
+	HttpTimeout         int
+	HttpSubmitTimeout   int
+	HttpDownloadTimeout int
+
+	// port for the OpenMetrics endpoint on 127.0.0.1, 0 to disable
+	MetricsPort int
+
+	// minimum level of the log records: debug, info, warn or error
+	LogLevel string
 
 	// debug settings, not stored in the json
 	Debug                  bool   `json:"-"`
@@ -70,12 +162,16 @@ type Settings struct {
 	FakeGsSubmitResult     string `json:"-"`
 	FakeGsRpg              bool   `json:"-"`
 	FakeGsItl              bool   `json:"-"`
-	GrooveStatsUrl         string `json:"-"`
+	FakeGsFixtureDir       string `json:"-"`
 
 	// backwards compatibility fields
 	SmDataDir string `json:",omitempty"`
 }
 
+// guards settings, which are read by the session goroutines while the GUI
+// changes them
+var mutex sync.RWMutex
+
 var settings = Settings{
 	FirstLaunch:      true,
 	SmExePath:        "",
@@ -85,6 +181,26 @@ var settings = Settings{
 	AutoDownloadMode: AutoDownloadOff,
 	UserUnlocks:      false,
 	AutoLaunch:       false,
+	IpcServerPort:    0,
+	IpcWatchMode:     WatchAuto,
+	IpcFsync:         false,
+	IpcRecord:        false,
+
+	CachePlayerScoresTtl:       15,
+	CachePlayerLeaderboardsTtl: 5,
+	CacheStaleWhileRevalidate:  false,
+
+	GrooveStatsUrl: "",
+
+	HttpProxy:           "",
+	HttpCaBundle:        "",
+	HttpTimeout:         DefaultHttpTimeout,
+	HttpSubmitTimeout:   DefaultHttpSubmitTimeout,
+	HttpDownloadTimeout: DefaultHttpDownloadTimeout,
+
+	MetricsPort: 0,
+
+	LogLevel: "info",
 
 	Debug:                  debug,
 	FakeGs:                 false,
@@ -94,10 +210,13 @@ var settings = Settings{
 	FakeGsSubmitResult:     "score-added",
 	FakeGsRpg:              true,
 	FakeGsItl:              true,
-	GrooveStatsUrl:         "https://api.groovestats.com",
+	FakeGsFixtureDir:       "",
 }
 
 func Get() Settings {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
 	return settings
 }
 
@@ -114,6 +233,9 @@ func Load() error {
 		return err
 	}
 
+	mutex.Lock()
+	defer mutex.Unlock()
+
 	err = json.Unmarshal(data, &settings)
 	if err != nil {
 		return err
@@ -127,17 +249,82 @@ func Load() error {
 		settings.SmDataDir = ""
 	}
 
+	err = ValidateGrooveStatsUrl(settings.GrooveStatsUrl)
+	if err != nil {
+		logging.New("Settings").Warn("ignoring invalid GrooveStats URL", "url", settings.GrooveStatsUrl, "err", err)
+		settings.GrooveStatsUrl = ""
+	}
+
+	if settings.HttpTimeout <= 0 {
+		settings.HttpTimeout = DefaultHttpTimeout
+	}
+	if settings.HttpSubmitTimeout <= 0 {
+		settings.HttpSubmitTimeout = DefaultHttpSubmitTimeout
+	}
+	if settings.HttpDownloadTimeout <= 0 {
+		settings.HttpDownloadTimeout = DefaultHttpDownloadTimeout
+	}
+
+	if settings.LogLevel == "" {
+		settings.LogLevel = "info"
+	}
+
 	settings.FirstLaunch = false
 	return nil
 }
 
+// GrooveStatsApiUrl returns the base url of the GrooveStats API without a
+// trailing slash.
+func (s *Settings) GrooveStatsApiUrl() string {
+	if s.GrooveStatsUrl == "" {
+		return DefaultGrooveStatsUrl
+	}
+
+	return strings.TrimSuffix(s.GrooveStatsUrl, "/")
+}
+
+// ValidateGrooveStatsUrl checks a GrooveStats URL override. API keys are sent
+// to it, so plain http is only allowed for servers on this machine.
+func ValidateGrooveStatsUrl(s string) error {
+	if s == "" {
+		return nil
+	}
+
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return errors.New("Must be a http or https URL")
+	}
+
+	switch u.Scheme {
+	case "https":
+		return nil
+	case "http":
+		if u.Hostname() == "localhost" {
+			return nil
+		}
+		ip := net.ParseIP(u.Hostname())
+		if ip != nil && ip.IsLoopback() {
+			return nil
+		}
+		return errors.New("Must be a https URL, http is only allowed for localhost")
+	default:
+		return errors.New("Must be a http or https URL")
+	}
+}
+
 func Update(newSettings Settings) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
 	settings = newSettings
 }
 
 func DetectSM() {
 	smExePath, smSaveDir, smSongsDir, smLogsDir := detectSM()
 
+	mutex.Lock()
+	defer mutex.Unlock()
+
 	settings.SmExePath = smExePath
 	settings.SmSaveDir = smSaveDir
 	settings.SmSongsDir = smSongsDir
@@ -161,7 +348,7 @@ func Save() error {
 		}
 	}
 
-	data, err := json.Marshal(settings)
+	data, err := json.Marshal(Get())
 	if err != nil {
 		return err
 	}
@@ -1,7 +0,0 @@
-package stats
-
-var GsNewSessionCount int
-var GsPlayerScoresCount int
-var GsPlayerScoresCachedCount int
-var GsPlayerLeaderboardsCount int
-var GsScoreSubmitCount int
@@ -3,8 +3,11 @@ package unlocks
 import (
 	"fmt"
 	"io"
-	"net/http"
 	"os"
+	"time"
+
+	"github.com/GrooveStats/gslauncher/internal/httpclient"
+	"github.com/GrooveStats/gslauncher/internal/metrics"
 )
 
 type DownloadInfo struct {
@@ -53,6 +56,17 @@ func fetch(download *Download) {
 	}
 	download.Progress <- info
 
+	start := time.Now()
+	defer func() {
+		outcome := "success"
+		if download.cancel {
+			outcome = "cancelled"
+		} else if info.Error != nil {
+			outcome = "fail"
+		}
+		metrics.DownloadDuration.With(outcome).Observe(time.Since(start).Seconds())
+	}()
+
 	outFile, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
 		info.Error = err
@@ -64,7 +78,14 @@ func fetch(download *Download) {
 		os.Remove(filename)
 	}()
 
-	resp, err := http.Get(download.Url)
+	client, err := httpclient.NewDownloadClient()
+	if err != nil {
+		info.Error = err
+		download.Progress <- info
+		return
+	}
+
+	resp, err := client.Get(download.Url)
 	if err != nil {
 		info.Error = err
 		download.Progress <- info
@@ -89,6 +110,7 @@ func fetch(download *Download) {
 	for {
 		written, err := io.CopyN(outFile, resp.Body, 32*1024)
 		info.Downloaded += written
+		metrics.DownloadBytes.Add(uint64(written))
 		download.Progress <- info
 
 		if download.cancel {
@@ -6,7 +6,9 @@ import (
 
 type actionDownload struct{}
 type actionRefresh struct{}
-type actionUnpack struct{ user *UserData }
+
+// the profile name is empty when the unlock is unpacked for everyone
+type actionUnpack struct{ profileName string }
 
 func (manager *Manager) processQueue(unlock *Unlock) {
 	for action := range unlock.queue {
@@ -16,28 +18,49 @@ func (manager *Manager) processQueue(unlock *Unlock) {
 		case actionRefresh:
 			manager.refresh(unlock)
 		case actionUnpack:
-			manager.doUnpack(unlock, a.user)
+			manager.doUnpack(unlock, a.profileName)
 		}
 	}
 }
 
 func (manager *Manager) doDownload(unlock *Unlock) {
-	if unlock.DownloadStatus != NotDownloaded {
+	manager.mutex.Lock()
+	status := unlock.DownloadStatus
+
+	// the zip is deleted after unpacking unless unlocks are separated by
+	// user, nobody needs it then
+	needed := false
+	for _, user := range unlock.Users {
+		if user.UnpackStatus != Unpacked {
+			needed = true
+		}
+	}
+	manager.mutex.Unlock()
+
+	if status != NotDownloaded || !needed {
 		return
 	}
 
 	manager.download(unlock)
 }
 
-func (manager *Manager) doUnpack(unlock *Unlock, user *UserData) {
+func (manager *Manager) doUnpack(unlock *Unlock, profileName string) {
+	manager.mutex.Lock()
+
 	if unlock.DownloadStatus != Downloaded {
+		manager.mutex.Unlock()
 		return
 	}
 
 	if settings.Get().UserUnlocks {
-		if user.UnpackStatus != NotUnpacked {
-			return
+		var user *UserData
+		for _, u := range unlock.Users {
+			if u.ProfileName == profileName && u.UnpackStatus == NotUnpacked {
+				user = u
+				break
+			}
 		}
+		manager.mutex.Unlock()
 
 		if user == nil {
 			return
@@ -45,7 +68,10 @@ func (manager *Manager) doUnpack(unlock *Unlock, user *UserData) {
 
 		manager.unpackUser(unlock, user)
 	} else {
-		if unlock.Users[0].UnpackStatus != NotUnpacked {
+		status := unlock.Users[0].UnpackStatus
+		manager.mutex.Unlock()
+
+		if status != NotUnpacked {
 			return
 		}
 
@@ -61,6 +87,27 @@ func (unlock *Unlock) QueueRefresh() {
 	unlock.queue <- actionRefresh{}
 }
 
-func (unlock *Unlock) QueueUnpack(user *UserData) {
-	unlock.queue <- actionUnpack{user: user}
+// QueueUnpack unpacks the unlock for the given profile, or for everyone if
+// unlocks aren't separated by user.
+func (unlock *Unlock) QueueUnpack(profileName string) {
+	unlock.queue <- actionUnpack{profileName: profileName}
+}
+
+// TryQueueDownload and TryQueueUnpack don't wait for room in the queue. They
+// return false if it is full.
+func (unlock *Unlock) TryQueueDownload() bool {
+	return unlock.tryQueue(actionDownload{})
+}
+
+func (unlock *Unlock) TryQueueUnpack(profileName string) bool {
+	return unlock.tryQueue(actionUnpack{profileName: profileName})
+}
+
+func (unlock *Unlock) tryQueue(action interface{}) bool {
+	select {
+	case unlock.queue <- action:
+		return true
+	default:
+		return false
+	}
 }
@@ -6,7 +6,9 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 
+	"github.com/GrooveStats/gslauncher/internal/metrics"
 	"github.com/GrooveStats/gslauncher/internal/settings"
 )
 
@@ -46,9 +48,15 @@ type Unlock struct {
 	queue chan interface{}
 }
 
+// Manager is safe for concurrent use. The unlocks are changed by the
+// download and unpack goroutines, so their fields must only be read from a
+// snapshot.
 type Manager struct {
 	DownloadDir string
-	Unlocks     []*Unlock
+
+	// guards unlocks and the fields of every unlock and user
+	mutex   sync.Mutex
+	unlocks []*Unlock
 
 	updateCallback func(*Unlock)
 }
@@ -62,8 +70,9 @@ func NewManager(cacheDir string) (*Manager, error) {
 	}
 
 	manager := Manager{
-		DownloadDir: downloadDir,
-		Unlocks:     make([]*Unlock, 0),
+		DownloadDir:    downloadDir,
+		unlocks:        make([]*Unlock, 0),
+		updateCallback: func(*Unlock) {},
 	}
 
 	return &manager, nil
@@ -74,24 +83,40 @@ func (manager *Manager) AddUnlock(questTitle, url, rpgName, profileName string,
 		profileName = "unnamed player"
 	}
 
-	for _, unlock := range manager.Unlocks {
+	manager.mutex.Lock()
+
+	for _, unlock := range manager.unlocks {
 		if unlock.RpgName == rpgName && unlock.DownloadUrl == url {
+			// GrooveStats reports the unlock again for later scores
+			for _, user := range unlock.Users {
+				if user.ProfileName == profileName {
+					manager.mutex.Unlock()
+					return
+				}
+			}
+
+			metrics.UnlocksObtained.Inc()
+
 			user := &UserData{
 				ProfileName: profileName,
 			}
 			unlock.Users = append(unlock.Users, user)
 			manager.detectUnpackStatus(unlock, user)
 
+			manager.mutex.Unlock()
+
 			manager.updateCallback(unlock)
 
 			mode := settings.Get().AutoDownloadMode
 			if mode == settings.AutoDownloadAndUnpack {
-				unlock.QueueUnpack(user)
+				unlock.QueueUnpack(profileName)
 			}
 			return
 		}
 	}
 
+	metrics.UnlocksObtained.Inc()
+
 	user := &UserData{
 		ProfileName: profileName,
 	}
@@ -110,7 +135,9 @@ func (manager *Manager) AddUnlock(questTitle, url, rpgName, profileName string,
 	manager.detectDownloadStatus(unlock)
 	manager.detectUnpackStatus(unlock, unlock.Users[0])
 
-	manager.Unlocks = append(manager.Unlocks, unlock)
+	manager.unlocks = append(manager.unlocks, unlock)
+
+	manager.mutex.Unlock()
 
 	manager.updateCallback(unlock)
 
@@ -120,11 +147,65 @@ func (manager *Manager) AddUnlock(questTitle, url, rpgName, profileName string,
 	if mode == settings.AutoDownloadOnly || mode == settings.AutoDownloadAndUnpack {
 		unlock.QueueDownload()
 		if mode == settings.AutoDownloadAndUnpack {
-			unlock.QueueUnpack(user)
+			unlock.QueueUnpack(user.ProfileName)
 		}
 	}
 }
 
+// Id identifies the unlock. It is stable across launcher restarts.
+func (unlock *Unlock) Id() string {
+	parts := strings.Split(unlock.DownloadUrl, "/")
+	basename := parts[len(parts)-1]
+	return fmt.Sprintf("%s-%s", unlock.RpgName, basename)
+}
+
+func (manager *Manager) GetUnlock(id string) *Unlock {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	for _, unlock := range manager.unlocks {
+		if unlock.Id() == id {
+			return unlock
+		}
+	}
+
+	return nil
+}
+
+// Snapshot returns copies of all unlocks, which can be read while the
+// unlocks are downloaded or unpacked.
+func (manager *Manager) Snapshot() []Unlock {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	snapshot := make([]Unlock, 0, len(manager.unlocks))
+	for _, unlock := range manager.unlocks {
+		snapshot = append(snapshot, unlock.copy())
+	}
+
+	return snapshot
+}
+
+// SnapshotOf returns a copy of a single unlock.
+func (manager *Manager) SnapshotOf(unlock *Unlock) Unlock {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	return unlock.copy()
+}
+
+func (unlock *Unlock) copy() Unlock {
+	c := *unlock
+
+	c.Users = make([]*UserData, 0, len(unlock.Users))
+	for _, user := range unlock.Users {
+		u := *user
+		c.Users = append(c.Users, &u)
+	}
+
+	return c
+}
+
 func (manager *Manager) SetUpdateCallback(callback func(*Unlock)) {
 	manager.updateCallback = callback
 }
@@ -172,7 +253,10 @@ func (manager *Manager) ClearCache() error {
 }
 
 func (manager *Manager) HasPending() bool {
-	for _, unlock := range manager.Unlocks {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	for _, unlock := range manager.unlocks {
 		for _, user := range unlock.Users {
 			if user.UnpackStatus != Unpacked {
 				return true
@@ -183,6 +267,28 @@ func (manager *Manager) HasPending() bool {
 	return false
 }
 
+// PendingCount returns the number of unlocks that haven't been unpacked for
+// every user yet.
+func (manager *Manager) PendingCount() int {
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+
+	count := 0
+
+	for _, unlock := range manager.unlocks {
+		for _, user := range unlock.Users {
+			if user.UnpackStatus != Unpacked {
+				count++
+				break
+			}
+		}
+	}
+
+	return count
+}
+
+// detectDownloadStatus and detectUnpackStatus must be called with the mutex
+// held.
 func (manager *Manager) detectDownloadStatus(unlock *Unlock) {
 	filename := manager.getCachePath(unlock)
 
@@ -222,12 +328,7 @@ func (manager *Manager) detectUnpackStatus(unlock *Unlock, user *UserData) {
 }
 
 func (manager *Manager) getCachePath(unlock *Unlock) string {
-	parts := strings.Split(unlock.DownloadUrl, "/")
-	basename := parts[len(parts)-1]
-	return filepath.Join(
-		manager.DownloadDir,
-		fmt.Sprintf("%s-%s", unlock.RpgName, basename),
-	)
+	return filepath.Join(manager.DownloadDir, unlock.Id())
 }
 
 func (manager *Manager) getUnpackPath(unlock *Unlock, profileName *string) string {
@@ -249,33 +350,43 @@ func (manager *Manager) getCookiePath(unlock *Unlock, profileName *string) strin
 }
 
 func (manager *Manager) download(unlock *Unlock) {
+	manager.mutex.Lock()
 	unlock.DownloadStatus = Downloading
 	unlock.DownloadError = nil
+	manager.mutex.Unlock()
 
 	filename := manager.getCachePath(unlock)
 	download := Fetch(unlock.DownloadUrl, filename)
 
 	for info := range download.Progress {
+		manager.mutex.Lock()
 		unlock.DownloadSize = info.TotalSize
 		unlock.DownloadProgress = info.Downloaded
 		if info.Error != nil {
 			unlock.DownloadStatus = NotDownloaded
 			unlock.DownloadError = info.Error
 		}
+		manager.mutex.Unlock()
+
 		manager.updateCallback(unlock)
 	}
 
+	manager.mutex.Lock()
 	if unlock.DownloadError == nil {
 		unlock.DownloadStatus = Downloaded
 	}
+	manager.mutex.Unlock()
+
 	manager.updateCallback(unlock)
 }
 
 func (manager *Manager) unpack(unlock *Unlock) {
+	manager.mutex.Lock()
 	for _, user := range unlock.Users {
 		user.UnpackStatus = Unpacking
 		user.UnpackError = nil
 	}
+	manager.mutex.Unlock()
 	manager.updateCallback(unlock)
 
 	filename := manager.getCachePath(unlock)
@@ -283,10 +394,12 @@ func (manager *Manager) unpack(unlock *Unlock) {
 
 	err := unzip(filename, unpackDir)
 	if err != nil {
+		manager.mutex.Lock()
 		for _, user := range unlock.Users {
 			user.UnpackStatus = NotUnpacked
 			user.UnpackError = err
 		}
+		manager.mutex.Unlock()
 		manager.updateCallback(unlock)
 		return
 	}
@@ -294,23 +407,28 @@ func (manager *Manager) unpack(unlock *Unlock) {
 	cookiePath := manager.getCookiePath(unlock, nil)
 	os.WriteFile(cookiePath, []byte(""), 0600)
 
+	removed := false
 	userUnlocks := settings.Get().UserUnlocks
 	if !userUnlocks {
-		err := os.Remove(filename)
-		if err == nil {
-			unlock.DownloadStatus = NotDownloaded
-		}
+		removed = os.Remove(filename) == nil
 	}
 
+	manager.mutex.Lock()
+	if removed {
+		unlock.DownloadStatus = NotDownloaded
+	}
 	for _, user := range unlock.Users {
 		user.UnpackStatus = Unpacked
 	}
+	manager.mutex.Unlock()
 	manager.updateCallback(unlock)
 }
 
 func (manager *Manager) unpackUser(unlock *Unlock, user *UserData) {
+	manager.mutex.Lock()
 	user.UnpackStatus = Unpacking
 	user.UnpackError = nil
+	manager.mutex.Unlock()
 	manager.updateCallback(unlock)
 
 	filename := manager.getCachePath(unlock)
@@ -318,8 +436,10 @@ func (manager *Manager) unpackUser(unlock *Unlock, user *UserData) {
 
 	err := unzip(filename, unpackDir)
 	if err != nil {
+		manager.mutex.Lock()
 		user.UnpackStatus = NotUnpacked
 		user.UnpackError = err
+		manager.mutex.Unlock()
 		manager.updateCallback(unlock)
 		return
 	}
@@ -327,13 +447,18 @@ func (manager *Manager) unpackUser(unlock *Unlock, user *UserData) {
 	cookiePath := manager.getCookiePath(unlock, &user.ProfileName)
 	os.WriteFile(cookiePath, []byte(""), 0600)
 
+	manager.mutex.Lock()
 	user.UnpackStatus = Unpacked
+	manager.mutex.Unlock()
 	manager.updateCallback(unlock)
 }
 
 func (manager *Manager) refresh(unlock *Unlock) {
+	manager.mutex.Lock()
 	for _, user := range unlock.Users {
 		manager.detectUnpackStatus(unlock, user)
 	}
+	manager.mutex.Unlock()
+
 	manager.updateCallback(unlock)
 }
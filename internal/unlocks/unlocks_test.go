@@ -0,0 +1,146 @@
+package unlocks
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GrooveStats/gslauncher/internal/metrics"
+	"github.com/GrooveStats/gslauncher/internal/settings"
+)
+
+func newTestPack(t *testing.T) []byte {
+	var buf bytes.Buffer
+
+	w := zip.NewWriter(&buf)
+	f, err := w.Create("Song/song.sm")
+	if err == nil {
+		_, err = f.Write([]byte("#TITLE:Song;"))
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	pack := newTestPack(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(pack)
+	}))
+	defer server.Close()
+
+	oldSettings := settings.Get()
+	defer settings.Update(oldSettings)
+
+	newSettings := settings.Get()
+	newSettings.AutoDownloadMode = settings.AutoDownloadAndUnpack
+	newSettings.UserUnlocks = false
+	newSettings.SmSongsDir = t.TempDir()
+	settings.Update(newSettings)
+
+	manager, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updates := make(chan struct{}, 1)
+	manager.SetUpdateCallback(func(unlock *Unlock) {
+		select {
+		case updates <- struct{}{}:
+		default:
+		}
+	})
+
+	url := server.URL + "/pack.zip"
+	go manager.AddUnlock("Quest", url, "Rpg", "player1", []string{"Song"})
+	go manager.AddUnlock("Quest", url, "Rpg", "player2", []string{"Song"})
+
+	// read the unlocks while they are downloaded and unpacked
+	deadline := time.After(10 * time.Second)
+	for {
+		manager.HasPending()
+		manager.PendingCount()
+
+		done := false
+		for _, unlock := range manager.Snapshot() {
+			done = len(unlock.Users) == 2
+			for _, user := range unlock.Users {
+				if user.UnpackStatus != Unpacked {
+					done = false
+				}
+			}
+		}
+		if done {
+			break
+		}
+
+		select {
+		case <-updates:
+		case <-time.After(10 * time.Millisecond):
+		case <-deadline:
+			t.Fatalf("unlock not unpacked: %+v", manager.Snapshot())
+		}
+	}
+
+	if manager.HasPending() || manager.PendingCount() != 0 {
+		t.Error("unpacked unlock is pending")
+	}
+
+	_, err = os.Stat(filepath.Join(newSettings.SmSongsDir, "Rpg Unlocks", "Song", "song.sm"))
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAddUnlockCountsOnce(t *testing.T) {
+	oldSettings := settings.Get()
+	defer settings.Update(oldSettings)
+
+	newSettings := settings.Get()
+	newSettings.AutoDownloadMode = settings.AutoDownloadOff
+	newSettings.SmSongsDir = t.TempDir()
+	settings.Update(newSettings)
+
+	manager, err := NewManager(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	obtained := metrics.UnlocksObtained.Value()
+
+	url := "https://example.com/pack.zip"
+	manager.AddUnlock("Quest", url, "Rpg", "player1", []string{"Song"})
+	manager.AddUnlock("Quest", url, "Rpg", "player1", []string{"Song"})
+	manager.AddUnlock("Quest", url, "Rpg", "player2", []string{"Song"})
+	manager.AddUnlock("Quest", url, "Rpg", "player2", []string{"Song"})
+
+	snapshot := manager.Snapshot()
+	if len(snapshot) != 1 || len(snapshot[0].Users) != 2 {
+		t.Errorf("unexpected unlocks: %+v", snapshot)
+	}
+
+	if n := metrics.UnlocksObtained.Value() - obtained; n != 2 {
+		t.Errorf("counted %d unlocks, expected 2", n)
+	}
+}
+
+func TestTryQueue(t *testing.T) {
+	unlock := &Unlock{queue: make(chan interface{}, 1)}
+
+	if !unlock.TryQueueDownload() {
+		t.Error("action not queued")
+	}
+	if unlock.TryQueueUnpack("player1") {
+		t.Error("action queued although the queue is full")
+	}
+}
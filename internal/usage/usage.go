@@ -0,0 +1,294 @@
+// Package usage keeps statistics about every launcher session in the cache
+// directory, so a flaky GrooveStats connection during an event can be spotted
+// afterwards. The counters of a session are the difference of the metrics at
+// its start and end.
+package usage
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/GrooveStats/gslauncher/internal/logging"
+	"github.com/GrooveStats/gslauncher/internal/metrics"
+)
+
+// older sessions are only kept in the totals
+const maxSessions = 200
+
+// Endpoint counts the requests of the theme to a GrooveStats endpoint.
+type Endpoint struct {
+	Requests int64 `json:"requests"`
+	Failures int64 `json:"failures"`
+	Disabled int64 `json:"disabled"`
+}
+
+// Session holds the statistics of one StepMania run.
+type Session struct {
+	Start time.Time `json:"start"`
+
+	// nil while the session is running, or if the launcher didn't exit
+	// cleanly
+	End *time.Time `json:"end,omitempty"`
+
+	SmRuntime       time.Duration       `json:"smRuntime"`
+	Endpoints       map[string]Endpoint `json:"endpoints"`
+	ScoresSubmitted int64               `json:"scoresSubmitted"`
+	UnlocksObtained int64               `json:"unlocksObtained"`
+}
+
+// Requests returns the sum of the endpoint counters.
+func (session *Session) Requests() Endpoint {
+	var sum Endpoint
+	for _, endpoint := range session.Endpoints {
+		sum.Requests += endpoint.Requests
+		sum.Failures += endpoint.Failures
+		sum.Disabled += endpoint.Disabled
+	}
+	return sum
+}
+
+// Totals sums up all sessions.
+type Totals struct {
+	Sessions        int64               `json:"sessions"`
+	SmRuntime       time.Duration       `json:"smRuntime"`
+	Endpoints       map[string]Endpoint `json:"endpoints"`
+	ScoresSubmitted int64               `json:"scoresSubmitted"`
+	UnlocksObtained int64               `json:"unlocksObtained"`
+}
+
+func (totals *Totals) add(session *Session) {
+	if totals.Endpoints == nil {
+		totals.Endpoints = make(map[string]Endpoint)
+	}
+
+	totals.Sessions++
+	totals.SmRuntime += session.SmRuntime
+	totals.ScoresSubmitted += session.ScoresSubmitted
+	totals.UnlocksObtained += session.UnlocksObtained
+
+	for name, endpoint := range session.Endpoints {
+		total := totals.Endpoints[name]
+		total.Requests += endpoint.Requests
+		total.Failures += endpoint.Failures
+		total.Disabled += endpoint.Disabled
+		totals.Endpoints[name] = total
+	}
+}
+
+type file struct {
+	// oldest first
+	Sessions []Session `json:"sessions"`
+
+	// sessions dropped from the list
+	Pruned Totals `json:"pruned"`
+}
+
+// counters is a snapshot of the metrics a session is made of.
+type counters struct {
+	endpoints map[string]Endpoint
+	scores    uint64
+	unlocks   uint64
+}
+
+func snapshot() counters {
+	c := counters{
+		endpoints: make(map[string]Endpoint),
+		scores:    metrics.ScoresSubmitted.Value(),
+		unlocks:   metrics.UnlocksObtained.Value(),
+	}
+
+	metrics.GsRequests.Each(func(labels []string, value uint64) {
+		endpoint := c.endpoints[labels[0]]
+		endpoint.Requests += int64(value)
+		switch labels[1] {
+		case "fail":
+			endpoint.Failures += int64(value)
+		case "disabled":
+			endpoint.Disabled += int64(value)
+		}
+		c.endpoints[labels[0]] = endpoint
+	})
+
+	return c
+}
+
+// Stats is the statistics file. It is safe for concurrent use.
+type Stats struct {
+	filename string
+	logger   *slog.Logger
+
+	mutex sync.Mutex
+	data  file
+
+	// the running session is the last one, its counters are relative to
+	// the snapshot taken at its start
+	running bool
+	base    counters
+	smStart time.Time
+}
+
+func Open(cacheDir string) (*Stats, error) {
+	stats := &Stats{
+		filename: filepath.Join(cacheDir, "groovestats-launcher", "statistics.json"),
+		logger:   logging.New("Usage"),
+	}
+
+	data, err := os.ReadFile(stats.filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return stats, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, &stats.data)
+	if err != nil {
+		// don't refuse to start over statistics
+		stats.logger.Warn("discarding corrupt statistics", "err", err)
+		stats.data = file{}
+	}
+
+	return stats, nil
+}
+
+// StartSession begins a session that started at the given time. The
+// StepMania runtime is counted from now on.
+func (stats *Stats) StartSession(start time.Time) error {
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+
+	stats.running = true
+	stats.base = snapshot()
+	stats.smStart = time.Now()
+	stats.data.Sessions = append(stats.data.Sessions, Session{
+		Start:     start,
+		Endpoints: make(map[string]Endpoint),
+	})
+
+	return stats.save()
+}
+
+// Checkpoint saves the counters of the running session, so they aren't lost
+// if the launcher doesn't exit cleanly.
+func (stats *Stats) Checkpoint() error {
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+
+	if !stats.running {
+		return nil
+	}
+
+	stats.update(time.Now())
+	return stats.save()
+}
+
+// EndSession ends the running session when StepMania exited.
+func (stats *Stats) EndSession(end time.Time) error {
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+
+	if !stats.running {
+		return nil
+	}
+
+	stats.update(end)
+	stats.data.Sessions[len(stats.data.Sessions)-1].End = &end
+	stats.running = false
+
+	for len(stats.data.Sessions) > maxSessions {
+		stats.data.Pruned.add(&stats.data.Sessions[0])
+		stats.data.Sessions = stats.data.Sessions[1:]
+	}
+
+	return stats.save()
+}
+
+// update sets the counters of the running session.
+func (stats *Stats) update(now time.Time) {
+	session := &stats.data.Sessions[len(stats.data.Sessions)-1]
+	current := snapshot()
+
+	session.SmRuntime = now.Sub(stats.smStart).Round(time.Second)
+	session.ScoresSubmitted = int64(current.scores - stats.base.scores)
+	session.UnlocksObtained = int64(current.unlocks - stats.base.unlocks)
+
+	session.Endpoints = make(map[string]Endpoint)
+	for name, endpoint := range current.endpoints {
+		base := stats.base.endpoints[name]
+		endpoint.Requests -= base.Requests
+		endpoint.Failures -= base.Failures
+		endpoint.Disabled -= base.Disabled
+		if endpoint != (Endpoint{}) {
+			session.Endpoints[name] = endpoint
+		}
+	}
+}
+
+// Sessions returns the sessions newest first, including the running one.
+func (stats *Stats) Sessions() []Session {
+	stats.mutex.Lock()
+	defer stats.mutex.Unlock()
+
+	if stats.running {
+		stats.update(time.Now())
+	}
+
+	sessions := make([]Session, len(stats.data.Sessions))
+	copy(sessions, stats.data.Sessions)
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].Start.After(sessions[j].Start)
+	})
+
+	return sessions
+}
+
+// Totals returns the totals of all sessions, including the running one.
+func (stats *Stats) Totals() Totals {
+	sessions := stats.Sessions()
+
+	stats.mutex.Lock()
+	totals := Totals{
+		Sessions:        stats.data.Pruned.Sessions,
+		SmRuntime:       stats.data.Pruned.SmRuntime,
+		Endpoints:       make(map[string]Endpoint),
+		ScoresSubmitted: stats.data.Pruned.ScoresSubmitted,
+		UnlocksObtained: stats.data.Pruned.UnlocksObtained,
+	}
+	for name, endpoint := range stats.data.Pruned.Endpoints {
+		totals.Endpoints[name] = endpoint
+	}
+	stats.mutex.Unlock()
+
+	for i := range sessions {
+		totals.add(&sessions[i])
+	}
+
+	return totals
+}
+
+func (stats *Stats) save() error {
+	data, err := json.Marshal(&stats.data)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(stats.filename), os.ModeDir|0700)
+	if err != nil {
+		return err
+	}
+
+	// never leave a truncated file behind
+	tmpFilename := stats.filename + ".tmp"
+	err = os.WriteFile(tmpFilename, data, 0600)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFilename, stats.filename)
+}
@@ -0,0 +1,137 @@
+package usage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GrooveStats/gslauncher/internal/metrics"
+)
+
+func TestSessions(t *testing.T) {
+	cacheDir := t.TempDir()
+
+	stats, err := Open(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// requests before the session don't count
+	metrics.GsRequests.With("player-scores", "success").Inc()
+
+	start := time.Now()
+	err = stats.StartSession(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metrics.GsRequests.With("player-scores", "success").Add(3)
+	metrics.GsRequests.With("player-scores", "fail").Inc()
+	metrics.GsRequests.With("score-submit", "disabled").Inc()
+	metrics.ScoresSubmitted.Add(2)
+	metrics.UnlocksObtained.Inc()
+
+	sessions := stats.Sessions()
+	if len(sessions) != 1 || sessions[0].End != nil {
+		t.Fatalf("running session missing: %+v", sessions)
+	}
+
+	err = stats.EndSession(start.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// later requests don't count either
+	metrics.GsRequests.With("player-scores", "fail").Inc()
+
+	stats, err = Open(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessions = stats.Sessions()
+	if len(sessions) != 1 || sessions[0].End == nil {
+		t.Fatalf("session not saved: %+v", sessions)
+	}
+
+	session := sessions[0]
+	expected := map[string]Endpoint{
+		"player-scores": {Requests: 4, Failures: 1},
+		"score-submit":  {Requests: 1, Disabled: 1},
+	}
+	for name, endpoint := range expected {
+		if session.Endpoints[name] != endpoint {
+			t.Errorf("%s: expected %+v, got %+v", name, endpoint, session.Endpoints[name])
+		}
+	}
+	if session.ScoresSubmitted != 2 || session.UnlocksObtained != 1 {
+		t.Errorf("unexpected scores %d and unlocks %d", session.ScoresSubmitted, session.UnlocksObtained)
+	}
+
+	err = stats.StartSession(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics.ScoresSubmitted.Inc()
+	err = stats.EndSession(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	totals := stats.Totals()
+	if totals.Sessions != 2 || totals.ScoresSubmitted != 3 || totals.Endpoints["player-scores"].Requests != 4 {
+		t.Fatalf("unexpected totals %+v", totals)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	stats, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	for i := 0; i < maxSessions+5; i++ {
+		err = stats.StartSession(start.Add(time.Duration(i) * time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+		metrics.ScoresSubmitted.Inc()
+		err = stats.EndSession(time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := len(stats.Sessions()); n != maxSessions {
+		t.Fatalf("expected %d sessions, got %d", maxSessions, n)
+	}
+
+	totals := stats.Totals()
+	if totals.Sessions != maxSessions+5 || totals.ScoresSubmitted != maxSessions+5 {
+		t.Fatalf("pruned sessions missing from the totals: %+v", totals)
+	}
+}
+
+func TestCorruptFile(t *testing.T) {
+	cacheDir := t.TempDir()
+	filename := filepath.Join(cacheDir, "groovestats-launcher", "statistics.json")
+
+	err := os.MkdirAll(filepath.Dir(filename), 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filename, []byte("{"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := Open(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Sessions()) != 0 {
+		t.Fatal("expected no sessions")
+	}
+}
@@ -7,7 +7,9 @@ var Major int = 1
 var Minor int = 6
 var Patch int = 1
 
-var Protocol int = 1
+// the range of IPC protocol versions the launcher understands
+var Protocol int = 2
+var MinProtocol int = 1
 
 func Formatted() string {
 	return fmt.Sprintf("%d.%d.%d", Major, Minor, Patch)